@@ -0,0 +1,77 @@
+// File: cmd/backtest/main.go
+//
+// Replays recorded market data (HEDGE_REC files) through the box strategy
+// and prints the simulated result:
+//
+//	backtest [-json] recordings/            (every md-*.bin in the directory)
+//	backtest [-json] md-20250901-000000.bin md-20250902-000000.bin
+package main
+
+import (
+	"Options_Hedger/internal/backtest"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "print the full report (with every fill) as JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-json] <file.bin|dir>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	_ = godotenv.Load()
+
+	paths, err := inputs(flag.Args())
+	if err != nil {
+		log.Fatalf("[BT] %v", err)
+	}
+	if len(paths) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	rep, err := backtest.New(backtest.ConfigFromEnv()).Run(paths)
+	if err != nil {
+		log.Fatalf("[BT] %v", err)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rep); err != nil {
+			log.Fatalf("[BT] %v", err)
+		}
+		return
+	}
+	rep.Print(os.Stdout)
+}
+
+// inputs expands directories to their recordings and sorts the files by
+// name, which is chronological for recorder files.
+func inputs(args []string) ([]string, error) {
+	var paths []string
+	for _, a := range args {
+		fi, err := os.Stat(a)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			paths = append(paths, a)
+			continue
+		}
+		m, err := filepath.Glob(filepath.Join(a, "md-*.bin"))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, m...)
+	}
+	sort.Slice(paths, func(i, j int) bool { return filepath.Base(paths[i]) < filepath.Base(paths[j]) })
+	return paths, nil
+}
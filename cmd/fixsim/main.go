@@ -0,0 +1,35 @@
+// File: cmd/fixsim/main.go
+//
+// Local Deribit FIX gateway for development and integration runs:
+//
+//	FIXSIM_CLIENT=<SenderCompID> go run ./cmd/fixsim
+//	FIX_CONFIG=config/quickfix.sim.cfg HEDGE_POS_RECONCILE=off ./hedger
+package main
+
+import (
+	"Options_Hedger/internal/fixsim"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	_ = godotenv.Load()
+
+	srv, err := fixsim.New(fixsim.ConfigFromEnv())
+	if err != nil {
+		log.Fatalf("[FIXSIM] %v", err)
+	}
+	if err := srv.Start(); err != nil {
+		log.Fatalf("[FIXSIM] start failed: %v", err)
+	}
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	<-sigc
+	log.Println("[FIXSIM] shutting down...")
+	srv.Stop()
+}
@@ -7,6 +7,13 @@ import (
 	"Options_Hedger/internal/data"
 	"Options_Hedger/internal/fix"
 	"Options_Hedger/internal/notify"
+	"Options_Hedger/internal/paper"
+	"Options_Hedger/internal/positions"
+	"Options_Hedger/internal/pricing"
+	"Options_Hedger/internal/recorder"
+	"Options_Hedger/internal/risk"
+	"Options_Hedger/internal/servers"
+	"Options_Hedger/internal/surface"
 	"context"
 	"log"
 	"os"
@@ -32,6 +39,14 @@ func main() {
 	}
 	_ = auth.FetchJWTToken(clientID, clientSecret)
 
+	// Universe capacity / book depth (HEDGE_MAX_OPTIONS, DATA_BOOK_DEPTH)
+	data.ConfigureFromEnv()
+	log.Printf("[INFO] Universe capacity: %d instruments, %d levels per side", data.Capacity(), data.BookDepth())
+
+	// Cross-process SharedBook segment (Linux: /dev/shm, otherwise heap)
+	if err := data.InitSharedMemory(); err != nil {
+		log.Printf("[SHM] init failed, using process-local book: %v", err)
+	}
 	log.Printf("[INFO] Shared memory base pointer: 0x%x", data.SharedMemoryPtr())
 
 	// Prepare option universe
@@ -64,21 +79,96 @@ func main() {
 	updatesCh := make(chan data.Update, 2048)
 	data.InitOrderBooks(opts.Symbols, updatesCh)
 
+	// Optional market data recorder (HEDGE_REC=1), written off the hot path
+	if cfg, ok := recorder.ConfigFromEnv(); ok {
+		rec := recorder.New(cfg)
+		if err := rec.Start(); err != nil {
+			log.Printf("[REC] start failed: %v", err)
+		} else {
+			defer rec.Stop()
+		}
+	}
+
+	// Implied vols and greeks into greeksTab, off the hot path (HEDGE_GREEKS_MS)
+	greeksCtx, stopGreeks := context.WithCancel(context.Background())
+	defer stopGreeks()
+	if cfg, ok := pricing.ConfigFromEnv(); ok {
+		go pricing.NewWorker(cfg).Run(greeksCtx)
+	}
+	// Parity-implied forwards and rates per expiry (HEDGE_CURVE_SEC)
+	if cfg, ok := pricing.CurveConfigFromEnv(); ok {
+		go pricing.NewCurveBuilder(cfg).Run(greeksCtx)
+	}
+	// SVI smile per expiry, refit from the subscribed chain (HEDGE_SVI_SEC)
+	if cfg, ok := surface.ConfigFromEnv(); ok {
+		fitter := surface.New(cfg)
+		surface.Install(fitter)
+		go fitter.Run(greeksCtx)
+	}
+
 	// Optional notifier (Telegram)
 	var ntf notify.Notifier
 	if n, err := notify.NewTelegramFromEnv(); err == nil {
 		ntf = n
 	}
 
+	// Position book: loaded from Deribit, updated from fills, reconciled periodically.
+	// Paper trading (HEDGE_PAPER=1) fills orders locally and starts flat, as does
+	// HEDGE_POS_RECONCILE=off (FIX pointed at cmd/fixsim).
+	posCtx, stopPos := context.WithCancel(context.Background())
+	defer stopPos()
+	if paper.Enabled() {
+		sim := paper.New(paper.ConfigFromEnv())
+		paper.Install(sim)
+		go sim.Run(posCtx)
+		positions.Track()
+	} else {
+		positions.Start(posCtx, ntf)
+	}
+
+	// Pre-trade risk gate in front of every FIX order (HEDGE_RISK_*)
+	gate := risk.NewGate(risk.LimitsFromEnv())
+	risk.Install(gate)
+
+	// Kill switch: restores a persisted trip before any order can go out
+	kill := risk.NewKillSwitch(gate, risk.KillConfigFromEnv(), ntf)
+	killCtx, stopKill := context.WithCancel(context.Background())
+	defer stopKill()
+	go kill.Run(killCtx)
+	if tg, ok := ntf.(*notify.Telegram); ok {
+		go tg.Commands(killCtx, kill.HandleCommand) // /kill, /rearm, /status
+	}
+	servers.ServeAdminHTTP(kill)
+
 	// Select and start trading strategy
 	handle := app.StartEngine(app.ChooseStrategy(), updatesCh, opts.Symbols, ntf)
 
-	// Maintain FIX session for order handling (without subscribing to market data in OnLogon)
-	if err := fix.InitFIXEngine("config/quickfix.cfg"); err != nil {
+	// Roll the universe at expiry / on index drift
+	rollCtx, stopRoll := context.WithCancel(context.Background())
+	defer stopRoll()
+	if roller := app.NewUniverseRollerFromEnv(handle, opts); roller != nil {
+		go roller.Run(rollCtx)
+	}
+
+	// Maintain FIX session for order handling (FIX_CONFIG, e.g. config/quickfix.sim.cfg for cmd/fixsim)
+	fixCfg := os.Getenv("FIX_CONFIG")
+	if fixCfg == "" {
+		fixCfg = "config/quickfix.cfg"
+	}
+	if err := fix.InitFIXEngine(fixCfg); err != nil {
 		log.Printf("[FIX] Init failed: %v", err)
 	}
 	defer fix.StopFIXEngine()
 
+	// SIGUSR1 trips the kill switch
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
+	go func() {
+		for range usr1 {
+			kill.Trip("signal SIGUSR1")
+		}
+	}()
+
 	// Wait for termination signal
 	sigc := make(chan os.Signal, 1)
 	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"Options_Hedger/internal/data"
+	"Options_Hedger/internal/fix"
+	"fmt"
+	"log"
+)
+
+// UniverseEngine is implemented by strategies whose option table can be
+// edited while running (indexes are data symbol indexes). Once a removal is
+// applied the engine must lift the slot's hold with data.ReleaseSymbol.
+type UniverseEngine interface {
+	AddOption(idx int32, symbol string)
+	RemoveOption(idx int32)
+}
+
+// AddInstruments subscribes symbols at runtime and registers them with the
+// running strategy. Symbols that fail to get a slot are skipped.
+func (h *Handle) AddInstruments(symbols []string) error {
+	idxs, err := fix.Subscribe(symbols)
+	if h.Engine != nil {
+		for i, idx := range idxs {
+			if idx >= 0 {
+				h.Engine.AddOption(idx, symbols[i])
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("add instruments: %w", err)
+	}
+	log.Printf("[UNIVERSE] added %d instruments", len(symbols))
+	return nil
+}
+
+// RemoveInstruments unsubscribes symbols at runtime. Freed slots are held
+// stale until the strategy applies the removal, so neither the old symbol nor
+// a new one reusing the slot is traded on the old option table.
+func (h *Handle) RemoveInstruments(symbols []string) {
+	freed := fix.Unsubscribe(symbols)
+	for _, idx := range freed {
+		if h.Engine != nil {
+			h.Engine.RemoveOption(idx)
+		} else {
+			data.ReleaseSymbol(idx)
+		}
+	}
+	log.Printf("[UNIVERSE] removed %d instruments", len(freed))
+}
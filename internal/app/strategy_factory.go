@@ -2,6 +2,7 @@ package app
 
 import (
 	"Options_Hedger/internal/data"
+	"Options_Hedger/internal/execution"
 	"Options_Hedger/internal/notify"
 	"Options_Hedger/internal/strategy"
 	"bufio"
@@ -11,6 +12,8 @@ import (
 	"os"
 	"strings"
 	"time"
+
+	"github.com/quickfixgo/enum"
 )
 
 type Kind int
@@ -20,8 +23,9 @@ const (
 )
 
 type Handle struct {
-	Name string
-	Stop func(ctx context.Context)
+	Name   string
+	Stop   func(ctx context.Context)
+	Engine UniverseEngine // nil if the strategy cannot change its universe at runtime
 }
 
 // Single-strategy build (Box): regardless of inputs, always select Box.
@@ -84,37 +88,23 @@ func StartEngine(kind Kind, updatesCh chan data.Update, symbols []string, ntf no
 	switch kind {
 	default: // KindBox
 		eng := strategy.NewBoxSpreadHFT(updatesCh)
+		eng.SetParams(strategy.BoxParamsFromEnv())
 		eng.InitializeHFT(symbols)
 		go eng.Run()
 		log.Printf("Box spread started..")
 
-		// Single consumer: log + Telegram notifications.
+		// Optional executor (HEDGE_EXEC_MODE); nil = signals only
+		execCtx, stopExec := context.WithCancel(context.Background())
+		exec := execution.NewBoxExecutor(execution.ConfigFromEnv())
+		if exec != nil {
+			go exec.Run(execCtx)
+			go reportResults(exec.Results(), ntf)
+		}
+
+		// Notifications run off the signal path, dropped when they fall behind.
+		notes := make(chan string, 64)
 		go func() {
-			for sig := range eng.Signals() {
-				lowCallSym := data.GetSymbolName(int32(sig.LowCallIdx))
-				lowPutSym := data.GetSymbolName(int32(sig.LowPutIdx))
-				highCallSym := data.GetSymbolName(int32(sig.HighCallIdx))
-				highPutSym := data.GetSymbolName(int32(sig.HighPutIdx))
-				lowCall := data.ReadDepthFast(int(sig.LowCallIdx))
-				lowPut := data.ReadDepthFast(int(sig.LowPutIdx))
-				highCall := data.ReadDepthFast(int(sig.HighCallIdx))
-				highPut := data.ReadDepthFast(int(sig.HighPutIdx))
-				idx := data.GetIndexPrice()
-
-				msg := fmt.Sprintf(
-					"[BOX-SPREAD]\n"+
-						"strikes=%.0f→%.0f  index=%.2f  profit=$%.2f\n"+
-						"buyCallLo : %s  ask@%.4f (qty=%.4f)\n"+
-						"sellCallHi: %s  bid@%.4f (qty=%.4f)\n"+
-						"sellPutLo : %s  bid@%.4f (qty=%.4f)\n"+
-						"buyPutHi  : %s  ask@%.4f (qty=%.4f)",
-					sig.LowStrike, sig.HighStrike, idx, sig.Profit,
-					lowCallSym, lowCall.AskPrice, lowCall.AskQty,
-					highCallSym, highCall.BidPrice, highCall.BidQty,
-					lowPutSym, lowPut.BidPrice, lowPut.BidQty,
-					highPutSym, highPut.AskPrice, highPut.AskQty,
-				)
-				log.Print(msg)
+			for msg := range notes {
 				if ntf != nil {
 					ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 					_ = ntf.Send(ctx, msg)
@@ -124,6 +114,92 @@ func StartEngine(kind Kind, updatesCh chan data.Update, symbols []string, ntf no
 			}
 		}()
 
-		return &Handle{Name: "box_spread", Stop: nil}
+		// Single consumer: on each wake-up take the waiting signals best
+		// first, hand each to the executor, then log and notify.
+		go func() {
+			for range eng.Ready() {
+				for {
+					sig, ok := eng.NextSignal()
+					if !ok {
+						break
+					}
+					if exec != nil && !exec.Submit(sig) {
+						log.Printf("[EXEC] busy, signal %.0f/%.0f skipped", sig.LowStrike, sig.HighStrike)
+					}
+					lowCallSym := data.GetSymbolName(int32(sig.LowCallIdx))
+					lowPutSym := data.GetSymbolName(int32(sig.LowPutIdx))
+					highCallSym := data.GetSymbolName(int32(sig.HighCallIdx))
+					highPutSym := data.GetSymbolName(int32(sig.HighPutIdx))
+					legs := data.ReadDepth4Fast(int(sig.LowCallIdx), int(sig.LowPutIdx), int(sig.HighCallIdx), int(sig.HighPutIdx))
+					lowCall, lowPut, highCall, highPut := legs[0], legs[1], legs[2], legs[3]
+					idx := data.GetIndexPrice()
+
+					msg := fmt.Sprintf(
+						"[BOX-SPREAD]\n"+
+							"strikes=%.0f→%.0f  index=%.2f  profit=$%.2f  rate=%.2f%% (edge %+.2f%%)\n"+
+							"tte=%.1fd  capital=$%.2f  apr=%.2f%%\n"+
+							"buyCallLo : %s  ask@%.4f (qty=%.4f)\n"+
+							"sellCallHi: %s  bid@%.4f (qty=%.4f)\n"+
+							"sellPutLo : %s  bid@%.4f (qty=%.4f)\n"+
+							"buyPutHi  : %s  ask@%.4f (qty=%.4f)",
+						sig.LowStrike, sig.HighStrike, idx, sig.Profit, sig.Rate*100, sig.RateEdge*100,
+						sig.Years*365, sig.CapitalUSD, sig.APR*100,
+						lowCallSym, lowCall.AskPrice, lowCall.AskQty,
+						highCallSym, highCall.BidPrice, highCall.BidQty,
+						lowPutSym, lowPut.BidPrice, lowPut.BidQty,
+						highPutSym, highPut.AskPrice, highPut.AskQty,
+					)
+					log.Print(msg)
+					select {
+					case notes <- msg:
+					default:
+					}
+				}
+			}
+		}()
+
+		stop := func(ctx context.Context) { stopExec() }
+		return &Handle{Name: "box_spread", Stop: stop, Engine: eng}
+	}
+}
+
+// reportResults logs executor outcomes and forwards them to the notifier.
+func reportResults(results <-chan execution.Result, ntf notify.Notifier) {
+	for r := range results {
+		status := "FILLED"
+		if !r.Complete {
+			status = "INCOMPLETE"
+		}
+		msg := fmt.Sprintf("[BOX-EXEC] %s via %s strikes=%.0f→%.0f qty=%g realized=$%.2f (signal $%.2f) in %s",
+			status, r.Via, r.Signal.LowStrike, r.Signal.HighStrike, r.Qty, r.RealizedUSD, r.Signal.Profit,
+			r.Finished.Sub(r.Started).Round(time.Millisecond))
+		for _, l := range r.Legs {
+			msg += fmt.Sprintf("\n%-4s %s %g/%g @%.4f", sideName(l.Side), l.Symbol, l.Filled, l.Qty, l.AvgPx)
+			if l.Unwound > 0 {
+				msg += fmt.Sprintf(" unwound %g @%.4f", l.Unwound, l.UnwindPx)
+			}
+		}
+		if r.UnwindLossBTC != 0 {
+			msg += fmt.Sprintf("\nunwind loss: %.6f BTC ($%.2f)", r.UnwindLossBTC, r.UnwindLossUSD)
+		}
+		if r.Naked {
+			msg += "\nWARNING: naked leg left open"
+		}
+		if r.Err != nil {
+			msg += "\nerr: " + r.Err.Error()
+		}
+		log.Print(msg)
+		if ntf != nil && (r.Qty > 0 || r.Legs[0].Filled > 0) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			_ = ntf.Send(ctx, msg)
+			cancel()
+		}
+	}
+}
+
+func sideName(s enum.Side) string {
+	if s == enum.Side_BUY {
+		return "BUY"
 	}
+	return "SELL"
 }
@@ -3,66 +3,160 @@ package app
 import (
 	"Options_Hedger/internal/data"
 	"encoding/json"
+	"fmt"
 	"log"
-	"math"
 	"net/http"
-	"os"
 	"sort"
-	"strconv"
-	"strings"
 	"time"
 )
 
+// Instrument is one entry of public/get_instruments.
 type Instrument struct {
-	Name     string `json:"instrument_name"`
-	IsActive bool   `json:"is_active"`
-	ExpireMs int64  `json:"expiration_timestamp"`
+	Name               string  `json:"instrument_name"`
+	IsActive           bool    `json:"is_active"`
+	ExpireMs           int64   `json:"expiration_timestamp"`
+	Kind               string  `json:"kind"`
+	OptionType         string  `json:"option_type"` // "call" | "put"
+	Strike             float64 `json:"strike"`
+	TickSize           float64 `json:"tick_size"`
+	MinTradeAmount     float64 `json:"min_trade_amount"`
+	ContractSize       float64 `json:"contract_size"`
+	SettlementCurrency string  `json:"settlement_currency"`
+	BaseCurrency       string  `json:"base_currency"`
+}
+
+// IsCall reports whether the instrument is a call.
+func (inst Instrument) IsCall() bool { return inst.OptionType == "call" }
+
+// Meta converts the exchange record to registry metadata.
+func (inst Instrument) Meta() data.InstrumentMeta {
+	m := data.InstrumentMeta{
+		Name:               inst.Name,
+		Kind:               inst.Kind,
+		Strike:             inst.Strike,
+		ExpiryMs:           inst.ExpireMs,
+		TickSize:           inst.TickSize,
+		MinTradeAmount:     inst.MinTradeAmount,
+		ContractSize:       inst.ContractSize,
+		SettlementCurrency: inst.SettlementCurrency,
+		BaseCurrency:       inst.BaseCurrency,
+	}
+	switch inst.OptionType {
+	case "call":
+		m.OptionType = data.Call
+	case "put":
+		m.OptionType = data.Put
+	}
+	return m
 }
 
 type Universe struct {
-	Symbols []string
+	Symbols    []string
+	Center     float64   // index price the strikes were centered on
+	NearExpiry time.Time // UTC
+	FarExpiry  time.Time // UTC
 }
 
 func BuildUniverse() (Universe, string, string, error) {
 	S := fetchBTCPrice()
 	data.SetIndexPrice(S)
+	return buildUniverseAt(S)
+}
+
+// buildUniverseAt selects the universe around index price S using the
+// configured UniverseSelector (see universeConfigFromEnv).
+func buildUniverseAt(S float64) (Universe, string, string, error) {
+	cfg, err := universeConfigFromEnv()
+	if err != nil {
+		return Universe{}, "", "", err
+	}
+	instruments, err := fetchInstruments()
+	if err != nil {
+		return Universe{}, "", "", err
+	}
 
-	instruments := fetchInstruments()
+	// Expiries: near/far within the window, every expiry within the window,
+	// or every listed expiry for selectors that ignore the window
+	var exps []expiry
+	if as, ok := cfg.Selector.(allExpiries); ok && as.allExpiries() {
+		exps = listExpiries(instruments, 0)
+	} else if cfg.AllExpiries {
+		exps = listExpiries(instruments, cfg.MaxDays)
+	} else {
+		nearLabel, nearUTC, farLabel, farUTC, err := findNearAndFarWithinDays(instruments, cfg.MaxDays)
+		if err != nil {
+			return Universe{}, "", "", err
+		}
+		exps = []expiry{{nearLabel, nearUTC}}
+		if farLabel != nearLabel {
+			exps = append(exps, expiry{farLabel, farUTC})
+		}
+	}
+	if len(exps) == 0 {
+		return Universe{}, "", "", fmt.Errorf("[EXPIRY] no future expiries found within %d days", cfg.MaxDays)
+	}
 
-	// Time window: default 7 days
-	maxDays := 7
-	if v := strings.TrimSpace(os.Getenv("HEDGE_EM_MAX_DAYS")); v != "" {
-		if x, err := strconv.Atoi(v); err == nil && x > 0 {
-			maxDays = x
+	env := SelectEnv{Index: S, Now: time.Now().UTC()}
+	if su, ok := cfg.Selector.(summaryUser); ok && su.needsSummary() {
+		if env.Summary, err = fetchBookSummaries(); err != nil {
+			return Universe{}, "", "", err
 		}
 	}
 
-	nearLabel, nearUTC, farLabel, farUTC := findNearAndFarWithinDays(instruments, maxDays)
+	// Rank every expiry first so the cap is split only across expiries that
+	// have candidates
+	type chain struct {
+		e      expiry
+		ranked []Instrument
+	}
+	var chains []chain
+	for _, e := range exps {
+		if ranked := cfg.Selector.Rank(chainAt(instruments, e.t), env); len(ranked) > 0 {
+			chains = append(chains, chain{e, ranked})
+		}
+	}
 
-	// Per-expiry cap: total 40 options, evenly split into 20 near and 20 far
-	perCap := data.MaxOptions / 2
-	nearSyms := filterOptionsByTSCap(instruments, nearUTC, S, perCap)
-	farSyms := filterOptionsByTSCap(instruments, farUTC, S, perCap)
+	// Per-expiry cap: capacity evenly split across those expiries unless
+	// HEDGE_PER_EXPIRY_CAP overrides it; an explicit list (file policy) is
+	// only bounded by the capacity
+	capacity := data.Capacity()
+	as, explicit := cfg.Selector.(allExpiries)
+	explicit = explicit && as.allExpiries()
+	perCap := cfg.PerCap
+	if perCap <= 0 {
+		perCap = capacity / max(len(chains), 1)
+		if explicit {
+			perCap = capacity
+		}
+	}
 
-	// Deduplicate if near == far
-	merged := make([]string, 0, data.MaxOptions)
-	seen := make(map[string]struct{}, data.MaxOptions)
-	for _, s := range append(nearSyms, farSyms...) {
-		if _, ok := seen[s]; ok {
-			continue
+	merged := make([]string, 0, capacity)
+	dropped := 0
+	for _, c := range chains {
+		var picked []string
+		if cfg.Paired {
+			picked = capPaired(c.ranked, perCap)
+		} else {
+			picked = capBalanced(c.ranked, perCap)
+		}
+		if room := capacity - len(merged); len(picked) > room {
+			picked = picked[:room]
 		}
-		seen[s] = struct{}{}
-		merged = append(merged, s)
-		if len(merged) >= data.MaxOptions {
-			break
+		merged = append(merged, picked...)
+		log.Printf("[INFO] Expiry %s (UTC %s): %d options", c.e.label, c.e.t.Format(time.RFC3339), len(picked))
+		if n := len(c.ranked) - len(picked); explicit && n > 0 {
+			dropped += n
+			log.Printf("[UNIVERSE] expiry %s: %d listed options dropped (cap %d, paired=%t)", c.e.label, n, perCap, cfg.Paired)
 		}
 	}
+	if dropped > 0 {
+		log.Printf("[UNIVERSE] %d listed options dropped; raise the capacity or HEDGE_PER_EXPIRY_CAP", dropped)
+	}
 
-	log.Printf("[INFO] Nearest expiry: %s (UTC %s)", nearLabel, nearUTC.Format(time.RFC3339))
-	log.Printf("[INFO] Farthest expiry within %dd: %s (UTC %s)", maxDays, farLabel, farUTC.Format(time.RFC3339))
-	log.Printf("[INFO] Filtered %d options (near %d, far %d) within 20%% of ATM", len(merged), len(nearSyms), len(farSyms))
+	near, far := exps[0], exps[len(exps)-1]
+	log.Printf("[INFO] Selected %d options over %d expiries via %s (paired=%t)", len(merged), len(exps), cfg.Selector.Name(), cfg.Paired)
 
-	return Universe{Symbols: merged}, nearLabel, farLabel, nil
+	return Universe{Symbols: merged, Center: S, NearExpiry: near.t, FarExpiry: far.t}, near.label, far.label, nil
 }
 
 // Fetch BTC index price from Deribit.
@@ -87,11 +181,11 @@ func fetchBTCPrice() float64 {
 }
 
 // Fetch the full list of BTC option instruments from Deribit.
-// Returns only active instruments.
-func fetchInstruments() []Instrument {
+// Returns only active instruments and loads them into the data registry.
+func fetchInstruments() ([]Instrument, error) {
 	res, err := http.Get("https://www.deribit.com/api/v2/public/get_instruments?currency=BTC&kind=option")
 	if err != nil {
-		log.Fatal("[INSTR] fetch failed:", err)
+		return nil, fmt.Errorf("[INSTR] fetch failed: %w", err)
 	}
 	defer res.Body.Close()
 
@@ -99,125 +193,74 @@ func fetchInstruments() []Instrument {
 		Result []Instrument `json:"result"`
 	}
 	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-		log.Fatal("[INSTR] decode failed:", err)
+		return nil, fmt.Errorf("[INSTR] decode failed: %w", err)
 	}
 	out := make([]Instrument, 0, len(r.Result))
+	metas := make([]data.InstrumentMeta, 0, len(r.Result))
 	for _, inst := range r.Result {
 		if inst.IsActive {
 			out = append(out, inst)
+			metas = append(metas, inst.Meta())
 		}
 	}
+	data.LoadInstrumentCatalog(metas)
 	log.Printf("[INFO] Fetched %d active instruments", len(out))
-	return out
+	return out, nil
 }
 
-// findNearAndFarWithinDays:
-// Among expiries that fall between now and now+maxDays,
-// pick the nearest expiry (near) and the farthest expiry (far).
-func findNearAndFarWithinDays(instruments []Instrument, maxDays int) (nearLabel string, nearUTC time.Time, farLabel string, farUTC time.Time) {
+type expiry struct {
+	label string
+	t     time.Time
+}
+
+// listExpiries returns distinct future expiries (within maxDays if > 0), nearest first.
+func listExpiries(instruments []Instrument, maxDays int) []expiry {
 	nowUTC := time.Now().UTC()
 	limit := nowUTC.Add(time.Duration(maxDays) * 24 * time.Hour)
 
-	type exp struct {
-		label string
-		t     time.Time
-	}
 	seen := make(map[string]bool)
-	var exps []exp
-
+	var exps []expiry
 	for _, inst := range instruments {
 		t := time.UnixMilli(inst.ExpireMs).UTC()
-		if !t.After(nowUTC) || t.After(limit) {
+		if !t.After(nowUTC) || (maxDays > 0 && t.After(limit)) {
 			continue
 		}
-		parts := strings.Split(inst.Name, "-")
-		if len(parts) < 3 {
-			continue
-		}
-		lbl := parts[1]
-		if seen[lbl] {
+		lbl := inst.Meta().ExpiryLabel()
+		if lbl == "" || seen[lbl] {
 			continue
 		}
 		seen[lbl] = true
-		exps = append(exps, exp{label: lbl, t: t})
+		exps = append(exps, expiry{label: lbl, t: t})
 	}
+	sort.Slice(exps, func(i, j int) bool { return exps[i].t.Before(exps[j].t) })
+	return exps
+}
+
+// findNearAndFarWithinDays:
+// Among expiries that fall between now and now+maxDays,
+// pick the nearest expiry (near) and the farthest expiry (far).
+func findNearAndFarWithinDays(instruments []Instrument, maxDays int) (nearLabel string, nearUTC time.Time, farLabel string, farUTC time.Time, err error) {
+	exps := listExpiries(instruments, maxDays)
 	if len(exps) == 0 {
-		log.Fatal("[EXPIRY] no future expiries found within window")
+		err = fmt.Errorf("[EXPIRY] no future expiries found within %d days", maxDays)
+		return
 	}
-	sort.Slice(exps, func(i, j int) bool { return exps[i].t.Before(exps[j].t) })
 	nearLabel, nearUTC = exps[0].label, exps[0].t
 	farLabel, farUTC = exps[len(exps)-1].label, exps[len(exps)-1].t
 	return
 }
 
-// filterOptionsByTSCap:
-// For a specific expiry, filter options that are within ±20% of ATM price.
-// Limit the count to "cap", evenly split between calls and puts.
-func filterOptionsByTSCap(instruments []Instrument, expiryUTC time.Time, atmPrice float64, cap int) []string {
-	type opt struct {
-		name     string
-		strike   float64
-		distance float64
-		isCall   bool
-	}
-	var list []opt
+// chainAt returns the active options of one expiry.
+func chainAt(instruments []Instrument, expiryUTC time.Time) []Instrument {
+	var out []Instrument
 	for _, inst := range instruments {
-		if !inst.IsActive {
+		if !inst.IsActive || !time.UnixMilli(inst.ExpireMs).UTC().Equal(expiryUTC) {
 			continue
 		}
-		t := time.UnixMilli(inst.ExpireMs).UTC()
-		if !t.Equal(expiryUTC) {
+		if inst.Kind != "option" || inst.Strike <= 0 {
 			continue
 		}
-		parts := strings.Split(inst.Name, "-")
-		if len(parts) < 4 {
-			continue
-		}
-		var s float64
-		if _, err := fmtSscanf(parts[2], &s); err != nil {
-			continue
-		}
-		distance := math.Abs(s - atmPrice)
-		if distance > atmPrice*0.2 {
-			continue
-		}
-		isCall := parts[3] == "C"
-		list = append(list, opt{inst.Name, s, distance, isCall})
-	}
-	sort.Slice(list, func(i, j int) bool { return list[i].distance < list[j].distance })
-
-	if cap <= 0 {
-		cap = data.MaxOptions
-	}
-	callCap, putCap := cap/2, cap/2
-	callCount, putCount := 0, 0
-	balanced := make([]opt, 0, len(list))
-	for _, o := range list {
-		if o.isCall && callCount < callCap {
-			balanced = append(balanced, o)
-			callCount++
-		} else if !o.isCall && putCount < putCap {
-			balanced = append(balanced, o)
-			putCount++
-		}
-		if len(balanced) >= cap {
-			break
-		}
-	}
-	out := make([]string, len(balanced))
-	for i, o := range balanced {
-		out[i] = o.name
+		out = append(out, inst)
 	}
 	return out
 }
-
-// fmtSscanf: tiny wrapper to parse float without importing fmt in this file.
-// Allocation-free.
-func fmtSscanf(s string, out *float64) (int, error) {
-	v, err := strconv.ParseFloat(s, 64)
-	if err != nil {
-		return 0, err
-	}
-	*out = v
-	return 1, nil
-}
@@ -0,0 +1,211 @@
+package app
+
+import (
+	"Options_Hedger/internal/data"
+	"Options_Hedger/internal/fix"
+	"Options_Hedger/internal/positions"
+	"context"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UniverseRoller rebuilds the universe after each expiry and whenever the
+// index drifts too far from the selection center, then hot-swaps the
+// subscriptions and the strategy tables through the Handle.
+//
+// Env:
+//
+//	HEDGE_ROLL=0               disable the roller
+//	HEDGE_ROLL_DRIFT_PCT=0.05  re-center when |index-center|/center exceeds this
+//	HEDGE_ROLL_CHECK_SEC=60    drift check period
+//	HEDGE_ROLL_DELAY_SEC=60    wait after an expiry before rebuilding
+type UniverseRoller struct {
+	h          *Handle
+	current    Universe
+	driftPct   float64
+	checkEvery time.Duration
+	rollDelay  time.Duration
+}
+
+// NewUniverseRollerFromEnv returns nil when HEDGE_ROLL=0.
+func NewUniverseRollerFromEnv(h *Handle, u Universe) *UniverseRoller {
+	if strings.TrimSpace(os.Getenv("HEDGE_ROLL")) == "0" {
+		return nil
+	}
+	r := &UniverseRoller{
+		h:          h,
+		current:    u,
+		driftPct:   0.05,
+		checkEvery: 60 * time.Second,
+		rollDelay:  60 * time.Second,
+	}
+	if v := strings.TrimSpace(os.Getenv("HEDGE_ROLL_DRIFT_PCT")); v != "" {
+		if x, err := strconv.ParseFloat(v, 64); err == nil && x > 0 {
+			r.driftPct = x
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("HEDGE_ROLL_CHECK_SEC")); v != "" {
+		if x, err := strconv.Atoi(v); err == nil && x > 0 {
+			r.checkEvery = time.Duration(x) * time.Second
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("HEDGE_ROLL_DELAY_SEC")); v != "" {
+		if x, err := strconv.Atoi(v); err == nil && x >= 0 {
+			r.rollDelay = time.Duration(x) * time.Second
+		}
+	}
+	return r
+}
+
+// Run blocks until ctx is done.
+func (r *UniverseRoller) Run(ctx context.Context) {
+	log.Printf("[ROLL] started (drift=%.2f%% check=%s delay=%s)", r.driftPct*100, r.checkEvery, r.rollDelay)
+	tick := time.NewTicker(r.checkEvery)
+	defer tick.Stop()
+	expiry := time.NewTimer(r.untilNextExpiry())
+	defer expiry.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-expiry.C:
+			r.roll("expiry")
+			expiry.Reset(r.untilNextExpiry())
+		case <-tick.C:
+			S := data.GetIndexPrice()
+			c := r.current.Center
+			if S <= 0 || c <= 0 {
+				continue
+			}
+			if drift := math.Abs(S-c) / c; drift > r.driftPct {
+				log.Printf("[ROLL] index %.2f drifted %.2f%% from center %.2f", S, drift*100, c)
+				r.roll("drift")
+				expiry.Reset(r.untilNextExpiry())
+			}
+		}
+	}
+}
+
+// untilNextExpiry returns the wait until the nearest selected expiry
+// (plus rollDelay); an expiry already past rolls after rollDelay.
+func (r *UniverseRoller) untilNextExpiry() time.Duration {
+	next := r.current.NearExpiry
+	if next.IsZero() {
+		next = r.current.FarExpiry
+	}
+	if next.IsZero() {
+		return 24 * time.Hour
+	}
+	d := time.Until(next) + r.rollDelay
+	if d < r.rollDelay {
+		d = r.rollDelay
+	}
+	return d
+}
+
+// roll rebuilds the universe around the live index and applies the diff.
+// On failure the current universe is kept and retried on the next trigger.
+func (r *UniverseRoller) roll(reason string) {
+	S := data.GetIndexPrice()
+	if S <= 0 {
+		S = fetchBTCPrice()
+	}
+	next, nearLbl, farLbl, err := buildUniverseAt(S)
+	if err != nil {
+		log.Printf("[ROLL] rebuild (%s) failed, keeping current universe: %v", reason, err)
+		return
+	}
+	if len(next.Symbols) == 0 {
+		log.Printf("[ROLL] rebuild (%s) selected no options, keeping current universe", reason)
+		return
+	}
+
+	add, remove := diffSymbols(r.current.Symbols, next.Symbols)
+	// Symbols with a position or a working order stay subscribed until flat
+	if busy := busySymbols(); len(busy) > 0 {
+		keep := remove[:0]
+		for _, s := range remove {
+			if busy[s] {
+				next.Symbols = append(next.Symbols, s)
+			} else {
+				keep = append(keep, s)
+			}
+		}
+		if n := len(remove) - len(keep); n > 0 {
+			log.Printf("[ROLL] keeping %d dropped instruments with a position or live order", n)
+		}
+		remove = keep
+	}
+	// Remove first so freed slots are available to the new symbols
+	if len(remove) > 0 {
+		r.h.RemoveInstruments(remove)
+	}
+	if len(add) > 0 {
+		if err := r.h.AddInstruments(add); err != nil {
+			log.Printf("[ROLL] %v", err)
+			// Leave failed symbols out of the current set so the next roll retries them
+			next.Symbols = subscribedOnly(next.Symbols)
+		}
+	}
+	r.current = next
+	log.Printf("[ROLL] %s: center=%.2f near=%s far=%s (+%d / -%d, total %d)",
+		reason, next.Center, nearLbl, farLbl, len(add), len(remove), len(next.Symbols))
+}
+
+// busySymbols returns the instruments with an open position or a live order.
+func busySymbols() map[string]bool {
+	busy := make(map[string]bool)
+	for _, p := range positions.All() {
+		busy[p.Symbol] = true
+	}
+	for _, o := range fix.OpenOrders() {
+		if len(o.Legs) == 0 {
+			busy[o.Symbol] = true
+		}
+		for _, l := range o.Legs {
+			busy[l.Symbol] = true
+		}
+	}
+	return busy
+}
+
+// subscribedOnly filters symbols down to those with a live subscription.
+func subscribedOnly(symbols []string) []string {
+	live := make(map[string]bool)
+	for _, s := range fix.SubscribedSymbols() {
+		live[s] = true
+	}
+	out := symbols[:0]
+	for _, s := range symbols {
+		if live[s] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// diffSymbols returns symbols only in next (add) and only in cur (remove).
+func diffSymbols(cur, next []string) (add, remove []string) {
+	in := make(map[string]struct{}, len(cur))
+	for _, s := range cur {
+		in[s] = struct{}{}
+	}
+	for _, s := range next {
+		if _, ok := in[s]; ok {
+			delete(in, s)
+			continue
+		}
+		add = append(add, s)
+	}
+	for _, s := range cur {
+		if _, ok := in[s]; ok {
+			remove = append(remove, s)
+		}
+	}
+	return add, remove
+}
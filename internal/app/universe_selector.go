@@ -0,0 +1,363 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UniverseSelector ranks the option chain of one expiry. Capping (balanced
+// or matched call/put pairs) is applied afterwards by BuildUniverse.
+type UniverseSelector interface {
+	Name() string
+	// Rank returns the eligible instruments of chain, best first.
+	Rank(chain []Instrument, env SelectEnv) []Instrument
+}
+
+// SelectEnv is the market context passed to selectors.
+type SelectEnv struct {
+	Index   float64
+	Now     time.Time
+	Summary map[string]BookSummary // filled only for selectors that need it
+}
+
+// summaryUser is implemented by selectors that need get_book_summary data.
+type summaryUser interface{ needsSummary() bool }
+
+// allExpiries is implemented by selectors that ignore the expiry window.
+type allExpiries interface{ allExpiries() bool }
+
+// BookSummary is the subset of public/get_book_summary_by_currency we use.
+type BookSummary struct {
+	Name            string  `json:"instrument_name"`
+	MarkIV          float64 `json:"mark_iv"` // percent
+	OpenInterest    float64 `json:"open_interest"`
+	Volume          float64 `json:"volume"`
+	UnderlyingPrice float64 `json:"underlying_price"`
+}
+
+// ── moneyness band ──────────────────────────────────────────────────────────
+
+// MoneynessSelector keeps strikes within ±BandPct of the index, nearest first.
+type MoneynessSelector struct{ BandPct float64 }
+
+func (s MoneynessSelector) Name() string { return fmt.Sprintf("moneyness(±%.0f%%)", s.BandPct*100) }
+
+func (s MoneynessSelector) Rank(chain []Instrument, env SelectEnv) []Instrument {
+	out := inBand(chain, env.Index, s.BandPct)
+	sort.SliceStable(out, func(i, j int) bool {
+		return math.Abs(out[i].Strike-env.Index) < math.Abs(out[j].Strike-env.Index)
+	})
+	return out
+}
+
+// ── delta band ──────────────────────────────────────────────────────────────
+
+// DeltaSelector keeps options whose |delta| (Black-76 on mark_iv) lies in
+// [Min, Max], closest to 0.5 first.
+type DeltaSelector struct{ Min, Max float64 }
+
+func (s DeltaSelector) Name() string       { return fmt.Sprintf("delta[%.2f,%.2f]", s.Min, s.Max) }
+func (s DeltaSelector) needsSummary() bool { return true }
+
+func (s DeltaSelector) Rank(chain []Instrument, env SelectEnv) []Instrument {
+	type scored struct {
+		inst Instrument
+		dist float64
+	}
+	var list []scored
+	for _, inst := range chain {
+		sum, ok := env.Summary[inst.Name]
+		if !ok || sum.MarkIV <= 0 {
+			continue
+		}
+		F := sum.UnderlyingPrice
+		if F <= 0 {
+			F = env.Index
+		}
+		T := time.UnixMilli(inst.ExpireMs).Sub(env.Now).Hours() / (24 * 365)
+		d := math.Abs(forwardDelta(F, inst.Strike, sum.MarkIV/100, T, inst.IsCall()))
+		if d < s.Min || d > s.Max {
+			continue
+		}
+		list = append(list, scored{inst, math.Abs(d - 0.5)})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].dist < list[j].dist })
+	out := make([]Instrument, len(list))
+	for i, x := range list {
+		out[i] = x.inst
+	}
+	return out
+}
+
+// forwardDelta is the undiscounted Black-76 delta.
+func forwardDelta(F, K, vol, T float64, isCall bool) float64 {
+	if F <= 0 || K <= 0 || vol <= 0 || T <= 0 {
+		if isCall == (F > K) {
+			if isCall {
+				return 1
+			}
+			return -1
+		}
+		return 0
+	}
+	d1 := (math.Log(F/K) + 0.5*vol*vol*T) / (vol * math.Sqrt(T))
+	nd1 := 0.5 * math.Erfc(-d1/math.Sqrt2)
+	if isCall {
+		return nd1
+	}
+	return nd1 - 1
+}
+
+// ── open interest / volume ranking ──────────────────────────────────────────
+
+// LiquiditySelector ranks by open interest ("oi") or 24h volume ("volume"),
+// optionally restricted to ±BandPct around the index (0 = no band).
+type LiquiditySelector struct {
+	By      string
+	BandPct float64
+}
+
+func (s LiquiditySelector) Name() string       { return "liquidity(" + s.By + ")" }
+func (s LiquiditySelector) needsSummary() bool { return true }
+
+func (s LiquiditySelector) Rank(chain []Instrument, env SelectEnv) []Instrument {
+	out := chain
+	if s.BandPct > 0 {
+		out = inBand(chain, env.Index, s.BandPct)
+	} else {
+		out = append([]Instrument(nil), chain...)
+	}
+	score := func(inst Instrument) float64 {
+		sum := env.Summary[inst.Name]
+		if s.By == "volume" {
+			return sum.Volume
+		}
+		return sum.OpenInterest
+	}
+	sort.SliceStable(out, func(i, j int) bool { return score(out[i]) > score(out[j]) })
+	return out
+}
+
+// ── explicit symbol list ────────────────────────────────────────────────────
+
+// FileSelector keeps only the symbols listed in a file (one per line, '#'
+// comments), in file order. The expiry window does not apply.
+type FileSelector struct {
+	Path  string
+	order map[string]int
+}
+
+// NewFileSelector loads the symbol list.
+func NewFileSelector(path string) (*FileSelector, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := &FileSelector{Path: path, order: make(map[string]int)}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+		if _, ok := s.order[line]; !ok {
+			s.order[line] = len(s.order)
+		}
+	}
+	return s, sc.Err()
+}
+
+func (s *FileSelector) Name() string      { return "file(" + s.Path + ")" }
+func (s *FileSelector) allExpiries() bool { return true }
+
+func (s *FileSelector) Rank(chain []Instrument, _ SelectEnv) []Instrument {
+	var out []Instrument
+	for _, inst := range chain {
+		if _, ok := s.order[inst.Name]; ok {
+			out = append(out, inst)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return s.order[out[i].Name] < s.order[out[j].Name] })
+	return out
+}
+
+// ── config ──────────────────────────────────────────────────────────────────
+
+// UniverseConfig describes how BuildUniverse picks expiries and strikes.
+//
+// Env:
+//
+//	HEDGE_UNIVERSE_POLICY=moneyness|delta|liquidity|file  (default moneyness)
+//	HEDGE_UNIVERSE_EXPIRIES=nearfar|all                   (default nearfar; all = every expiry within HEDGE_EM_MAX_DAYS)
+//	HEDGE_UNIVERSE_PAIRED=1                               (default 1: only strikes with both call and put)
+//	HEDGE_UNIVERSE_BAND_PCT=0.20                          (moneyness / liquidity band)
+//	HEDGE_UNIVERSE_DELTA_MIN=0.10, HEDGE_UNIVERSE_DELTA_MAX=0.90
+//	HEDGE_UNIVERSE_RANK_BY=oi|volume
+//	HEDGE_UNIVERSE_FILE=path                              (file policy)
+type UniverseConfig struct {
+	Selector    UniverseSelector
+	AllExpiries bool
+	Paired      bool
+	MaxDays     int
+	PerCap      int // per-expiry cap (0 = capacity / #expiries with candidates; file: capacity)
+}
+
+func universeConfigFromEnv() (UniverseConfig, error) {
+	cfg := UniverseConfig{
+		AllExpiries: strings.EqualFold(strings.TrimSpace(os.Getenv("HEDGE_UNIVERSE_EXPIRIES")), "all"),
+		Paired:      strings.TrimSpace(os.Getenv("HEDGE_UNIVERSE_PAIRED")) != "0",
+		MaxDays:     7,
+	}
+	if v := strings.TrimSpace(os.Getenv("HEDGE_EM_MAX_DAYS")); v != "" {
+		if x, err := strconv.Atoi(v); err == nil && x > 0 {
+			cfg.MaxDays = x
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("HEDGE_PER_EXPIRY_CAP")); v != "" {
+		if x, err := strconv.Atoi(v); err == nil && x > 0 {
+			cfg.PerCap = x
+		}
+	}
+	band := envFloat("HEDGE_UNIVERSE_BAND_PCT", 0.20)
+
+	switch p := strings.ToLower(strings.TrimSpace(os.Getenv("HEDGE_UNIVERSE_POLICY"))); p {
+	case "", "moneyness":
+		cfg.Selector = MoneynessSelector{BandPct: band}
+	case "delta":
+		cfg.Selector = DeltaSelector{
+			Min: envFloat("HEDGE_UNIVERSE_DELTA_MIN", 0.10),
+			Max: envFloat("HEDGE_UNIVERSE_DELTA_MAX", 0.90),
+		}
+	case "liquidity", "oi", "volume":
+		by := strings.ToLower(strings.TrimSpace(os.Getenv("HEDGE_UNIVERSE_RANK_BY")))
+		if p == "volume" || by == "volume" {
+			by = "volume"
+		} else {
+			by = "oi"
+		}
+		cfg.Selector = LiquiditySelector{By: by, BandPct: band}
+	case "file":
+		path := strings.TrimSpace(os.Getenv("HEDGE_UNIVERSE_FILE"))
+		if path == "" {
+			return cfg, fmt.Errorf("[UNIVERSE] policy=file requires HEDGE_UNIVERSE_FILE")
+		}
+		fs, err := NewFileSelector(path)
+		if err != nil {
+			return cfg, fmt.Errorf("[UNIVERSE] load %s: %w", path, err)
+		}
+		cfg.Selector = fs
+	default:
+		return cfg, fmt.Errorf("[UNIVERSE] unknown HEDGE_UNIVERSE_POLICY=%q", p)
+	}
+	return cfg, nil
+}
+
+func envFloat(key string, def float64) float64 {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if x, err := strconv.ParseFloat(v, 64); err == nil && x > 0 {
+			return x
+		}
+	}
+	return def
+}
+
+// ── helpers ─────────────────────────────────────────────────────────────────
+
+func inBand(chain []Instrument, S, band float64) []Instrument {
+	var out []Instrument
+	for _, inst := range chain {
+		if math.Abs(inst.Strike-S) <= S*band {
+			out = append(out, inst)
+		}
+	}
+	return out
+}
+
+// capBalanced takes ranked instruments up to cap, split evenly between calls
+// and puts (legacy behaviour; may leave strikes with a single leg).
+func capBalanced(ranked []Instrument, cap int) []string {
+	callCap, putCap := cap/2, cap/2
+	callCount, putCount := 0, 0
+	out := make([]string, 0, cap)
+	for _, inst := range ranked {
+		if inst.IsCall() && callCount < callCap {
+			out = append(out, inst.Name)
+			callCount++
+		} else if !inst.IsCall() && putCount < putCap {
+			out = append(out, inst.Name)
+			putCount++
+		}
+		if len(out) >= cap {
+			break
+		}
+	}
+	return out
+}
+
+// capPaired takes whole strikes (call + put, both ranked) in rank order of
+// their first leg, up to cap instruments.
+func capPaired(ranked []Instrument, cap int) []string {
+	type pair struct{ call, put string }
+	pairs := make(map[float64]*pair)
+	var order []float64
+	for _, inst := range ranked {
+		k := inst.Strike
+		p, ok := pairs[k]
+		if !ok {
+			p = &pair{}
+			pairs[k] = p
+			order = append(order, k)
+		}
+		if inst.IsCall() {
+			p.call = inst.Name
+		} else {
+			p.put = inst.Name
+		}
+	}
+	out := make([]string, 0, cap)
+	for _, k := range order {
+		p := pairs[k]
+		if p.call == "" || p.put == "" {
+			continue
+		}
+		if len(out)+2 > cap {
+			break
+		}
+		out = append(out, p.call, p.put)
+	}
+	return out
+}
+
+// fetchBookSummaries loads mark IV / OI / volume for all BTC options.
+func fetchBookSummaries() (map[string]BookSummary, error) {
+	res, err := http.Get("https://www.deribit.com/api/v2/public/get_book_summary_by_currency?currency=BTC&kind=option")
+	if err != nil {
+		return nil, fmt.Errorf("[SUMMARY] fetch failed: %w", err)
+	}
+	defer res.Body.Close()
+	var r struct {
+		Result []BookSummary `json:"result"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("[SUMMARY] decode failed: %w", err)
+	}
+	out := make(map[string]BookSummary, len(r.Result))
+	for _, s := range r.Result {
+		out[s.Name] = s
+	}
+	log.Printf("[INFO] Fetched %d book summaries", len(out))
+	return out, nil
+}
@@ -3,6 +3,8 @@ package auth
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -12,6 +14,18 @@ import (
 // It uses the provided client ID and secret with client_credentials grant type.
 // Returns the access token string, or terminates with log.Fatal on failure.
 func FetchJWTToken(clientID, clientSecret string) string {
+	token, err := FetchToken(clientID, clientSecret)
+	if err != nil && !errors.Is(err, ErrNoToken) {
+		log.Fatal(err)
+	}
+	return token
+}
+
+// ErrNoToken is returned when the auth response carries no access token.
+var ErrNoToken = errors.New("[AUTH] no access token")
+
+// FetchToken is FetchJWTToken for long-running callers: errors are returned.
+func FetchToken(clientID, clientSecret string) (string, error) {
 	url := "https://www.deribit.com/api/v2/public/auth"
 
 	payload := map[string]interface{}{
@@ -31,7 +45,7 @@ func FetchJWTToken(clientID, clientSecret string) string {
 
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
-		log.Fatal("[AUTH] Token request failed:", err)
+		return "", fmt.Errorf("[AUTH] Token request failed: %w", err)
 	}
 	defer res.Body.Close()
 
@@ -50,11 +64,14 @@ func FetchJWTToken(clientID, clientSecret string) string {
 		} `json:"result"`
 	}
 	if err := json.Unmarshal(rawBody, &r); err != nil {
-		log.Fatal("[AUTH] Decode failed:", err)
+		return "", fmt.Errorf("[AUTH] Decode failed: %w", err)
+	}
+	if r.Result.AccessToken == "" {
+		return "", fmt.Errorf("%w (status %d)", ErrNoToken, res.StatusCode)
 	}
 
 	// Debug: Log only the token value (optional)
 	// log.Println("[DEBUG] Access Token:", r.Result.AccessToken)
 
-	return r.Result.AccessToken
+	return r.Result.AccessToken, nil
 }
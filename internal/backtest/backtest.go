@@ -0,0 +1,350 @@
+// Package backtest replays recorder logs through the box strategy in event
+// time and simulates the execution of its signals. Nothing reads the wall
+// clock, so a run over the same files with the same settings is repeatable.
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"Options_Hedger/internal/data"
+	"Options_Hedger/internal/paper"
+	"Options_Hedger/internal/recorder"
+	"Options_Hedger/internal/strategy"
+)
+
+// Config for a backtest run. Fees use the paper trading model.
+//
+// Env:
+//
+//	HEDGE_BT_LATENCY_MS=5     (signal -> fill, in event time)
+//	HEDGE_BT_SLIP_TICKS=0     (adverse ticks per leg on every fill)
+//	HEDGE_PAPER_FEE=0.0003    (BTC per contract)
+//	HEDGE_PAPER_FEE_CAP=0.125 (fee cap as a fraction of the option price)
+//
+// Strategy thresholds are the live HEDGE_BOX_* settings.
+type Config struct {
+	Latency   time.Duration      `json:"latency_ns"`
+	SlipTicks float64            `json:"slip_ticks"`
+	Fee       float64            `json:"fee"`
+	FeeCap    float64            `json:"fee_cap"`
+	Params    strategy.BoxParams `json:"params"`
+}
+
+// ConfigFromEnv reads HEDGE_BT_*, HEDGE_PAPER_FEE* and HEDGE_BOX_* settings.
+func ConfigFromEnv() Config {
+	pc := paper.ConfigFromEnv()
+	return Config{
+		Latency:   time.Duration(envFloat("HEDGE_BT_LATENCY_MS", 5) * float64(time.Millisecond)),
+		SlipTicks: envFloat("HEDGE_BT_SLIP_TICKS", 0),
+		Fee:       pc.Fee,
+		FeeCap:    pc.FeeCap,
+		Params:    strategy.BoxParamsFromEnv(),
+	}
+}
+
+func envFloat(key string, def float64) float64 {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if x, err := strconv.ParseFloat(v, 64); err == nil && x >= 0 {
+			return x
+		}
+	}
+	return def
+}
+
+// defaultTick is used for instruments without a tick size (names parsed
+// from the recording rather than loaded from the exchange catalog).
+const defaultTick = 0.0001
+
+// pending is a signal waiting for its simulated fill.
+type pending struct {
+	sig strategy.BoxSignal
+	due int64
+}
+
+// Backtest holds the replay state. It drives the process-wide data tables,
+// so only one backtest can run per process.
+type Backtest struct {
+	cfg    Config
+	eng    *strategy.BoxSpreadHFT
+	now    int64   // event time of the record being replayed (unix ns)
+	slot   []int32 // recorded symbol index -> local index
+	dirty  bool    // symbol table changed: rebuild the engine's option table
+	inst   *pending
+	open   []openBox // filled boxes until expiry, for capital usage
+	report Report
+}
+
+type openBox struct {
+	expiry  int64
+	capital float64
+}
+
+// New returns a backtest with the given settings.
+func New(cfg Config) *Backtest {
+	b := &Backtest{cfg: cfg}
+	b.report.Config = cfg
+	b.report.boxes = make(map[string]*BoxStats)
+	return b
+}
+
+// Run replays the files in order and returns the report. A truncated last
+// record (recorder crash) ends that file; any other read error aborts.
+func (b *Backtest) Run(paths []string) (*Report, error) {
+	for _, p := range paths {
+		if err := b.replay(p); err != nil {
+			return nil, err
+		}
+	}
+	if b.inst != nil {
+		b.report.Expired++ // data ended before the order arrived
+		b.inst = nil
+	}
+	b.report.finish()
+	return &b.report, nil
+}
+
+func (b *Backtest) replay(path string) error {
+	rd, err := recorder.Open(path)
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+	b.report.Files = append(b.report.Files, path)
+	if b.eng == nil {
+		b.start(rd)
+	} else {
+		for i, name := range rd.Symbols {
+			b.bind(int32(i), name)
+		}
+	}
+
+	for {
+		rec, err := rd.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			log.Printf("[BT] %s: truncated last record ignored", path)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		b.step(rec)
+	}
+}
+
+// start sizes the data tables for the recording and builds the engine.
+func (b *Backtest) start(rd *recorder.Reader) {
+	if n := len(rd.Symbols); n > data.Capacity() {
+		data.SetCapacity(n)
+	}
+	names := append([]string(nil), rd.Symbols...)
+	data.InitOrderBooks(names, nil)
+	b.slot = make([]int32, len(names))
+	for i := range b.slot {
+		b.slot[i] = int32(i)
+	}
+
+	b.eng = strategy.NewBoxSpreadHFT(nil)
+	b.eng.SetParams(b.cfg.Params)
+	b.eng.SetClock(func() int64 { return b.now })
+	b.eng.InitializeHFT(names)
+	b.report.From = time.Unix(0, rd.Start).UTC()
+}
+
+// bind maps a recorded slot to name, allocating a local slot when needed.
+func (b *Backtest) bind(idx int32, name string) {
+	for int(idx) >= len(b.slot) {
+		b.slot = append(b.slot, -1)
+	}
+	local := b.slot[idx]
+	if local >= 0 && data.GetSymbolName(local) == name {
+		return
+	}
+	if local >= 0 {
+		data.RemoveSymbol(local)
+		b.slot[idx] = -1
+	}
+	if name != "" {
+		i, err := data.AddSymbol(name)
+		if err != nil {
+			log.Printf("[BT] %s not replayed: %v", name, err)
+			return
+		}
+		b.slot[idx] = i
+	}
+	b.dirty = true
+}
+
+// step advances event time to rec, settles the order due before it and
+// applies it to the book and the engine.
+func (b *Backtest) step(rec recorder.Record) {
+	if b.inst != nil && b.inst.due <= rec.Time {
+		b.now = b.inst.due
+		b.fill(b.inst.sig)
+		b.inst = nil
+	}
+	b.now = rec.Time
+	b.report.Records++
+	b.report.To = time.Unix(0, rec.Time).UTC()
+
+	switch rec.Kind {
+	case recorder.KindIndex:
+		data.SetIndexPrice(rec.Index)
+	case recorder.KindSymbol:
+		b.bind(rec.Idx, rec.Symbol)
+	case recorder.KindUpdate:
+		u := rec.Update
+		if int(u.SymbolIdx) >= len(b.slot) || b.slot[u.SymbolIdx] < 0 {
+			return
+		}
+		u.SymbolIdx = b.slot[u.SymbolIdx]
+		b.report.Updates++
+		data.ApplyRecordedFast(u)
+		data.SetSymbolStale(u.SymbolIdx, false) // a rebound slot is live from its first update
+		if b.dirty {
+			b.rebuild()
+		}
+		b.eng.Process(u)
+		b.drain()
+	}
+}
+
+// rebuild reloads the engine's option table from the local slots.
+func (b *Backtest) rebuild() {
+	n := data.GetSymbolCount()
+	names := make([]string, n)
+	for i := range names {
+		names[i] = data.GetSymbolName(int32(i))
+	}
+	b.eng.InitializeHFT(names)
+	b.dirty = false
+}
+
+// drain takes the waiting signals best first after each update. Like
+// BoxExecutor, one box is in flight at a time and signals taken meanwhile
+// are dropped.
+func (b *Backtest) drain() {
+	for {
+		sig, ok := b.eng.NextSignal()
+		if !ok {
+			return
+		}
+		b.report.Signals++
+		b.box(sig).Signals++
+		if b.inst != nil {
+			b.report.Skipped++
+			continue
+		}
+		b.inst = &pending{sig: sig, due: b.now + int64(b.cfg.Latency)}
+	}
+}
+
+// fill executes a box at the current touch: every leg pays SlipTicks ticks
+// and the size is capped by the displayed quantity of each leg.
+func (b *Backtest) fill(sig strategy.BoxSignal) {
+	idx := [4]int32{int32(sig.LowCallIdx), int32(sig.LowPutIdx), int32(sig.HighCallIdx), int32(sig.HighPutIdx)}
+	// Long box: +C_low, -P_low, -C_high, +P_high; short box is the mirror
+	buy := [4]bool{true, false, false, true}
+	if sig.Side == strategy.BoxShort {
+		buy = [4]bool{false, true, true, false}
+	}
+	depth := data.ReadDepth4Fast(int(idx[0]), int(idx[1]), int(idx[2]), int(idx[3]))
+
+	f := Fill{Time: time.Unix(0, b.now).UTC(), Side: sig.Side, LowStrike: sig.LowStrike, HighStrike: sig.HighStrike, SignalUSD: sig.Profit}
+	qty, lot := sig.Qty, 0.0
+	var px [4]float64
+	for i := range idx {
+		m, _ := data.InstrumentAt(idx[i])
+		tick := m.TickSize
+		if tick <= 0 {
+			tick = defaultTick
+		}
+		avail := 0.0
+		if buy[i] {
+			px[i], avail = depth[i].AskPrice+b.cfg.SlipTicks*tick, depth[i].AskQty
+			f.NetBTC += px[i]
+		} else {
+			px[i], avail = depth[i].BidPrice-b.cfg.SlipTicks*tick, depth[i].BidQty
+			f.NetBTC -= px[i]
+		}
+		if depth[i].AskPrice <= 0 || depth[i].BidPrice <= 0 || px[i] <= 0 || avail <= 0 {
+			b.report.Missed++
+			return
+		}
+		qty = math.Min(qty, avail)
+		lot = math.Max(lot, m.MinTradeAmount)
+		f.Expiry = time.UnixMilli(m.ExpiryMs).UTC()
+		f.Legs[i] = data.GetSymbolName(idx[i])
+	}
+	if lot > 0 {
+		qty = math.Floor(qty/lot+1e-9) * lot
+	}
+	if qty <= 0 {
+		b.report.Missed++
+		return
+	}
+
+	S := data.GetIndexPrice()
+	f.Qty, f.Index = qty, S
+	for i := range px {
+		f.FeesBTC += math.Min(b.cfg.Fee*qty, b.cfg.FeeCap*px[i]*qty)
+	}
+	dir := 1.0
+	if sig.Side == strategy.BoxShort {
+		dir = -1.0
+	}
+	face := (sig.HighStrike - sig.LowStrike) * qty
+	f.PnLUSD = dir*face - f.NetBTC*S*qty - f.FeesBTC*S
+	f.CapitalUSD = strategy.BoxCapitalUSD(sig.Side, face, (f.NetBTC*qty+f.FeesBTC)*S, b.cfg.Params.MarginRate)
+	if tte := f.Expiry.Sub(f.Time); tte > 0 && f.CapitalUSD > 0 {
+		f.Years = tte.Hours() / (24 * 365)
+		f.APR = f.PnLUSD / f.CapitalUSD / f.Years
+	}
+	b.hold(f.Expiry.UnixNano(), f.CapitalUSD)
+	b.report.add(f, b.box(sig))
+}
+
+// hold tracks capital tied up in filled boxes until their expiry.
+func (b *Backtest) hold(expiry int64, capital float64) {
+	open := b.open[:0]
+	var used float64
+	for _, o := range b.open {
+		if o.expiry > b.now {
+			open = append(open, o)
+			used += o.capital
+		}
+	}
+	b.open = append(open, openBox{expiry: expiry, capital: capital})
+	if used += capital; used > b.report.PeakCapitalUSD {
+		b.report.PeakCapitalUSD = used
+	}
+}
+
+// box returns the per-box stats of sig.
+func (b *Backtest) box(sig strategy.BoxSignal) *BoxStats {
+	label := ""
+	if m, ok := data.InstrumentAt(int32(sig.LowCallIdx)); ok {
+		label = m.ExpiryLabel()
+	}
+	side := "long"
+	if sig.Side == strategy.BoxShort {
+		side = "short"
+	}
+	key := fmt.Sprintf("%s %.0f/%.0f %s", label, sig.LowStrike, sig.HighStrike, side)
+	s, ok := b.report.boxes[key]
+	if !ok {
+		s = &BoxStats{Box: key}
+		b.report.boxes[key] = s
+	}
+	return s
+}
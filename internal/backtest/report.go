@@ -0,0 +1,131 @@
+package backtest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Fill is one simulated box execution. PnL is the value locked in at the
+// fill (face value at expiry less premium and fees, at the index then).
+type Fill struct {
+	Time       time.Time `json:"time"`
+	Expiry     time.Time `json:"expiry"`
+	Legs       [4]string `json:"legs"` // low call, low put, high call, high put
+	Side       int8      `json:"side"` // +1 long box, -1 short box
+	LowStrike  float64   `json:"low_strike"`
+	HighStrike float64   `json:"high_strike"`
+	Qty        float64   `json:"qty"`
+	NetBTC     float64   `json:"net_btc"` // paid per box: buys minus sells
+	Index      float64   `json:"index"`
+	FeesBTC    float64   `json:"fees_btc"`
+	PnLUSD     float64   `json:"pnl_usd"`
+	SignalUSD  float64   `json:"signal_usd"` // profit floor at detection
+	CapitalUSD float64   `json:"capital_usd"`
+	Years      float64   `json:"years"` // time to expiry
+	APR        float64   `json:"apr"`
+}
+
+// BoxStats aggregate the signals and fills of one box (expiry, strikes, side).
+type BoxStats struct {
+	Box        string  `json:"box"`
+	Signals    int     `json:"signals"`
+	Fills      int     `json:"fills"`
+	Qty        float64 `json:"qty"`
+	PnLUSD     float64 `json:"pnl_usd"`
+	FeesBTC    float64 `json:"fees_btc"`
+	CapitalUSD float64 `json:"capital_usd"`
+	APR        float64 `json:"apr"` // capital and time weighted
+
+	capYears float64
+}
+
+// Report is the outcome of a backtest run.
+type Report struct {
+	Config Config    `json:"config"`
+	Files  []string  `json:"files"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+
+	Records uint64 `json:"records"`
+	Updates uint64 `json:"updates"`
+	Signals int    `json:"signals"`
+	Skipped int    `json:"skipped"` // a box was already in flight
+	Missed  int    `json:"missed"`  // no quote or size left when the order arrived
+	Expired int    `json:"expired"` // data ended before the order arrived
+
+	Fills          int     `json:"fills"`
+	Qty            float64 `json:"qty"`
+	PnLUSD         float64 `json:"pnl_usd"`
+	FeesBTC        float64 `json:"fees_btc"`
+	FeesUSD        float64 `json:"fees_usd"`
+	CapitalUSD     float64 `json:"capital_usd"` // sum over fills
+	PeakCapitalUSD float64 `json:"peak_capital_usd"`
+	APR            float64 `json:"apr"` // capital and time weighted
+
+	Boxes  []*BoxStats `json:"boxes"` // by PnL, best first
+	Trades []Fill      `json:"trades"`
+
+	boxes    map[string]*BoxStats
+	capYears float64
+}
+
+func (r *Report) add(f Fill, s *BoxStats) {
+	r.Trades = append(r.Trades, f)
+	r.Fills++
+	r.Qty += f.Qty
+	r.PnLUSD += f.PnLUSD
+	r.FeesBTC += f.FeesBTC
+	r.FeesUSD += f.FeesBTC * f.Index
+	r.CapitalUSD += f.CapitalUSD
+	r.capYears += f.CapitalUSD * f.Years
+
+	s.Fills++
+	s.Qty += f.Qty
+	s.PnLUSD += f.PnLUSD
+	s.FeesBTC += f.FeesBTC
+	s.CapitalUSD += f.CapitalUSD
+	s.capYears += f.CapitalUSD * f.Years
+}
+
+// finish computes the yields and orders the boxes.
+func (r *Report) finish() {
+	if r.capYears > 0 {
+		r.APR = r.PnLUSD / r.capYears
+	}
+	r.Boxes = r.Boxes[:0]
+	for _, s := range r.boxes {
+		if s.capYears > 0 {
+			s.APR = s.PnLUSD / s.capYears
+		}
+		r.Boxes = append(r.Boxes, s)
+	}
+	sort.Slice(r.Boxes, func(i, j int) bool {
+		if r.Boxes[i].PnLUSD != r.Boxes[j].PnLUSD {
+			return r.Boxes[i].PnLUSD > r.Boxes[j].PnLUSD
+		}
+		return r.Boxes[i].Box < r.Boxes[j].Box
+	})
+}
+
+// Print writes a human-readable summary.
+func (r *Report) Print(w io.Writer) {
+	c := r.Config
+	fmt.Fprintf(w, "files    %d  %s → %s (%s)\n", len(r.Files), r.From.Format(time.RFC3339), r.To.Format(time.RFC3339), r.To.Sub(r.From).Round(time.Second))
+	fmt.Fprintf(w, "settings latency=%s slip=%g ticks min_profit=$%g gap=%g fee=%g cap=%g\n",
+		c.Latency, c.SlipTicks, c.Params.MinProfitUSD, c.Params.MinStrikeGap, c.Fee, c.FeeCap)
+	fmt.Fprintf(w, "replayed %d records, %d book updates\n", r.Records, r.Updates)
+	fmt.Fprintf(w, "signals  %d (skipped %d in flight, missed %d, expired %d)\n", r.Signals, r.Skipped, r.Missed, r.Expired)
+	fmt.Fprintf(w, "fills    %d  qty=%g\n", r.Fills, r.Qty)
+	fmt.Fprintf(w, "pnl      $%.2f  fees %.6f BTC ($%.2f)\n", r.PnLUSD, r.FeesBTC, r.FeesUSD)
+	fmt.Fprintf(w, "capital  $%.2f deployed, $%.2f peak  yield %.2f%% annualized\n", r.CapitalUSD, r.PeakCapitalUSD, r.APR*100)
+	if len(r.Boxes) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%-28s %7s %5s %8s %10s %10s %10s %8s\n", "box", "signals", "fills", "qty", "pnl $", "fees BTC", "capital $", "apr %")
+	for _, s := range r.Boxes {
+		fmt.Fprintf(w, "%-28s %7d %5d %8g %10.2f %10.6f %10.2f %8.2f\n",
+			s.Box, s.Signals, s.Fills, s.Qty, s.PnLUSD, s.FeesBTC, s.CapitalUSD, s.APR*100)
+	}
+}
@@ -0,0 +1,68 @@
+package data
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unsafe"
+)
+
+const (
+	// DefaultMaxOptions is the capacity used when HEDGE_MAX_OPTIONS is unset.
+	DefaultMaxOptions = 40
+	// HardMaxOptions bounds capacity so symbol indexes fit in int16 signals.
+	HardMaxOptions = 1 << 14
+)
+
+var maxOptions = DefaultMaxOptions
+
+func init() { ConfigureFromEnv() }
+
+// ConfigureFromEnv re-reads HEDGE_MAX_OPTIONS and DATA_BOOK_DEPTH and resizes
+// the tables. Call once after .env is loaded, before any other data setup.
+func ConfigureFromEnv() {
+	n := DefaultMaxOptions
+	if v := strings.TrimSpace(os.Getenv("HEDGE_MAX_OPTIONS")); v != "" {
+		if x, err := strconv.Atoi(v); err == nil && x > 0 {
+			n = x
+		}
+	}
+	bookDepth = loadBookDepth()
+	SetCapacity(n)
+}
+
+// Capacity returns the number of instrument slots chosen at startup.
+func Capacity() int { return maxOptions }
+
+// SetCapacity (re)allocates every per-instrument table for n instruments.
+// Startup only: call before InitSharedMemory, InitOrderBooks and any writer.
+// Existing depth/greeks contents are discarded; the index price is kept.
+func SetCapacity(n int) {
+	if n <= 0 {
+		n = DefaultMaxOptions
+	}
+	if n > HardMaxOptions {
+		n = HardMaxOptions
+	}
+	maxOptions = n
+	books = alignedEntries(n)
+	l2Books = make([]L2Book, n)
+	slotReset = make([]uint32, n)
+	greeksTab = make([]atomic.Value, n)
+	symbolNames = make([]string, n)
+	symbolStale = make([]uint32, n)
+	symbolHeld = make([]uint32, n)
+	instMeta = make([]atomic.Pointer[InstrumentMeta], n)
+	atomic.StoreInt32(&symbolCount, 0)
+}
+
+// alignedEntries returns n DepthEntry slots starting on a cache-line boundary.
+func alignedEntries(n int) []DepthEntry {
+	buf := make([]byte, (n+1)*cacheLine)
+	off := 0
+	if rem := int(uintptr(unsafe.Pointer(&buf[0])) % cacheLine); rem != 0 {
+		off = cacheLine - rem
+	}
+	return unsafe.Slice((*DepthEntry)(unsafe.Pointer(&buf[off])), n)
+}
@@ -5,15 +5,20 @@ import "sync/atomic"
 
 // 옵션 1계약당 그릭스 (USD/일 단위: Theta 등)
 type Greeks struct {
-	Delta float64
-	Gamma float64
-	Theta float64
-	Vega  float64
-	Rho   float64
-	TsMs  int64 // 수신 시각(ms)
+	Delta    float64
+	DeltaInv float64 // BTC 정산 델타 (Delta - BTC 프리미엄)
+	Gamma    float64
+	Theta    float64
+	Vega     float64 // 변동성 1%p 당
+	Rho      float64 // 금리 1% 당
+	MarkIV   float64 // mid 기준 내재변동성 (연율, 0 = 없음)
+	BidIV    float64
+	AskIV    float64
+	Forward  float64 // 계산에 쓴 기초자산 가격 (USD)
+	TsMs     int64   // 계산 시각(ms)
 }
 
-var greeksTab [MaxOptions]atomic.Value // 각 인덱스에 최신 그릭스 저장
+var greeksTab []atomic.Value // 각 인덱스에 최신 그릭스 저장
 
 // 빠른 쓰기 (WS 수신 루틴에서 호출)
 func WriteGreeksFast(idx int, g Greeks) {
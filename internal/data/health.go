@@ -0,0 +1,56 @@
+package data
+
+import "sync/atomic"
+
+// Per-symbol market data health, written by the FIX market data supervisor
+// and read by strategies before trusting a quote. A symbol is live until the
+// supervisor marks it stale; the next snapshot/update makes it live again.
+var symbolStale []uint32 // 1 = stale, by symbol index
+
+// A freed slot is also held until the strategy has dropped it from its own
+// tables, so a symbol reusing the slot cannot be traded with the old
+// symbol's strike/type/expiry. Data does not lift the hold; the strategy does.
+var symbolHeld []uint32 // 1 = held, by symbol index
+
+// SetSymbolStale marks a symbol stale (true) or live (false).
+func SetSymbolStale(idx int32, stale bool) {
+	if idx < 0 || int(idx) >= len(symbolStale) {
+		return
+	}
+	var v uint32
+	if stale {
+		v = 1
+	}
+	atomic.StoreUint32(&symbolStale[idx], v)
+}
+
+// SymbolLive reports whether a symbol's market data is currently trusted.
+func SymbolLive(idx int32) bool {
+	if idx < 0 || int(idx) >= len(symbolStale) {
+		return false
+	}
+	return atomic.LoadUint32(&symbolStale[idx]) == 0 && atomic.LoadUint32(&symbolHeld[idx]) == 0
+}
+
+// HoldSymbol keeps a slot from being live until ReleaseSymbol.
+func HoldSymbol(idx int32) {
+	if idx < 0 || int(idx) >= len(symbolHeld) {
+		return
+	}
+	atomic.StoreUint32(&symbolHeld[idx], 1)
+}
+
+// ReleaseSymbol lifts the hold of a slot once its consumer caught up.
+func ReleaseSymbol(idx int32) {
+	if idx < 0 || int(idx) >= len(symbolHeld) {
+		return
+	}
+	atomic.StoreUint32(&symbolHeld[idx], 0)
+}
+
+// SetAllSymbolsStale marks every slot stale (e.g. on FIX logout).
+func SetAllSymbolsStale() {
+	for i := range symbolStale {
+		atomic.StoreUint32(&symbolStale[i], 1)
+	}
+}
@@ -9,8 +9,13 @@ import (
 
 var atomicIndexPrice uint64
 
+// InitSharedMemory is a no-op outside Linux: SharedBook stays on the Go heap.
 func InitSharedMemory() error { return nil }
 
+func writeSymbolTable(names []string) {}
+
+func publishSymbol(idx int32, name string, count int32) {}
+
 func WriteIndexPrice(v float64) {
 	atomic.StoreUint64(&atomicIndexPrice, math.Float64bits(v))
 }
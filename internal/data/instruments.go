@@ -0,0 +1,178 @@
+package data
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OptionType of an instrument.
+type OptionType uint8
+
+const (
+	NotOption OptionType = iota
+	Call
+	Put
+)
+
+// InstrumentMeta is exchange metadata from public/get_instruments.
+type InstrumentMeta struct {
+	Name               string
+	Kind               string // "option", "future", ...
+	OptionType         OptionType
+	Strike             float64
+	ExpiryMs           int64 // expiration_timestamp (08:00 UTC on Deribit)
+	TickSize           float64
+	MinTradeAmount     float64
+	ContractSize       float64
+	SettlementCurrency string
+	BaseCurrency       string
+}
+
+// Expiry returns the expiration time in UTC.
+func (m InstrumentMeta) Expiry() time.Time { return time.UnixMilli(m.ExpiryMs).UTC() }
+
+// IsCall reports whether the instrument is a call option.
+func (m InstrumentMeta) IsCall() bool { return m.OptionType == Call }
+
+// ExpiryLabel returns the Deribit expiry code (e.g. "27SEP25") of ExpiryMs.
+func (m InstrumentMeta) ExpiryLabel() string {
+	if m.ExpiryMs <= 0 {
+		return ""
+	}
+	return strings.ToUpper(m.Expiry().Format("2Jan06"))
+}
+
+// Registry: the catalog holds every instrument fetched from the exchange,
+// instMeta resolves subscribed symbol indexes to it (lock-free reads).
+var (
+	catalogMu sync.RWMutex
+	catalog   = map[string]*InstrumentMeta{}
+	instMeta  []atomic.Pointer[InstrumentMeta] // by symbol index
+)
+
+// LoadInstrumentCatalog replaces the catalog and re-resolves subscribed slots.
+func LoadInstrumentCatalog(metas []InstrumentMeta) {
+	m := make(map[string]*InstrumentMeta, len(metas))
+	for i := range metas {
+		mm := metas[i]
+		m[mm.Name] = &mm
+	}
+	catalogMu.Lock()
+	catalog = m
+	catalogMu.Unlock()
+
+	n := atomic.LoadInt32(&symbolCount)
+	symMu.RLock()
+	for i := int32(0); i < n; i++ {
+		resolveMeta(i, symbolNames[i])
+	}
+	symMu.RUnlock()
+}
+
+// InstrumentByName returns catalog metadata for a symbol.
+func InstrumentByName(name string) (InstrumentMeta, bool) {
+	catalogMu.RLock()
+	m, ok := catalog[name]
+	catalogMu.RUnlock()
+	if !ok {
+		return InstrumentMeta{}, false
+	}
+	return *m, true
+}
+
+// InstrumentAt returns metadata of a subscribed symbol index.
+func InstrumentAt(idx int32) (InstrumentMeta, bool) {
+	if idx < 0 || int(idx) >= len(instMeta) {
+		return InstrumentMeta{}, false
+	}
+	m := instMeta[idx].Load()
+	if m == nil {
+		return InstrumentMeta{}, false
+	}
+	return *m, true
+}
+
+// ResolveInstrument returns catalog metadata for name, falling back to
+// parsing the name when the catalog does not list it.
+func ResolveInstrument(name string) (InstrumentMeta, bool) {
+	if m, ok := InstrumentByName(name); ok {
+		return m, true
+	}
+	return ParseInstrumentName(name)
+}
+
+// resolveMeta binds a slot to catalog metadata. Symbols missing from the
+// catalog (e.g. replayed data) fall back to parsing the Deribit name.
+func resolveMeta(idx int32, name string) {
+	if int(idx) >= len(instMeta) {
+		return
+	}
+	if name == "" {
+		instMeta[idx].Store(nil)
+		return
+	}
+	if m, ok := ResolveInstrument(name); ok {
+		instMeta[idx].Store(&m)
+	} else {
+		instMeta[idx].Store(nil)
+	}
+}
+
+// ParseInstrumentName derives option metadata from a Deribit name
+// (UNDERLYING-DMMMYY-STRIKE-C|P, expiry at 08:00 UTC). Exchange fields
+// such as tick size are left zero.
+func ParseInstrumentName(name string) (InstrumentMeta, bool) {
+	parts := strings.Split(name, "-")
+	if len(parts) != 4 {
+		return InstrumentMeta{}, false
+	}
+	strike, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return InstrumentMeta{}, false
+	}
+	exp, ok := parseExpiryLabel(parts[1])
+	if !ok {
+		return InstrumentMeta{}, false
+	}
+	m := InstrumentMeta{
+		Name:               name,
+		Kind:               "option",
+		Strike:             strike,
+		ExpiryMs:           exp.UnixMilli(),
+		BaseCurrency:       parts[0],
+		SettlementCurrency: parts[0],
+	}
+	switch parts[3] {
+	case "C":
+		m.OptionType = Call
+	case "P":
+		m.OptionType = Put
+	default:
+		return InstrumentMeta{}, false
+	}
+	return m, true
+}
+
+var monthCodes = map[string]time.Month{
+	"JAN": time.January, "FEB": time.February, "MAR": time.March, "APR": time.April,
+	"MAY": time.May, "JUN": time.June, "JUL": time.July, "AUG": time.August,
+	"SEP": time.September, "OCT": time.October, "NOV": time.November, "DEC": time.December,
+}
+
+// parseExpiryLabel parses "5SEP25" / "30AUG24" into 08:00 UTC of that day.
+func parseExpiryLabel(s string) (time.Time, bool) {
+	if len(s) < 6 || len(s) > 7 {
+		return time.Time{}, false
+	}
+	dayLen := len(s) - 5
+	day, err1 := strconv.Atoi(s[:dayLen])
+	mon, ok := monthCodes[strings.ToUpper(s[dayLen:dayLen+3])]
+	yy, err2 := strconv.Atoi(s[len(s)-2:])
+	if err1 != nil || err2 != nil || !ok {
+		return time.Time{}, false
+	}
+	return time.Date(2000+yy, mon, day, 8, 0, 0, 0, time.UTC), true
+}
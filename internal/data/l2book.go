@@ -0,0 +1,205 @@
+package data
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// MaxBookDepth is the hard cap of price levels kept per side.
+const MaxBookDepth = 32
+
+// MDUpdateAction values (FIX tag 279).
+const (
+	ActionNew    byte = '0'
+	ActionChange byte = '1'
+	ActionDelete byte = '2'
+)
+
+// Level is a single aggregated price level.
+type Level struct {
+	Price float64
+	Qty   float64
+}
+
+// L2Book keeps sorted price levels for one instrument.
+// Bids are sorted descending, asks ascending; index 0 is the top of book.
+// Owned by the FIX receive goroutine (single writer), no locking.
+type L2Book struct {
+	Bids  [MaxBookDepth]Level
+	Asks  [MaxBookDepth]Level
+	NBids int32
+	NAsks int32
+}
+
+var (
+	l2Books   []L2Book // by symbol index
+	bookDepth int32    // levels kept per side, see ConfigureFromEnv
+	slotReset []uint32 // 1 = clear the slot before its next write
+)
+
+// markReset asks the book writer to clear a slot. AddSymbol/RemoveSymbol run
+// on other goroutines, so they never touch the book themselves.
+func markReset(idx int32) { atomic.StoreUint32(&slotReset[idx], 1) }
+
+// claimReset clears a flagged slot's L2 book and top of book. Called by the
+// writer before it writes the slot.
+func claimReset(idx int32) {
+	if atomic.LoadUint32(&slotReset[idx]) == 0 || atomic.SwapUint32(&slotReset[idx], 0) == 0 {
+		return
+	}
+	l2Books[idx].Reset()
+	WriteDepthFast(int(idx), 0, 0, 0, 0)
+}
+
+// loadBookDepth reads DATA_BOOK_DEPTH (default 10, capped at MaxBookDepth).
+func loadBookDepth() int32 {
+	d := 10
+	if v := strings.TrimSpace(os.Getenv("DATA_BOOK_DEPTH")); v != "" {
+		if x, err := strconv.Atoi(v); err == nil && x > 0 {
+			d = x
+		}
+	}
+	if d > MaxBookDepth {
+		d = MaxBookDepth
+	}
+	return int32(d)
+}
+
+// BookDepth returns the configured number of levels kept per side.
+func BookDepth() int { return int(bookDepth) }
+
+// Reset clears both sides (used before applying a 35=W snapshot).
+func (b *L2Book) Reset() {
+	b.NBids = 0
+	b.NAsks = 0
+}
+
+// Apply applies one MDUpdateAction to a price level.
+// New and Change both upsert the level; a non-positive qty or Delete removes it.
+func (b *L2Book) Apply(isBid bool, action byte, price, qty float64) {
+	if price <= 0 {
+		return
+	}
+	levels, n := &b.Asks, &b.NAsks
+	if isBid {
+		levels, n = &b.Bids, &b.NBids
+	}
+	if action == ActionDelete || qty <= 0 {
+		removeLevel(levels, n, isBid, price)
+		return
+	}
+	upsertLevel(levels, n, isBid, price, qty)
+}
+
+// Best returns the top level of one side (zero Level if empty).
+func (b *L2Book) Best(isBid bool) Level {
+	if isBid {
+		if b.NBids > 0 {
+			return b.Bids[0]
+		}
+		return Level{}
+	}
+	if b.NAsks > 0 {
+		return b.Asks[0]
+	}
+	return Level{}
+}
+
+// better reports whether price a ranks ahead of price b on the given side.
+func better(isBid bool, a, b float64) bool {
+	if isBid {
+		return a > b
+	}
+	return a < b
+}
+
+func upsertLevel(levels *[MaxBookDepth]Level, n *int32, isBid bool, price, qty float64) {
+	cnt := *n
+	pos := int32(0)
+	for pos < cnt && better(isBid, levels[pos].Price, price) {
+		pos++
+	}
+	if pos < cnt && levels[pos].Price == price {
+		levels[pos].Qty = qty
+		return
+	}
+	if pos >= bookDepth {
+		return // beyond tracked depth
+	}
+	if cnt >= bookDepth {
+		cnt = bookDepth - 1 // drop the worst level
+	}
+	copy(levels[pos+1:cnt+1], levels[pos:cnt])
+	levels[pos] = Level{Price: price, Qty: qty}
+	*n = cnt + 1
+}
+
+func removeLevel(levels *[MaxBookDepth]Level, n *int32, isBid bool, price float64) {
+	cnt := *n
+	for i := int32(0); i < cnt; i++ {
+		if levels[i].Price == price {
+			copy(levels[i:cnt-1], levels[i+1:cnt])
+			levels[cnt-1] = Level{}
+			*n = cnt - 1
+			return
+		}
+		if better(isBid, price, levels[i].Price) {
+			return // sorted: price is not in the book
+		}
+	}
+}
+
+// ResetBookFast clears the L2 book of a symbol (call before a snapshot).
+func ResetBookFast(symbolIdx int32) {
+	if !validIdx(symbolIdx) {
+		return
+	}
+	claimReset(symbolIdx)
+	l2Books[symbolIdx].Reset()
+}
+
+// ApplyLevelFast applies one MD entry to the L2 book of a symbol.
+// Call PublishBookFast once the whole message has been applied.
+func ApplyLevelFast(symbolIdx int32, isBid bool, action byte, price, qty float64) {
+	if !validIdx(symbolIdx) {
+		return
+	}
+	claimReset(symbolIdx)
+	l2Books[symbolIdx].Apply(isBid, action, price, qty)
+}
+
+// PublishBookFast derives the best bid/ask from the L2 book and writes the
+// touched sides to the shared top-of-book (an empty side is written as 0/0).
+// Both sides of one message go out in a single write and a single Update.
+func PublishBookFast(symbolIdx int32, bidTouched, askTouched bool, idxPrice float64) {
+	if !validIdx(symbolIdx) {
+		return
+	}
+	b := &l2Books[symbolIdx]
+	switch {
+	case bidTouched && askTouched:
+		bid, ask := b.Best(true), b.Best(false)
+		ApplyQuoteFast(symbolIdx, bid.Price, bid.Qty, ask.Price, ask.Qty, idxPrice)
+	case bidTouched:
+		top := b.Best(true)
+		ApplyUpdateFast(symbolIdx, true, top.Price, top.Qty, idxPrice)
+	case askTouched:
+		top := b.Best(false)
+		ApplyUpdateFast(symbolIdx, false, top.Price, top.Qty, idxPrice)
+	}
+}
+
+// CopyBookLevels copies up to len(dst) levels of one side into dst and
+// returns the count. Must be called from the FIX receive goroutine.
+func CopyBookLevels(symbolIdx int32, isBid bool, dst []Level) int {
+	if !validIdx(symbolIdx) {
+		return 0
+	}
+	b := &l2Books[symbolIdx]
+	if isBid {
+		return copy(dst, b.Bids[:b.NBids])
+	}
+	return copy(dst, b.Asks[:b.NAsks])
+}
@@ -0,0 +1,151 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+type levelOp struct {
+	bid    bool
+	action byte
+	price  float64
+	qty    float64
+}
+
+func TestL2BookApply(t *testing.T) {
+	tests := []struct {
+		name       string
+		depth      int32
+		ops        []levelOp
+		bids, asks []Level
+	}{
+		{
+			name: "new levels sort by side",
+			ops: []levelOp{
+				{true, ActionNew, 0.010, 1}, {true, ActionNew, 0.012, 2}, {true, ActionNew, 0.011, 3},
+				{false, ActionNew, 0.015, 1}, {false, ActionNew, 0.013, 2}, {false, ActionNew, 0.014, 3},
+			},
+			bids: []Level{{0.012, 2}, {0.011, 3}, {0.010, 1}},
+			asks: []Level{{0.013, 2}, {0.014, 3}, {0.015, 1}},
+		},
+		{
+			name: "change updates qty in place",
+			ops:  []levelOp{{true, ActionNew, 0.010, 1}, {true, ActionNew, 0.011, 1}, {true, ActionChange, 0.010, 5}},
+			bids: []Level{{0.011, 1}, {0.010, 5}},
+		},
+		{
+			name: "change of a missing level inserts it",
+			ops:  []levelOp{{false, ActionNew, 0.020, 1}, {false, ActionChange, 0.019, 4}},
+			asks: []Level{{0.019, 4}, {0.020, 1}},
+		},
+		{
+			name: "delete removes the level",
+			ops:  []levelOp{{true, ActionNew, 0.010, 1}, {true, ActionNew, 0.011, 1}, {true, ActionDelete, 0.011, 0}},
+			bids: []Level{{0.010, 1}},
+		},
+		{
+			name: "delete of a missing level is a no-op",
+			ops: []levelOp{
+				{true, ActionNew, 0.010, 1}, {true, ActionDelete, 0.012, 0}, {true, ActionDelete, 0.009, 0},
+				{false, ActionDelete, 0.030, 0},
+			},
+			bids: []Level{{0.010, 1}},
+		},
+		{
+			name: "zero qty removes the level",
+			ops:  []levelOp{{false, ActionNew, 0.020, 1}, {false, ActionChange, 0.020, 0}},
+		},
+		{
+			name: "non-positive price is ignored",
+			ops:  []levelOp{{true, ActionNew, 0, 1}, {true, ActionNew, -1, 1}},
+		},
+		{
+			name:  "depth cap drops the worst level",
+			depth: 2,
+			ops:   []levelOp{{true, ActionNew, 0.010, 1}, {true, ActionNew, 0.011, 1}, {true, ActionNew, 0.012, 1}, {true, ActionNew, 0.009, 1}},
+			bids:  []Level{{0.012, 1}, {0.011, 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.depth > 0 {
+				defer func(d int32) { bookDepth = d }(bookDepth)
+				bookDepth = tt.depth
+			}
+			var b L2Book
+			for _, op := range tt.ops {
+				b.Apply(op.bid, op.action, op.price, op.qty)
+			}
+			if got := append([]Level{}, b.Bids[:b.NBids]...); !reflect.DeepEqual(got, append([]Level{}, tt.bids...)) {
+				t.Errorf("bids = %v, want %v", got, tt.bids)
+			}
+			if got := append([]Level{}, b.Asks[:b.NAsks]...); !reflect.DeepEqual(got, append([]Level{}, tt.asks...)) {
+				t.Errorf("asks = %v, want %v", got, tt.asks)
+			}
+		})
+	}
+}
+
+func TestReusedSlotStartsEmpty(t *testing.T) {
+	defer SetCapacity(Capacity())
+	SetCapacity(4)
+	InitOrderBooks([]string{"BTC-A", "BTC-B"}, nil)
+
+	ApplyLevelFast(1, true, ActionNew, 0.010, 1)
+	ApplyLevelFast(1, false, ActionNew, 0.012, 2)
+	PublishBookFast(1, true, true, 0)
+
+	RemoveSymbol(1)
+	idx, err := AddSymbol("BTC-C")
+	if err != nil || idx != 1 {
+		t.Fatalf("AddSymbol = %d, %v; want the freed slot 1", idx, err)
+	}
+
+	// The first write after reuse clears the previous symbol's levels
+	ApplyLevelFast(1, true, ActionNew, 0.020, 3)
+	PublishBookFast(1, true, false, 0)
+	if n := CopyBookLevels(1, false, make([]Level, 4)); n != 0 {
+		t.Errorf("asks after reuse = %d levels, want 0", n)
+	}
+	d := ReadDepthFast(1)
+	if d.BidPrice != 0.020 || d.BidQty != 3 || d.AskPrice != 0 || d.AskQty != 0 {
+		t.Errorf("top of book = %g/%g %g/%g, want 0.02/3 and an empty ask", d.BidPrice, d.BidQty, d.AskPrice, d.AskQty)
+	}
+
+	// A snapshot reset clears both sides
+	ApplyLevelFast(0, true, ActionNew, 0.030, 1)
+	ResetBookFast(0)
+	if n := CopyBookLevels(0, true, make([]Level, 4)); n != 0 {
+		t.Errorf("bids after ResetBookFast = %d levels, want 0", n)
+	}
+}
+
+func TestPublishBothSidesOnce(t *testing.T) {
+	defer SetCapacity(Capacity())
+	SetCapacity(4)
+	ch := make(chan Update, 4)
+	InitOrderBooks([]string{"BTC-A"}, ch)
+	defer func() { updateCh = nil }()
+
+	ApplyLevelFast(0, true, ActionNew, 0.010, 1)
+	ApplyLevelFast(0, false, ActionNew, 0.012, 2)
+	PublishBookFast(0, true, true, 0)
+
+	if len(ch) != 1 {
+		t.Fatalf("%d updates for one two-sided message, want 1", len(ch))
+	}
+	u := <-ch
+	if !u.TwoSided || u.Price != 0.010 || u.Qty != 1 || u.AskPrice != 0.012 || u.AskQty != 2 {
+		t.Errorf("update = %+v", u)
+	}
+	d := ReadDepthFast(0)
+	if d.BidPrice != 0.010 || d.AskPrice != 0.012 {
+		t.Errorf("top of book = %g/%g, want 0.01/0.012", d.BidPrice, d.AskPrice)
+	}
+
+	ApplyLevelFast(0, false, ActionChange, 0.012, 5)
+	PublishBookFast(0, false, true, 0)
+	if u := <-ch; u.TwoSided || u.IsBid || u.Qty != 5 {
+		t.Errorf("one-sided update = %+v", u)
+	}
+}
@@ -3,41 +3,49 @@ package data
 import (
 	"log"
 	"os"
-)
-
-const (
-	maxSymbols = 40
+	"sync"
+	"sync/atomic"
 )
 
 var (
-	symbolToIndex [maxSymbols]int32 // direct indexing
-	symbolNames   [maxSymbols]string
-	updateCh      chan Update
-	symbolCount   int32
-	obDebug       = os.Getenv("DATA_OB_DEBUG") == "1"
+	symMu       sync.RWMutex // guards symbolNames (writes only at setup / runtime add/remove)
+	symbolNames []string     // by symbol index, Capacity() slots ("" = free)
+	updateCh    chan Update
+	symbolCount int32 // atomic: high-water mark of used slots
+	obDebug     = os.Getenv("DATA_OB_DEBUG") == "1"
 )
 
+// validIdx reports whether idx is inside the used slot range.
+func validIdx(idx int32) bool {
+	return idx >= 0 && idx < atomic.LoadInt32(&symbolCount)
+}
+
 func InitOrderBooks(syms []string, ch chan Update) {
 	updateCh = ch
 	count := len(syms)
-	if count > maxSymbols {
-		count = maxSymbols
+	if count > maxOptions {
+		count = maxOptions
 	}
 
+	symMu.Lock()
 	for i := 0; i < count; i++ {
-		symbolToIndex[i] = int32(i)
 		symbolNames[i] = syms[i]
+		l2Books[i].Reset()
+		resolveMeta(int32(i), syms[i])
 	}
-	symbolCount = int32(count)
+	atomic.StoreInt32(&symbolCount, int32(count))
+	writeSymbolTable(symbolNames[:count])
+	symMu.Unlock()
 }
 
 func ApplyUpdateFast(symbolIdx int32, isBid bool, price, qty, idxPrice float64) {
-	if symbolIdx < 0 || symbolIdx >= symbolCount {
+	if !validIdx(symbolIdx) {
 		if obDebug {
 			log.Printf("[OB][WRN] ApplyUpdateFast: bad idx=%d isBid=%t price=%.8f", symbolIdx, isBid, price)
 		}
 		return
 	}
+	claimReset(symbolIdx)
 
 	idx := int(symbolIdx)
 	current := ReadDepthFast(idx)
@@ -72,27 +80,76 @@ func ApplyUpdateFast(symbolIdx int32, isBid bool, price, qty, idxPrice float64)
 		}
 	}
 
-	// non-blocking channel trasnfer
-	select {
-	case updateCh <- Update{
+	emitUpdate(Update{
 		SymbolIdx:  symbolIdx,
 		IsBid:      isBid,
 		Price:      price,
 		Qty:        qty,
 		IndexPrice: idxPrice,
 		UpdateTime: Nanotime(),
-	}:
+	})
+}
+
+// ApplyQuoteFast writes both sides of a symbol in one top-of-book write, so
+// readers never pair the new bid with the old ask, and emits one Update.
+func ApplyQuoteFast(symbolIdx int32, bid, bidQty, ask, askQty, idxPrice float64) {
+	if !validIdx(symbolIdx) {
+		return
+	}
+	claimReset(symbolIdx)
+	WriteDepthFast(int(symbolIdx), bid, bidQty, ask, askQty)
+	if idxPrice > 0 {
+		SetIndexPrice(idxPrice)
+	}
+	if obDebug {
+		log.Printf("[OB][WRITE] %s idx=%d side=BOTH B=%.10f/Q=%.6f A=%.10f/Q=%.6f idx=%.2f",
+			GetSymbolName(symbolIdx), symbolIdx, bid, bidQty, ask, askQty, idxPrice)
+	}
+	emitUpdate(Update{
+		SymbolIdx:  symbolIdx,
+		IsBid:      true,
+		TwoSided:   true,
+		Price:      bid,
+		Qty:        bidQty,
+		AskPrice:   ask,
+		AskQty:     askQty,
+		IndexPrice: idxPrice,
+		UpdateTime: Nanotime(),
+	})
+}
+
+// ApplyRecordedFast re-applies a recorded Update (one or both sides).
+func ApplyRecordedFast(u Update) {
+	if u.TwoSided {
+		ApplyQuoteFast(u.SymbolIdx, u.Price, u.Qty, u.AskPrice, u.AskQty, u.IndexPrice)
+	} else {
+		ApplyUpdateFast(u.SymbolIdx, u.IsBid, u.Price, u.Qty, u.IndexPrice)
+	}
+}
+
+// emitUpdate hands u to the tap and, without blocking, to the strategy.
+func emitUpdate(u Update) {
+	if t := mdTap.Load(); t != nil {
+		(*t).OnUpdate(u)
+	}
+
+	// non-blocking channel trasnfer
+	select {
+	case updateCh <- u:
 	default:
 	}
 }
 
 func GetSymbolName(idx int32) string {
-	if idx < 0 || idx >= symbolCount {
+	if !validIdx(idx) {
 		return ""
 	}
+	symMu.RLock()
+	defer symMu.RUnlock()
 	return symbolNames[idx]
 }
 
+// GetSymbolCount returns the number of used slots (including freed ones).
 func GetSymbolCount() int32 {
-	return symbolCount
+	return atomic.LoadInt32(&symbolCount)
 }
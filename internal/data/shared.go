@@ -3,65 +3,128 @@ package data
 
 import (
 	"math"
+	"runtime"
 	"sync/atomic"
 	"unsafe"
 )
 
-const MaxOptions = 40
 const cacheLine = 64
 
+// SharedBook holds the index price; per-instrument depth entries follow it
+// in memory (see books), one cache line each.
 type SharedBook struct {
 	IndexPrice   float64
 	LastUpdateNs int64 // nanosecond timestamp
 	_            [cacheLine - 16]byte
-	Books        [MaxOptions]DepthEntry
 }
 
+// DepthEntry is guarded by a sequence lock: Seq is odd while a write is in
+// progress and is bumped twice per write, so readers retry on a torn read.
 type DepthEntry struct {
+	Seq          uint64
 	BidPrice     float64
 	BidQty       float64
 	AskPrice     float64
 	AskQty       float64
 	LastUpdateNs int64
-	_            [cacheLine - 40]byte
+	_            [cacheLine - 48]byte
 }
 
+// spins before yielding to the writer goroutine (GOMAXPROCS=1 friendly)
+const seqSpinLimit = 64
+
 // Update info - Stack assignment optimization
 type Update struct {
 	SymbolIdx  int32 // identifying symbols with index
 	IsBid      bool
+	TwoSided   bool // Price/Qty is the bid, AskPrice/AskQty the ask
 	Price      float64
 	Qty        float64
+	AskPrice   float64 // TwoSided only
+	AskQty     float64 // TwoSided only
 	IndexPrice float64
 	UpdateTime int64 // nanosecond
 }
 
-var shared = &SharedBook{}
+var (
+	shared = &SharedBook{}
+	books  []DepthEntry // Capacity() entries, cache-line aligned
+)
 
 func SetIndexPrice(v float64) {
 	now := Nanotime()
-	atomic.StoreUint64((*uint64)(unsafe.Pointer(&shared.IndexPrice)), math.Float64bits(v))
+	old := atomic.SwapUint64((*uint64)(unsafe.Pointer(&shared.IndexPrice)), math.Float64bits(v))
 	atomic.StoreInt64(&shared.LastUpdateNs, now)
+	if t := mdTap.Load(); t != nil && old != math.Float64bits(v) {
+		(*t).OnIndex(v, now)
+	}
 }
 
 func GetIndexPrice() float64 {
 	return math.Float64frombits(atomic.LoadUint64((*uint64)(unsafe.Pointer(&shared.IndexPrice))))
 }
 
+// WriteDepthFast publishes a top-of-book entry. Single writer per entry.
 func WriteDepthFast(idx int, bid, bidQty, ask, askQty float64) {
-	entry := &shared.Books[idx]
+	entry := &books[idx]
 	now := Nanotime()
 
-	// atomic update
+	atomic.AddUint64(&entry.Seq, 1) // odd: write in progress
 	atomic.StoreUint64((*uint64)(unsafe.Pointer(&entry.BidPrice)), math.Float64bits(bid))
 	atomic.StoreUint64((*uint64)(unsafe.Pointer(&entry.BidQty)), math.Float64bits(bidQty))
 	atomic.StoreUint64((*uint64)(unsafe.Pointer(&entry.AskPrice)), math.Float64bits(ask))
 	atomic.StoreUint64((*uint64)(unsafe.Pointer(&entry.AskQty)), math.Float64bits(askQty))
 	atomic.StoreInt64(&entry.LastUpdateNs, now)
+	atomic.AddUint64(&entry.Seq, 1) // even: stable
 }
 
+// ReadDepthFast returns a consistent (untorn) copy of one entry.
 func ReadDepthFast(idx int) DepthEntry {
-	entry := &shared.Books[idx]
+	return books[idx].Load()
+}
+
+// ReadDepth4Fast returns the four legs of a box as of one instant: all
+// sequence counters must be unchanged across the whole read.
+func ReadDepth4Fast(i0, i1, i2, i3 int) [4]DepthEntry {
+	e := [4]*DepthEntry{&books[i0], &books[i1], &books[i2], &books[i3]}
+	var seqs [4]uint64
+	var out [4]DepthEntry
+	for spin := 0; ; spin++ {
+		for k := 0; k < 4; k++ {
+			seqs[k] = waitStable(e[k], spin)
+		}
+		for k := 0; k < 4; k++ {
+			out[k] = loadEntry(e[k])
+		}
+		ok := true
+		for k := 0; k < 4; k++ {
+			if atomic.LoadUint64(&e[k].Seq) != seqs[k] {
+				ok = false
+				break
+			}
+			out[k].Seq = seqs[k]
+		}
+		if ok {
+			return out
+		}
+	}
+}
+
+// waitStable spins until no write is in progress and returns the even seq.
+func waitStable(entry *DepthEntry, spin int) uint64 {
+	for {
+		seq := atomic.LoadUint64(&entry.Seq)
+		if seq&1 == 0 {
+			return seq
+		}
+		spin++
+		if spin%seqSpinLimit == 0 {
+			runtime.Gosched()
+		}
+	}
+}
+
+func loadEntry(entry *DepthEntry) DepthEntry {
 	return DepthEntry{
 		BidPrice:     math.Float64frombits(atomic.LoadUint64((*uint64)(unsafe.Pointer(&entry.BidPrice)))),
 		BidQty:       math.Float64frombits(atomic.LoadUint64((*uint64)(unsafe.Pointer(&entry.BidQty)))),
@@ -0,0 +1,79 @@
+package data
+
+import (
+	"math"
+	"os"
+	"strings"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Cross-process segment layout (all offsets cache-line aligned):
+//
+//	[0, 64)                      ShmHeader
+//	[64, 64+cap*SymbolNameLen)   symbol table, NUL-padded names by symbol index
+//	[bookOff, bookOff+64)        SharedBook (index price)
+//	[entriesOff, ...)            cap * DepthEntry, by symbol index
+const (
+	ShmMagic      uint64 = 0x4f50544844474552 // "OPTHDGER"
+	ShmVersion    uint32 = 2
+	SymbolNameLen        = 64
+	shmHeaderSize        = cacheLine
+)
+
+// ShmHeader sits at offset 0 of the segment. Magic is written last on init,
+// so readers must check it before trusting the rest.
+type ShmHeader struct {
+	Magic       uint64
+	Version     uint32
+	Capacity    uint32 // number of DepthEntry slots
+	SymbolSeq   uint64 // sequence lock for the symbol table (odd = writing)
+	SymbolCount int32
+	WriterPid   int32
+	CreatedNs   int64 // wall clock, unix ns
+	_           [cacheLine - 40]byte
+}
+
+// ShmSymbolTableOffset returns the offset of the symbol table.
+func ShmSymbolTableOffset() int { return shmHeaderSize }
+
+// ShmBookOffset returns the offset of SharedBook for a given capacity.
+func ShmBookOffset(capacity int) int { return shmHeaderSize + capacity*SymbolNameLen }
+
+// ShmEntriesOffset returns the offset of the first DepthEntry.
+func ShmEntriesOffset(capacity int) int {
+	return ShmBookOffset(capacity) + int(unsafe.Sizeof(SharedBook{}))
+}
+
+// ShmSize returns the total segment size for a given capacity.
+func ShmSize(capacity int) int {
+	return ShmEntriesOffset(capacity) + capacity*int(unsafe.Sizeof(DepthEntry{}))
+}
+
+// ShmName returns the segment name under /dev/shm (DATA_SHM_NAME).
+// "off" disables the segment.
+func ShmName() string {
+	if v := strings.TrimSpace(os.Getenv("DATA_SHM_NAME")); v != "" {
+		return v
+	}
+	return "options_hedger"
+}
+
+// Load returns a consistent copy of the entry using its sequence lock.
+// Safe on entries living in a read-only mapping of another process.
+func (e *DepthEntry) Load() DepthEntry {
+	for spin := 0; ; spin++ {
+		seq := waitStable(e, spin)
+		out := loadEntry(e)
+		if atomic.LoadUint64(&e.Seq) == seq {
+			out.Seq = seq
+			return out
+		}
+	}
+}
+
+// Index returns the index price and its last update time (monotonic ns).
+func (b *SharedBook) Index() (float64, int64) {
+	px := math.Float64frombits(atomic.LoadUint64((*uint64)(unsafe.Pointer(&b.IndexPrice))))
+	return px, atomic.LoadInt64(&b.LastUpdateNs)
+}
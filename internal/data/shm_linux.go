@@ -0,0 +1,110 @@
+//go:build linux
+
+package data
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	shmMem    []byte
+	shmHeader *ShmHeader
+)
+
+// InitSharedMemory moves SharedBook into a file-backed mapping under /dev/shm
+// so other processes can read depth and index price via pkg/shmreader.
+// Must be called before any writer (FIX session, strategy) starts.
+func InitSharedMemory() error {
+	name := ShmName()
+	if name == "off" {
+		return nil
+	}
+	path := filepath.Join("/dev/shm", name)
+	capacity := maxOptions
+	size := ShmSize(capacity)
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	defer f.Close()
+	if err := f.Truncate(int64(size)); err != nil {
+		return fmt.Errorf("truncate %s: %w", path, err)
+	}
+	mem, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return fmt.Errorf("mmap %s: %w", path, err)
+	}
+
+	hdr := (*ShmHeader)(unsafe.Pointer(&mem[0]))
+	atomic.StoreUint64(&hdr.Magic, 0) // invalidate for readers while we rebuild
+	clear(mem[8:])
+	hdr.Version = ShmVersion
+	hdr.Capacity = uint32(capacity)
+	hdr.WriterPid = int32(os.Getpid())
+	hdr.CreatedNs = time.Now().UnixNano()
+
+	book := (*SharedBook)(unsafe.Pointer(&mem[ShmBookOffset(capacity)]))
+	px, ts := shared.Index()
+	book.IndexPrice, book.LastUpdateNs = px, ts
+	entries := unsafe.Slice((*DepthEntry)(unsafe.Pointer(&mem[ShmEntriesOffset(capacity)])), capacity)
+	for i := range books {
+		entries[i] = books[i].Load()
+	}
+
+	shmMem, shmHeader = mem, hdr
+	shared, books = book, entries
+	n := atomic.LoadInt32(&symbolCount)
+	for i := int32(0); i < n; i++ {
+		writeSymbolName(i, symbolNames[i])
+	}
+	atomic.StoreInt32(&hdr.SymbolCount, n)
+	atomic.StoreUint64(&hdr.Magic, ShmMagic)
+
+	log.Printf("[SHM] SharedBook mapped at %s (%d bytes, cap=%d)", path, size, capacity)
+	return nil
+}
+
+// writeSymbolTable publishes the symbol names under the table sequence lock.
+func writeSymbolTable(names []string) {
+	if shmHeader == nil {
+		return
+	}
+	atomic.AddUint64(&shmHeader.SymbolSeq, 1)
+	for i, n := range names {
+		writeSymbolName(int32(i), n)
+	}
+	atomic.StoreInt32(&shmHeader.SymbolCount, int32(len(names)))
+	atomic.AddUint64(&shmHeader.SymbolSeq, 1)
+}
+
+// publishSymbol updates one slot of the symbol table (runtime add/remove).
+func publishSymbol(idx int32, name string, count int32) {
+	if shmHeader == nil {
+		return
+	}
+	atomic.AddUint64(&shmHeader.SymbolSeq, 1)
+	writeSymbolName(idx, name)
+	atomic.StoreInt32(&shmHeader.SymbolCount, count)
+	atomic.AddUint64(&shmHeader.SymbolSeq, 1)
+}
+
+func writeSymbolName(idx int32, name string) {
+	off := ShmSymbolTableOffset() + int(idx)*SymbolNameLen
+	slot := shmMem[off : off+SymbolNameLen]
+	n := copy(slot[:SymbolNameLen-1], name)
+	clear(slot[n:])
+}
+
+// WriteIndexPrice is kept for API parity with the non-Linux build.
+func WriteIndexPrice(v float64) { SetIndexPrice(v) }
+
+// ReadIndexPrice is kept for API parity with the non-Linux build.
+func ReadIndexPrice() float64 { return GetIndexPrice() }
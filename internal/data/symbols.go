@@ -0,0 +1,91 @@
+package data
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// LookupSymbol returns the slot index of a symbol, or -1.
+func LookupSymbol(name string) int32 {
+	n := atomic.LoadInt32(&symbolCount)
+	symMu.RLock()
+	defer symMu.RUnlock()
+	for i := int32(0); i < n; i++ {
+		if symbolNames[i] == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddSymbol assigns a slot to a symbol at runtime (reusing freed slots first)
+// and returns its index. Adding an existing symbol returns its current index.
+// Call before the FIX mapping is published. A reused slot's book is cleared
+// by the book writer before its first update; the slot stays stale until its
+// first market data, and held until the strategy has dropped the symbol that
+// freed it (see HoldSymbol).
+func AddSymbol(name string) (int32, error) {
+	if name == "" {
+		return -1, fmt.Errorf("empty symbol")
+	}
+	symMu.Lock()
+	defer symMu.Unlock()
+
+	n := atomic.LoadInt32(&symbolCount)
+	free := int32(-1)
+	for i := int32(0); i < n; i++ {
+		switch symbolNames[i] {
+		case name:
+			return i, nil
+		case "":
+			if free < 0 {
+				free = i
+			}
+		}
+	}
+	idx := free
+	if idx < 0 {
+		if int(n) >= maxOptions {
+			return -1, fmt.Errorf("universe capacity %d exhausted", maxOptions)
+		}
+		idx = n
+	}
+
+	if idx < n {
+		markReset(idx)
+	}
+	symbolNames[idx] = name
+	resolveMeta(idx, name)
+	if idx == n {
+		atomic.StoreInt32(&symbolCount, n+1)
+	}
+	publishSymbol(idx, name, atomic.LoadInt32(&symbolCount))
+	if t := mdTap.Load(); t != nil {
+		(*t).OnSymbol(idx, name)
+	}
+	return idx, nil
+}
+
+// RemoveSymbol frees a slot at runtime. The slot is marked stale and held so no
+// strategy trades on it, and its book is cleared by the book writer; the
+// index may be reused later. The strategy releases the hold once it dropped
+// the symbol.
+func RemoveSymbol(idx int32) {
+	if !validIdx(idx) {
+		return
+	}
+	symMu.Lock()
+	defer symMu.Unlock()
+	if symbolNames[idx] == "" {
+		return
+	}
+	symbolNames[idx] = ""
+	resolveMeta(idx, "")
+	SetSymbolStale(idx, true)
+	HoldSymbol(idx)
+	markReset(idx)
+	publishSymbol(idx, "", atomic.LoadInt32(&symbolCount))
+	if t := mdTap.Load(); t != nil {
+		(*t).OnSymbol(idx, "")
+	}
+}
@@ -0,0 +1,23 @@
+package data
+
+import "sync/atomic"
+
+// MDTap receives a copy of every book update, index change and symbol slot
+// change (see SetMDTap). Calls are made on the FIX receive goroutine and
+// must not block.
+type MDTap interface {
+	OnUpdate(u Update)
+	OnIndex(price float64, ns int64)
+	OnSymbol(idx int32, name string)
+}
+
+var mdTap atomic.Pointer[MDTap]
+
+// SetMDTap installs the market data tap (nil removes it).
+func SetMDTap(t MDTap) {
+	if t == nil {
+		mdTap.Store(nil)
+		return
+	}
+	mdTap.Store(&t)
+}
@@ -0,0 +1,320 @@
+// Package execution turns strategy signals into orders.
+package execution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"Options_Hedger/internal/data"
+	"Options_Hedger/internal/fix"
+	"Options_Hedger/internal/risk"
+	"Options_Hedger/internal/strategy"
+
+	"github.com/quickfixgo/enum"
+)
+
+// Mode selects how a box is sent to the exchange.
+type Mode uint8
+
+const (
+	ModeOff      Mode = iota // signals only
+	ModeMultileg             // one NewOrderMultileg (35=AB)
+	ModeLegs                 // four sequenced IOC legs
+	ModeAuto                 // multileg, falling back to legs when rejected
+)
+
+func (m Mode) String() string {
+	return [...]string{"off", "multileg", "legs", "auto"}[m]
+}
+
+// Config for BoxExecutor.
+//
+// Env:
+//
+//	HEDGE_EXEC_MODE=off|multileg|legs|auto  (default off)
+//	HEDGE_EXEC_MAX_QTY=0                    (box size cap, 0 = signal size)
+//	HEDGE_EXEC_MAX_AGE_MS=500               (drop older signals)
+//	HEDGE_EXEC_LEG_TIMEOUT_MS=2000          (wait for each order's final state)
+//	HEDGE_EXEC_ML_FAILS=3                   (auto: multileg rejects before using legs only)
+//
+// Legged execution is configured by LegConfig.
+type Config struct {
+	Mode          Mode
+	MaxQty        float64
+	MaxAge        time.Duration
+	OrderTimeout  time.Duration
+	MultilegFails int32
+	Leg           LegConfig
+}
+
+// ConfigFromEnv reads HEDGE_EXEC_* settings.
+func ConfigFromEnv() Config {
+	c := Config{
+		MaxQty:        envFloat("HEDGE_EXEC_MAX_QTY", 0),
+		MaxAge:        time.Duration(envFloat("HEDGE_EXEC_MAX_AGE_MS", 500)) * time.Millisecond,
+		OrderTimeout:  time.Duration(envFloat("HEDGE_EXEC_LEG_TIMEOUT_MS", 2000)) * time.Millisecond,
+		MultilegFails: int32(envFloat("HEDGE_EXEC_ML_FAILS", 3)),
+		Leg:           legConfigFromEnv(),
+	}
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("HEDGE_EXEC_MODE"))) {
+	case "multileg", "combo":
+		c.Mode = ModeMultileg
+	case "legs", "ioc":
+		c.Mode = ModeLegs
+	case "auto":
+		c.Mode = ModeAuto
+	}
+	return c
+}
+
+func envFloat(key string, def float64) float64 {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if x, err := strconv.ParseFloat(v, 64); err == nil {
+			return x
+		}
+	}
+	return def
+}
+
+// Leg is one option order of a box.
+type Leg struct {
+	Idx      int32
+	Symbol   string
+	Side     enum.Side
+	Price    float64 // signal price (BTC)
+	Avail    float64 // touch size when the box was prepared
+	Qty      float64 // ordered
+	Filled   float64
+	AvgPx    float64
+	Unwound  float64 // closed again after a failed box
+	UnwindPx float64
+	ClOrdID  string // last order sent for the leg
+}
+
+// Result reports the outcome of one BoxSignal: the realized box, or the
+// loss of unwinding a box that could not be completed.
+type Result struct {
+	Signal        strategy.BoxSignal
+	Via           Mode
+	Qty           float64 // box size completed on all four legs
+	Legs          [4]Leg  // low call, low put, high call, high put
+	Complete      bool
+	RealizedUSD   float64 // locked-in box value of Qty at fill prices, before fees
+	UnwindLossBTC float64
+	UnwindLossUSD float64 // at the index price when finished
+	Naked         bool    // some excess could not be unwound
+	Err           error
+	Started       time.Time
+	Finished      time.Time
+}
+
+var (
+	ErrStale      = errors.New("execution: signal too old")
+	ErrTooSmall   = errors.New("execution: size below min trade amount")
+	ErrNoQuote    = errors.New("execution: leg has no quote")
+	ErrIncomplete = errors.New("execution: box not completed")
+	ErrUnsettled  = errors.New("execution: order still live after cancel")
+)
+
+// BoxExecutor consumes BoxSignals one at a time. Signals arriving while a
+// box is in flight are dropped: their quotes are stale by the time we are free.
+type BoxExecutor struct {
+	cfg     Config
+	in      chan strategy.BoxSignal
+	results chan Result
+	mlFails int32 // atomic, consecutive multileg rejects
+}
+
+// NewBoxExecutor returns nil when cfg.Mode is ModeOff.
+func NewBoxExecutor(cfg Config) *BoxExecutor {
+	if cfg.Mode == ModeOff {
+		return nil
+	}
+	return &BoxExecutor{
+		cfg:     cfg,
+		in:      make(chan strategy.BoxSignal, 1),
+		results: make(chan Result, 64),
+	}
+}
+
+// Submit queues a signal without blocking; false if the executor is busy.
+func (x *BoxExecutor) Submit(sig strategy.BoxSignal) bool {
+	select {
+	case x.in <- sig:
+		return true
+	default:
+		return false
+	}
+}
+
+// Results delivers one Result per executed signal.
+func (x *BoxExecutor) Results() <-chan Result { return x.results }
+
+// Run executes submitted signals until ctx is done.
+func (x *BoxExecutor) Run(ctx context.Context) {
+	log.Printf("[EXEC] box executor started (mode=%s)", x.cfg.Mode)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-x.in:
+			r := x.Execute(ctx, sig)
+			select {
+			case x.results <- r:
+			default:
+				log.Printf("[EXEC] results channel full, dropped result")
+			}
+		}
+	}
+}
+
+// Execute sends one box and waits for its outcome.
+func (x *BoxExecutor) Execute(ctx context.Context, sig strategy.BoxSignal) (r Result) {
+	r = Result{Signal: sig, Started: time.Now()}
+	defer func() { r.Finished = time.Now() }()
+
+	if age := time.Duration(data.Nanotime() - sig.UpdateTimeNs); x.cfg.MaxAge > 0 && age > x.cfg.MaxAge {
+		r.Err = fmt.Errorf("%w (%s)", ErrStale, age)
+		return r
+	}
+	qty, lot, err := x.prepare(sig, &r.Legs)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	if err := risk.CheckBox(sig.LowStrike, sig.HighStrike, qty); err != nil {
+		r.Err = err
+		return r
+	}
+
+	mode := x.cfg.Mode
+	if mode == ModeAuto && atomic.LoadInt32(&x.mlFails) >= x.cfg.MultilegFails {
+		mode = ModeLegs
+	}
+	if mode == ModeMultileg || mode == ModeAuto {
+		r.Via = ModeMultileg
+		rejected := x.sendMultileg(ctx, sig, qty, &r)
+		if !rejected || mode == ModeMultileg {
+			return r
+		}
+		if n := atomic.AddInt32(&x.mlFails, 1); n == x.cfg.MultilegFails {
+			log.Printf("[EXEC] multileg rejected %d times in a row, using legs only", n)
+		}
+		r.Err = nil
+	}
+	r.Via = ModeLegs
+	x.legIn(ctx, lot, &r)
+	return r
+}
+
+// prepare builds the four legs from the current book and returns the box
+// size and the lot (largest min trade amount of the legs).
+func (x *BoxExecutor) prepare(sig strategy.BoxSignal, legs *[4]Leg) (float64, float64, error) {
+	idx := [4]int32{int32(sig.LowCallIdx), int32(sig.LowPutIdx), int32(sig.HighCallIdx), int32(sig.HighPutIdx)}
+	// Long box: +C_low, -P_low, -C_high, +P_high; short box is the mirror
+	buy := [4]bool{true, false, false, true}
+	if sig.Side == strategy.BoxShort {
+		buy = [4]bool{false, true, true, false}
+	}
+	depth := data.ReadDepth4Fast(int(idx[0]), int(idx[1]), int(idx[2]), int(idx[3]))
+
+	qty, lot := sig.Qty, 0.0
+	for i := range legs {
+		l := Leg{Idx: idx[i], Symbol: data.GetSymbolName(idx[i])}
+		avail := 0.0
+		if buy[i] {
+			l.Side, l.Price, avail = enum.Side_BUY, depth[i].AskPrice, depth[i].AskQty
+		} else {
+			l.Side, l.Price, avail = enum.Side_SELL, depth[i].BidPrice, depth[i].BidQty
+		}
+		if l.Symbol == "" || l.Price <= 0 || avail <= 0 {
+			return 0, 0, fmt.Errorf("%w: %s", ErrNoQuote, l.Symbol)
+		}
+		if avail < qty || qty <= 0 {
+			qty = avail
+		}
+		l.Avail = avail
+		if m, ok := data.InstrumentAt(idx[i]); ok && m.MinTradeAmount > lot {
+			lot = m.MinTradeAmount
+		}
+		legs[i] = l
+	}
+	if x.cfg.MaxQty > 0 && qty > x.cfg.MaxQty {
+		qty = x.cfg.MaxQty
+	}
+	qty = roundLot(qty, lot)
+	if qty <= 0 || (lot > 0 && qty < lot) {
+		return 0, 0, ErrTooSmall
+	}
+	for i := range legs {
+		legs[i].Qty = qty
+	}
+	return qty, lot, nil
+}
+
+// sendMultileg sends the box as one combo, expressed as a long box that is
+// bought (long) or sold (short). It reports whether the exchange rejected
+// the combo; orders that could not be sent do not count.
+func (x *BoxExecutor) sendMultileg(ctx context.Context, sig strategy.BoxSignal, qty float64, r *Result) bool {
+	longSide := [4]enum.Side{enum.Side_BUY, enum.Side_SELL, enum.Side_SELL, enum.Side_BUY}
+	req := fix.MultilegReq{Side: enum.Side_BUY, Qty: qty, ClOrdPrefix: "BOXML", TIF: enum.TimeInForce_IMMEDIATE_OR_CANCEL}
+	if sig.Side == strategy.BoxShort {
+		req.Side = enum.Side_SELL
+	}
+	for i, l := range r.Legs {
+		req.Legs = append(req.Legs, fix.MultilegLeg{Symbol: l.Symbol, Side: longSide[i], Ratio: 1})
+		if longSide[i] == enum.Side_BUY {
+			req.Price += l.Price
+		} else {
+			req.Price -= l.Price
+		}
+	}
+
+	id, err := fix.SendMultileg(&req)
+	if err != nil {
+		// Never reached the exchange (risk, credits, session): not a combo reject
+		r.Err = err
+		return false
+	}
+	wctx, cancel := context.WithTimeout(ctx, x.cfg.OrderTimeout)
+	o, err := fix.WaitOrder(wctx, id)
+	cancel()
+	o, err = x.settle(id, o, err)
+	for i := range r.Legs {
+		l := &r.Legs[i]
+		l.ClOrdID = id
+		l.Filled = o.CumQty
+		switch {
+		case o.CumQty <= 0:
+		case i < len(o.Legs) && o.Legs[i].AvgPx > 0:
+			l.AvgPx = o.Legs[i].AvgPx
+		case i == 0:
+			// no leg prices reported: the combo's price difference goes to the first leg
+			l.AvgPx = l.Price + o.AvgPx - req.Price
+		default:
+			l.AvgPx = l.Price
+		}
+	}
+	r.Qty = o.CumQty
+	r.Complete = o.CumQty >= qty-1e-9
+	switch {
+	case err != nil:
+		r.Err = fmt.Errorf("multileg %s: %w", id, err)
+	case o.State == fix.OrdRejected:
+		r.Err = fmt.Errorf("multileg rejected: %s", o.RejectReason)
+		return true
+	case !r.Complete:
+		r.Err = fmt.Errorf("%w: combo filled %g/%g", ErrIncomplete, o.CumQty, qty)
+	default:
+		atomic.StoreInt32(&x.mlFails, 0)
+	}
+	x.realize(r)
+	return false
+}
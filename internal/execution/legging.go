@@ -0,0 +1,270 @@
+package execution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"Options_Hedger/internal/data"
+	"Options_Hedger/internal/fix"
+	"Options_Hedger/internal/strategy"
+
+	"github.com/quickfixgo/enum"
+)
+
+// defaultTick is the Deribit BTC option tick used when the registry has none.
+const defaultTick = 0.0005
+
+// LegConfig controls sequential (legged) box execution.
+//
+// Env:
+//
+//	HEDGE_LEG_RETRIES=3           (re-priced IOC attempts per leg after the first)
+//	HEDGE_LEG_MAX_SLIP_TICKS=2    (re-price at most this far from the signal price)
+//	HEDGE_LEG_DEADLINE_MS=3000    (complete all four legs within, else unwind)
+//	HEDGE_UNWIND_SLIP_TICKS=10    (how far through the touch unwind orders go)
+//	HEDGE_UNWIND_RETRIES=5
+type LegConfig struct {
+	Retries         int
+	MaxSlipTicks    float64
+	Deadline        time.Duration
+	UnwindSlipTicks float64
+	UnwindRetries   int
+}
+
+func legConfigFromEnv() LegConfig {
+	return LegConfig{
+		Retries:         int(envFloat("HEDGE_LEG_RETRIES", 3)),
+		MaxSlipTicks:    envFloat("HEDGE_LEG_MAX_SLIP_TICKS", 2),
+		Deadline:        time.Duration(envFloat("HEDGE_LEG_DEADLINE_MS", 3000)) * time.Millisecond,
+		UnwindSlipTicks: envFloat("HEDGE_UNWIND_SLIP_TICKS", 10),
+		UnwindRetries:   int(envFloat("HEDGE_UNWIND_RETRIES", 5)),
+	}
+}
+
+// legIn executes the box leg by leg:
+//   - least liquid leg first (most likely to fail, cheapest to abandon),
+//   - every following leg sized to what the previous legs actually filled,
+//   - unfilled remainders re-priced from the live book within MaxSlipTicks,
+//   - legs filled beyond the completed box size are unwound at the deadline.
+func (x *BoxExecutor) legIn(ctx context.Context, lot float64, r *Result) {
+	cfg := x.cfg.Leg
+	dctx, cancel := context.WithTimeout(ctx, cfg.Deadline)
+	defer cancel()
+
+	order := []int{0, 1, 2, 3}
+	sort.SliceStable(order, func(a, b int) bool { return r.Legs[order[a]].Avail < r.Legs[order[b]].Avail })
+
+	target := r.Legs[order[0]].Qty
+	for _, i := range order {
+		l := &r.Legs[i]
+		l.Qty = target
+		x.fillLeg(dctx, l, cfg)
+		if l.Filled < target {
+			target = roundLot(l.Filled, lot)
+		}
+		if target <= 0 {
+			break
+		}
+	}
+
+	r.Qty = target
+	r.Complete = target > 0 && target >= r.Legs[order[0]].Qty-1e-9
+	if !r.Complete {
+		r.Err = fmt.Errorf("%w: %g of %g boxes", ErrIncomplete, target, r.Legs[order[0]].Qty)
+	}
+	for i := range r.Legs {
+		if excess := r.Legs[i].Filled - target; excess > 1e-9 {
+			x.unwind(ctx, &r.Legs[i], excess, cfg, r)
+		}
+	}
+	x.realize(r)
+}
+
+// fillLeg sends IOC orders until l.Qty is filled, the retries are used up or
+// ctx ends. Each retry is priced from the current touch, capped MaxSlipTicks
+// away from the signal price.
+func (x *BoxExecutor) fillLeg(ctx context.Context, l *Leg, cfg LegConfig) {
+	tick := tickOf(l.Idx)
+	limit := l.Price + cfg.MaxSlipTicks*tick
+	if l.Side == enum.Side_SELL {
+		limit = l.Price - cfg.MaxSlipTicks*tick
+	}
+	px := l.Price
+	for attempt := 0; attempt <= cfg.Retries && ctx.Err() == nil; attempt++ {
+		rem := l.Qty - l.Filled
+		if rem <= 1e-9 {
+			return
+		}
+		if attempt > 0 {
+			px = repriced(l, limit, tick)
+		}
+		o, err := x.sendIOC(ctx, l.Symbol, l.Side, px, rem, "BOXL", false)
+		l.ClOrdID = o.ClOrdID
+		addFill(&l.Filled, &l.AvgPx, o.CumQty, o.AvgPx)
+		if err != nil {
+			log.Printf("[EXEC] leg %s attempt %d: %v", l.Symbol, attempt+1, err)
+		}
+		if errors.Is(err, ErrUnsettled) {
+			return // fills of a live order are unknown: don't send on top of it
+		}
+	}
+}
+
+// repriced returns the live touch for the leg's side, no worse than limit.
+func repriced(l *Leg, limit, tick float64) float64 {
+	d := data.ReadDepthFast(int(l.Idx))
+	if l.Side == enum.Side_BUY {
+		if d.AskPrice <= 0 {
+			return limit
+		}
+		return roundTick(math.Min(d.AskPrice, limit), tick)
+	}
+	if d.BidPrice <= 0 {
+		return limit
+	}
+	return roundTick(math.Max(d.BidPrice, limit), tick)
+}
+
+// unwind closes qty of a filled leg with aggressive IOC orders on the
+// opposite side and records the loss against the entry price.
+func (x *BoxExecutor) unwind(ctx context.Context, l *Leg, qty float64, cfg LegConfig, r *Result) {
+	side, sign := enum.Side_SELL, 1.0
+	if l.Side == enum.Side_SELL {
+		side, sign = enum.Side_BUY, -1.0
+	}
+	tick := tickOf(l.Idx)
+	uctx, cancel := context.WithTimeout(ctx, x.cfg.OrderTimeout*time.Duration(cfg.UnwindRetries+1))
+	defer cancel()
+
+	for attempt := 0; attempt <= cfg.UnwindRetries && uctx.Err() == nil; attempt++ {
+		rem := qty - l.Unwound
+		if rem <= 1e-9 {
+			break
+		}
+		d := data.ReadDepthFast(int(l.Idx))
+		px := d.BidPrice - cfg.UnwindSlipTicks*tick
+		if side == enum.Side_BUY {
+			px = d.AskPrice + cfg.UnwindSlipTicks*tick
+		}
+		px = math.Max(roundTick(px, tick), tick)
+		o, err := x.sendIOC(uctx, l.Symbol, side, px, rem, "BOXU", true)
+		addFill(&l.Unwound, &l.UnwindPx, o.CumQty, o.AvgPx)
+		if err != nil {
+			log.Printf("[EXEC] unwind %s attempt %d: %v", l.Symbol, attempt+1, err)
+		}
+		if errors.Is(err, ErrUnsettled) {
+			break
+		}
+	}
+	// Bought high and sold lower (or the mirror) = positive loss
+	r.UnwindLossBTC += sign * (l.AvgPx - l.UnwindPx) * l.Unwound
+	if left := qty - l.Unwound; left > 1e-9 {
+		r.Naked = true
+		log.Printf("[EXEC] NAKED %s %s %g left after unwind", sideName(l.Side), l.Symbol, left)
+	}
+}
+
+// realize fills in the realized box value at the current index price.
+func (x *BoxExecutor) realize(r *Result) {
+	S := data.GetIndexPrice()
+	var netBTC float64 // paid per box: buys minus sells
+	for _, l := range r.Legs {
+		if l.Side == enum.Side_BUY {
+			netBTC += l.AvgPx
+		} else {
+			netBTC -= l.AvgPx
+		}
+	}
+	dir := 1.0
+	if r.Signal.Side == strategy.BoxShort {
+		dir = -1.0
+	}
+	if r.Qty > 0 {
+		r.RealizedUSD = (dir*(r.Signal.HighStrike-r.Signal.LowStrike) - netBTC*S) * r.Qty
+	}
+	r.UnwindLossUSD = r.UnwindLossBTC * S
+}
+
+// sendIOC sends one IOC order and waits for its final state. An order still
+// open at the timeout is cancelled and settled, so late fills are counted.
+func (x *BoxExecutor) sendIOC(ctx context.Context, sym string, side enum.Side, px, qty float64, prefix string, reduce bool) (fix.Order, error) {
+	req := fix.OrderReq{
+		Symbol:      sym,
+		Side:        side,
+		Price:       px,
+		Qty:         qty,
+		TIF:         enum.TimeInForce_IMMEDIATE_OR_CANCEL,
+		ClOrdPrefix: prefix,
+		Reduce:      reduce,
+	}
+	id, err := fix.SendOrderReq(&req)
+	if err != nil {
+		return fix.Order{ClOrdID: id}, err
+	}
+	wctx, cancel := context.WithTimeout(ctx, x.cfg.OrderTimeout)
+	defer cancel()
+	o, err := fix.WaitOrder(wctx, id)
+	return x.settle(id, o, err)
+}
+
+// settle finishes an order whose wait ended early: it cancels the order and
+// waits (past the caller's deadline) for its final state. The error is
+// ErrUnsettled if the order is still open afterwards.
+func (x *BoxExecutor) settle(id string, o fix.Order, err error) (fix.Order, error) {
+	if err == nil || errors.Is(err, fix.ErrUnknownOrder) {
+		return o, err
+	}
+	if o.State.Terminal() {
+		return o, nil
+	}
+	if _, cerr := fix.CancelOrder(id); cerr != nil && !errors.Is(cerr, fix.ErrOrderDone) {
+		log.Printf("[EXEC] cancel %s after timeout: %v", id, cerr)
+	}
+	wctx, cancel := context.WithTimeout(context.Background(), x.cfg.OrderTimeout)
+	defer cancel()
+	f, werr := fix.WaitOrder(wctx, id)
+	if werr == nil || f.State.Terminal() {
+		return f, nil
+	}
+	if f.ClOrdID != "" {
+		o = f
+	}
+	return o, fmt.Errorf("%w: %s %s", ErrUnsettled, id, o.State)
+}
+
+// addFill accumulates qty@px into a running quantity and average price.
+func addFill(total, avg *float64, qty, px float64) {
+	if qty <= 0 {
+		return
+	}
+	*avg = (*avg**total + px*qty) / (*total + qty)
+	*total += qty
+}
+
+func tickOf(idx int32) float64 {
+	if m, ok := data.InstrumentAt(idx); ok && m.TickSize > 0 {
+		return m.TickSize
+	}
+	return defaultTick
+}
+
+func roundTick(px, tick float64) float64 { return math.Round(px/tick) * tick }
+
+func roundLot(q, lot float64) float64 {
+	if lot <= 0 {
+		return q
+	}
+	return math.Floor(q/lot+1e-9) * lot
+}
+
+func sideName(s enum.Side) string {
+	if s == enum.Side_BUY {
+		return "BUY"
+	}
+	return "SELL"
+}
@@ -0,0 +1,240 @@
+package fix
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"Options_Hedger/internal/data"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/field"
+	"github.com/quickfixgo/fix44/ordercancelreplacerequest"
+	"github.com/quickfixgo/fix44/ordercancelrequest"
+	"github.com/quickfixgo/fix44/ordermasscancelrequest"
+	"github.com/quickfixgo/quickfix"
+)
+
+// ErrUnknownOrder is returned for ClOrdIDs the order manager does not track.
+var ErrUnknownOrder = errors.New("fix: unknown order")
+
+// ErrOrderDone is returned when cancelling/replacing a terminal order.
+var ErrOrderDone = errors.New("fix: order already done")
+
+// CancelOrder sends an OrderCancelRequest (35=F) for a tracked order and
+// returns the cancel's ClOrdID. The result arrives as EvCanceled or
+// EvCancelRejected.
+func CancelOrder(clOrdID string) (string, error) {
+	o, ok := GetOrder(clOrdID)
+	if !ok {
+		return "", ErrUnknownOrder
+	}
+	if o.State.Terminal() {
+		return "", ErrOrderDone
+	}
+	id := newClOrdID(o.prefix())
+
+	req := ordercancelrequest.New(
+		field.NewOrigClOrdID(o.ClOrdID),
+		field.NewClOrdID(id),
+		field.NewSide(o.Side),
+		field.NewTransactTime(time.Now()),
+	)
+	if sym := o.wireSymbol(); sym != "" {
+		req.Set(field.NewSymbol(sym))
+	}
+	if o.OrderID != "" {
+		req.Set(field.NewOrderID(o.OrderID))
+	}
+	req.Set(field.NewOrderQty(fixDec(o.Qty)))
+
+	if err := throttle.acquire(reqCancel, false); err != nil {
+		return "", err
+	}
+	prev := orders.markPending(o.ClOrdID, id, OrdPendingCancel)
+	if err := sendToSession(req); err != nil {
+		orders.restoreState(o.ClOrdID, prev)
+		return id, err
+	}
+	return id, nil
+}
+
+// ReplaceOrder sends an OrderCancelReplaceRequest (35=G) changing price and
+// quantity (0 keeps the current value). On acknowledgement the order is
+// re-keyed under the returned ClOrdID; the old one stays resolvable.
+func ReplaceOrder(clOrdID string, price, qty float64) (string, error) {
+	o, ok := GetOrder(clOrdID)
+	if !ok {
+		return "", ErrUnknownOrder
+	}
+	if o.State.Terminal() {
+		return "", ErrOrderDone
+	}
+	if price <= 0 {
+		price = o.Price
+	}
+	if qty <= 0 {
+		qty = o.Qty
+	}
+	chk := OrderCheck{Symbol: o.Symbol, Side: o.Side, Price: price, Qty: qty - o.CumQty, Replace: true}
+	if len(o.Legs) > 0 {
+		chk.Symbol, chk.Legs = "", o.Legs
+	}
+	if err := checkPreTrade(chk); err != nil {
+		return "", err
+	}
+	id := newClOrdID(o.prefix())
+
+	req := ordercancelreplacerequest.New(
+		field.NewOrigClOrdID(o.ClOrdID),
+		field.NewClOrdID(id),
+		field.NewSide(o.Side),
+		field.NewTransactTime(time.Now()),
+		field.NewOrdType(enum.OrdType_LIMIT),
+	)
+	if sym := o.wireSymbol(); sym != "" {
+		req.Set(field.NewSymbol(sym))
+	}
+	if o.OrderID != "" {
+		req.Set(field.NewOrderID(o.OrderID))
+	}
+	if o.TIF != "" {
+		req.Set(field.NewTimeInForce(o.TIF))
+	}
+	req.Set(field.NewOrderQty(fixDec(qty)))
+	req.Set(field.NewPrice(fixDec(price)))
+
+	if err := throttle.acquire(reqOrder, o.TIF == enum.TimeInForce_IMMEDIATE_OR_CANCEL); err != nil {
+		return "", err
+	}
+	prev := orders.markPending(o.ClOrdID, id, OrdPendingReplace)
+	if err := sendToSession(req); err != nil {
+		orders.restoreState(o.ClOrdID, prev)
+		return id, err
+	}
+	return id, nil
+}
+
+// MassCancel sends an OrderMassCancelRequest (35=q) for one symbol, or for
+// every order of the account when symbol is "". Individual cancels are
+// reported through ExecutionReports; the 35=r report is logged.
+func MassCancel(symbol string) error {
+	typ := enum.MassCancelRequestType_CANCEL_ALL_ORDERS
+	if symbol != "" {
+		typ = enum.MassCancelRequestType_CANCEL_ORDERS_FOR_A_SECURITY
+	}
+	req := ordermasscancelrequest.New(
+		field.NewClOrdID(newClOrdID("MC")),
+		field.NewMassCancelRequestType(typ),
+		field.NewTransactTime(time.Now()),
+	)
+	if symbol != "" {
+		req.Set(field.NewSymbol(symbol))
+	}
+	if err := throttle.acquire(reqCancel, false); err != nil {
+		return err
+	}
+	return sendToSession(req)
+}
+
+// wireSymbol returns the Symbol (55) to send on a cancel/replace: "" for a
+// combo still named after its legs, which OrigClOrdID/OrderID identify.
+func (o Order) wireSymbol() string {
+	if len(o.Legs) > 0 && o.Symbol == legNames(o.Legs) {
+		return ""
+	}
+	return o.Symbol
+}
+
+// CancelFilter selects open orders for CancelAll. Empty fields match all.
+// Symbol and Expiry match a multileg order when any of its legs matches.
+type CancelFilter struct {
+	Symbol string // exact instrument name
+	Expiry string // Deribit expiry label, e.g. "27SEP25"
+	Prefix string // ClOrdID prefix (strategy tag)
+}
+
+func (f CancelFilter) match(o Order) bool {
+	if f.Prefix != "" && !strings.HasPrefix(o.ClOrdID, f.Prefix) {
+		return false
+	}
+	if len(o.Legs) == 0 {
+		return f.matchSymbol(o.Symbol)
+	}
+	for _, l := range o.Legs {
+		if f.matchSymbol(l.Symbol) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSymbol applies the Symbol and Expiry fields to one instrument.
+func (f CancelFilter) matchSymbol(sym string) bool {
+	if f.Symbol != "" && sym != f.Symbol {
+		return false
+	}
+	if f.Expiry != "" {
+		m, ok := data.ResolveInstrument(sym)
+		if !ok || !strings.EqualFold(m.ExpiryLabel(), f.Expiry) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f CancelFilter) String() string {
+	return fmt.Sprintf("symbol=%q expiry=%q prefix=%q", f.Symbol, f.Expiry, f.Prefix)
+}
+
+// CancelAll cancels every tracked open order matching f and returns how many
+// cancels were sent. Orders already pending cancel are skipped.
+func CancelAll(f CancelFilter) (int, error) {
+	var errs []error
+	sent := 0
+	for _, o := range OpenOrders() {
+		if o.State == OrdPendingCancel || !f.match(o) {
+			continue
+		}
+		if _, err := CancelOrder(o.ClOrdID); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", o.ClOrdID, err))
+			continue
+		}
+		sent++
+	}
+	if sent > 0 || len(errs) > 0 {
+		log.Printf("[FIX-ORD] cancel all (%s): %d sent, %d failed", f, sent, len(errs))
+	}
+	return sent, errors.Join(errs...)
+}
+
+// cancelOnExit pulls every resting order before the session stops
+// (FIX_CANCEL_ON_EXIT=0 disables) and waits briefly for the acks.
+func cancelOnExit() {
+	if strings.TrimSpace(os.Getenv("FIX_CANCEL_ON_EXIT")) == "0" || supervisor.session.Load() == nil {
+		return
+	}
+	_, _ = CancelAll(CancelFilter{})
+	if err := MassCancel(""); err != nil {
+		log.Printf("[FIX-ORD] mass cancel on exit: %v", err)
+	}
+	deadline := time.Now().Add(time.Duration(envMs("FIX_CANCEL_WAIT_MS", 2_000)))
+	for time.Now().Before(deadline) && len(OpenOrders()) > 0 {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if n := len(OpenOrders()); n > 0 {
+		log.Printf("[FIX-ORD] %d orders still open at exit", n)
+	}
+}
+
+// onMassCancelReport logs an OrderMassCancelReport (35=r).
+func onMassCancelReport(msg *quickfix.Message) {
+	if getStr(msg, 531) == string(enum.MassCancelResponse_CANCEL_REQUEST_REJECTED) {
+		log.Printf("[FIX-ORD] mass cancel %s rejected: %s", getStr(msg, 11), rejectText(msg, 532))
+		return
+	}
+	log.Printf("[FIX-ORD] mass cancel %s done (affected=%s)", getStr(msg, 11), getStr(msg, 533))
+}
@@ -10,11 +10,10 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/quickfixgo/enum"
-	"github.com/quickfixgo/field"
-	"github.com/quickfixgo/fix44/marketdatarequest"
 	"github.com/quickfixgo/quickfix"
 	"github.com/quickfixgo/quickfix/store/file"
 )
@@ -22,28 +21,68 @@ import (
 // App implements quickfix.Application
 type App struct{}
 
-var optionSymbols []string
+// symbolTable is the immutable symbol <-> index mapping (O(1) lookup for HFT).
+// Subscribe/Unsubscribe replace it copy-on-write so FromApp reads it lock-free.
+type symbolTable struct {
+	byName map[string]int32
+	byIdx  []string // by data symbol index, "" = free slot
+}
+
+var (
+	symTab   atomic.Pointer[symbolTable]
+	symTabMu sync.Mutex // serializes table writers
+)
+
+func init() { symTab.Store(&symbolTable{byName: map[string]int32{}}) }
 
-// Optimized for HFT: symbol -> index mapping (O(1) lookup)
-var symbolToIndex map[string]int32
-var indexToSymbol [data.MaxOptions]string
+// active returns the subscribed symbols in index order.
+func (t *symbolTable) active() []string {
+	out := make([]string, 0, len(t.byName))
+	for _, s := range t.byIdx {
+		if s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// clone returns a writable copy sized for the data capacity.
+func (t *symbolTable) clone() *symbolTable {
+	n := &symbolTable{
+		byName: make(map[string]int32, len(t.byName)+8),
+		byIdx:  make([]string, len(t.byIdx), data.Capacity()),
+	}
+	for k, v := range t.byName {
+		n.byName[k] = v
+	}
+	copy(n.byIdx, t.byIdx)
+	return n
+}
 
 // SetOptionSymbols initializes symbol-index mappings for fast lookup.
+// Symbols beyond data.Capacity() are ignored. Startup only; use Subscribe /
+// Unsubscribe once the engine runs.
 func SetOptionSymbols(symbols []string) {
-	optionSymbols = symbols
+	if n := data.Capacity(); len(symbols) > n {
+		symbols = symbols[:n]
+	}
 
-	symbolToIndex = make(map[string]int32, len(symbols))
+	t := &symbolTable{
+		byName: make(map[string]int32, len(symbols)),
+		byIdx:  make([]string, len(symbols), data.Capacity()),
+	}
 	for i, sym := range symbols {
-		if i >= data.MaxOptions {
-			break
-		}
-		symbolToIndex[sym] = int32(i)
-		indexToSymbol[i] = sym
+		t.byName[sym] = int32(i)
+		t.byIdx[i] = sym
 	}
+	symTabMu.Lock()
+	symTab.Store(t)
+	symTabMu.Unlock()
+	supervisor.resize(data.Capacity())
 }
 
 func getSymbolIndex(symbol string) int32 {
-	if idx, ok := symbolToIndex[symbol]; ok {
+	if idx, ok := symTab.Load().byName[symbol]; ok {
 		return idx
 	}
 	return -1
@@ -52,47 +91,14 @@ func getSymbolIndex(symbol string) int32 {
 func (App) OnCreate(id quickfix.SessionID) {}
 
 // OnLogon: sends a MarketDataRequest for options + BTC index once logged in.
+// The market data supervisor owns the subscription from here on.
 func (App) OnLogon(id quickfix.SessionID) {
 	log.Println("[FIX] >>>> OnLogon received from server!")
-
-	// Create MarketDataRequest
-	mdReq := marketdatarequest.New(
-		field.NewMDReqID("BTC_OPTIONS"),
-		field.NewSubscriptionRequestType(enum.SubscriptionRequestType_SNAPSHOT_PLUS_UPDATES),
-		field.NewMarketDepth(1),
-	)
-	mdReq.Set(field.NewMDUpdateType(enum.MDUpdateType_INCREMENTAL_REFRESH))
-	mdReq.Set(field.NewAggregatedBook(true))
-
-	// Add MDEntryTypes (Bid + Offer)
-	mdEntryGroup := marketdatarequest.NewNoMDEntryTypesRepeatingGroup()
-	bidEntry := mdEntryGroup.Add()
-	bidEntry.Set(field.NewMDEntryType(enum.MDEntryType_BID))
-	askEntry := mdEntryGroup.Add()
-	askEntry.Set(field.NewMDEntryType(enum.MDEntryType_OFFER))
-	mdReq.SetGroup(mdEntryGroup)
-
-	// Add option symbols + BTC-USD Index symbol
-	symGroup := marketdatarequest.NewNoRelatedSymRepeatingGroup()
-	for _, sym := range optionSymbols {
-		entry := symGroup.Add()
-		entry.Set(field.NewSymbol(sym))
-	}
-
-	// Include BTC index
-	idxEntry := symGroup.Add()
-	idxEntry.Set(field.NewSymbol("BTC-DERIBIT-INDEX"))
-	mdReq.SetGroup(symGroup)
-
-	// Send request
-	if err := quickfix.SendToTarget(mdReq, id); err != nil {
-		log.Println("[FIX] MarketDataRequest send error:", err)
-	} else {
-		log.Println("[FIX] MarketDataRequest sent for options + BTC-USD Index")
-	}
+	supervisor.onLogon(id)
 }
 
-func (App) OnLogout(id quickfix.SessionID)                           {}
+func (App) OnLogout(id quickfix.SessionID) { supervisor.onLogout() }
+
 func (App) ToApp(msg *quickfix.Message, id quickfix.SessionID) error { return nil }
 
 // ToAdmin: custom login authentication handling.
@@ -129,16 +135,15 @@ func (App) FromAdmin(msg *quickfix.Message, id quickfix.SessionID) quickfix.Mess
 	return nil
 }
 
-// PriceLevel: lightweight struct for bid/ask price levels (optimized for HFT).
-type PriceLevel struct {
-	Price float64
-	Qty   float64
-}
-
-// FromApp: handles incoming market data messages.
+// FromApp: handles incoming market data and order-entry messages.
 func (app *App) FromApp(msg *quickfix.Message, id quickfix.SessionID) quickfix.MessageRejectError {
 	msgType, _ := msg.Header.GetString(quickfix.Tag(35))
 
+	// Order state: ExecutionReport / OrderCancelReject / MassCancelReport / BusinessMessageReject
+	if Deliver(msg) {
+		return nil
+	}
+
 	var idxPrice float64
 	foundIndex := false
 
@@ -147,43 +152,54 @@ func (app *App) FromApp(msg *quickfix.Message, id quickfix.SessionID) quickfix.M
 	if err := msg.Body.GetField(810, &idxField); err == nil {
 		idxPrice = float64(idxField)
 		data.SetIndexPrice(idxPrice)
+		supervisor.onIndexData(data.Nanotime())
 		foundIndex = true
 	}
 
+	// MarketDataRequestReject
+	if msgType == "Y" {
+		var reqID, reason, text quickfix.FIXString
+		_ = msg.Body.GetField(262, &reqID)
+		_ = msg.Body.GetField(281, &reason)
+		_ = msg.Body.GetField(58, &text)
+		supervisor.onReject(reqID.String(), reason.String(), text.String())
+		return nil
+	}
+
 	// Process Snapshot (W) or Incremental (X)
 	if msgType == "W" || msgType == "X" {
+		if t := rawMDTap.Load(); t != nil {
+			(*t).OnRawMD(msg.Bytes(), data.Nanotime())
+		}
 		if !foundIndex {
 			// Fallback: fast parse index price
 			idxPrice = parseIndexPriceFast(msg)
 			if idxPrice > 0 {
 				data.SetIndexPrice(idxPrice)
+				supervisor.onIndexData(data.Nanotime())
 				foundIndex = true
 			}
 		}
 
-		// Parse bid/ask updates
-		sym, bid, ask, bidQty, askQty, delBid, delAsk := fastParseHFT(msg, msgType)
+		sym := symbolOf(msg)
 
 		// Ignore index symbol
 		if len(sym) > 10 && (strings.HasPrefix(sym, "BTC-DERIBIT") || strings.HasPrefix(sym, "BTC-USD")) {
 			return nil
 		}
 
-		if sym != "" {
-			symbolIdx := getSymbolIndex(sym)
-			if symbolIdx >= 0 {
-				if !foundIndex {
-					idxPrice = data.GetIndexPrice()
-				}
-
-				if bid > 0 || delBid {
-					data.ApplyUpdateFast(symbolIdx, true, bid, bidQty, idxPrice)
-				}
-				if ask > 0 || delAsk {
-					data.ApplyUpdateFast(symbolIdx, false, ask, askQty, idxPrice)
-				}
-			}
+		symbolIdx := getSymbolIndex(sym)
+		if symbolIdx < 0 {
+			return nil
 		}
+		if !foundIndex {
+			idxPrice = data.GetIndexPrice()
+		}
+
+		// Apply every level to the L2 book, then publish the derived top of book
+		bidTouched, askTouched := applyBookHFT(msg, msgType, symbolIdx)
+		data.PublishBookFast(symbolIdx, bidTouched, askTouched, idxPrice)
+		supervisor.onSymbolData(symbolIdx, data.Nanotime())
 	}
 
 	return nil
@@ -198,7 +214,7 @@ func parseIndexPriceFast(msg *quickfix.Message) float64 {
 	}
 
 	sym := symField.String()
-	if sym != "BTC-DERIBIT-INDEX" {
+	if sym != indexSymbol {
 		return 0
 	}
 
@@ -231,18 +247,19 @@ func parseIndexPriceFast(msg *quickfix.Message) float64 {
 	return 0
 }
 
-// fastParseHFT: optimized parser for FIX messages (snapshot/incremental).
-func fastParseHFT(msg *quickfix.Message, msgType string) (string, float64, float64, float64, float64, bool, bool) {
-	var sym string
-	var bestBid, bestAsk, bidQty, askQty float64
-	var delBid, delAsk bool
-
-	// Fast extract symbol
+// symbolOf: fast extract of Symbol (55) from the message body.
+func symbolOf(msg *quickfix.Message) string {
 	var symField quickfix.FIXString
-	if err := msg.Body.GetField(55, &symField); err == nil {
-		sym = symField.String()
+	if err := msg.Body.GetField(55, &symField); err != nil {
+		return ""
 	}
+	return symField.String()
+}
 
+// applyBookHFT applies all MD entries of a snapshot (W) or incremental (X)
+// message to the L2 book of symbolIdx. It returns which sides were touched.
+// A snapshot rebuilds the book from scratch, so both sides are reported.
+func applyBookHFT(msg *quickfix.Message, msgType string, symbolIdx int32) (bidTouched, askTouched bool) {
 	// Group template selection
 	var group *quickfix.RepeatingGroup
 	switch msgType {
@@ -262,129 +279,61 @@ func fastParseHFT(msg *quickfix.Message, msgType string) (string, float64, float
 				quickfix.GroupElement(271),
 			})
 	default:
-		return sym, bestBid, bestAsk, bidQty, askQty, delBid, delAsk
+		return false, false
 	}
 
 	if err := msg.Body.GetGroup(group); err != nil {
-		return sym, bestBid, bestAsk, bidQty, askQty, delBid, delAsk
+		return false, false
 	}
 
-	switch msgType {
-	case "W": // Snapshot: collect levels, find best bid/ask
-		var bids [16]PriceLevel
-		var asks [16]PriceLevel
-		var bidCount, askCount int
-
-		for i := 0; i < group.Len() && i < 32; i++ {
-			entry := group.Get(i)
-
-			var mdType quickfix.FIXString
-			var price quickfix.FIXFloat
-			var size quickfix.FIXFloat
-
-			if entry.GetField(269, &mdType) != nil ||
-				entry.GetField(270, &price) != nil ||
-				entry.GetField(271, &size) != nil {
-				continue
-			}
+	if msgType == "W" {
+		data.ResetBookFast(symbolIdx)
+		bidTouched, askTouched = true, true
+	}
 
-			if mdType.String() == "3" {
-				continue
-			}
+	for i := 0; i < group.Len(); i++ {
+		entry := group.Get(i)
 
-			p := float64(price)
-			q := float64(size)
-
-			if p > 0 && q > 0 {
-				switch mdType.String() {
-				case "0": // Bid
-					if bidCount < 16 {
-						bids[bidCount] = PriceLevel{Price: p, Qty: q}
-						bidCount++
-					}
-				case "1": // Ask
-					if askCount < 16 {
-						asks[askCount] = PriceLevel{Price: p, Qty: q}
-						askCount++
-					}
-				}
-			}
-		}
+		var mdType quickfix.FIXString
+		var price quickfix.FIXFloat
+		var size quickfix.FIXFloat
+		var action quickfix.FIXString
 
-		if bidCount > 0 {
-			bestIdx := 0
-			for i := 1; i < bidCount; i++ {
-				if bids[i].Price > bids[bestIdx].Price {
-					bestIdx = i
-				}
-			}
-			bestBid = bids[bestIdx].Price
-			bidQty = bids[bestIdx].Qty
+		if entry.GetField(269, &mdType) != nil || entry.GetField(270, &price) != nil {
+			continue
 		}
 
-		if askCount > 0 {
-			bestIdx := 0
-			for i := 1; i < askCount; i++ {
-				if asks[i].Price < asks[bestIdx].Price {
-					bestIdx = i
-				}
-			}
-			bestAsk = asks[bestIdx].Price
-			askQty = asks[bestIdx].Qty
+		var isBid bool
+		switch mdType.String() {
+		case "0": // Bid
+			isBid = true
+		case "1": // Ask
+			isBid = false
+		default: // index (3) / trades etc.
+			continue
 		}
 
-	case "X": // Incremental: process updates directly
-		for i := 0; i < group.Len() && i < 8; i++ {
-			entry := group.Get(i)
-
-			var mdType quickfix.FIXString
-			var price quickfix.FIXFloat
-			var size quickfix.FIXFloat
-			var action quickfix.FIXString
-
-			if entry.GetField(269, &mdType) != nil ||
-				entry.GetField(270, &price) != nil ||
-				entry.GetField(271, &size) != nil {
-				continue
-			}
-
-			if mdType.String() == "3" {
-				continue
+		act := data.ActionNew // snapshot entries are plain levels
+		if msgType == "X" {
+			if entry.GetField(279, &action) == nil && len(action) > 0 {
+				act = action.String()[0]
 			}
+		}
 
-			entry.GetField(279, &action)
-
-			p := float64(price)
-			q := float64(size)
-
-			// Handle delete action
-			if action.String() == "2" {
-				q = 0
-				switch mdType.String() {
-				case "0":
-					delBid = true
-				case "1":
-					delAsk = true
-				}
-			}
+		// Size may be omitted on deletes
+		if entry.GetField(271, &size) != nil && act != data.ActionDelete {
+			continue
+		}
 
-			// Only keep first update (usually top of book)
-			switch mdType.String() {
-			case "0": // Bid
-				if bestBid == 0 {
-					bestBid = p
-					bidQty = q
-				}
-			case "1": // Ask
-				if bestAsk == 0 {
-					bestAsk = p
-					askQty = q
-				}
-			}
+		data.ApplyLevelFast(symbolIdx, isBid, act, float64(price), float64(size))
+		if isBid {
+			bidTouched = true
+		} else {
+			askTouched = true
 		}
 	}
 
-	return sym, bestBid, bestAsk, bidQty, askQty, delBid, delAsk
+	return bidTouched, askTouched
 }
 
 var initiator *quickfix.Initiator
@@ -416,11 +365,15 @@ func InitFIXEngine(cfgPath string) error {
 		return err
 	}
 	initiator = initr
+	throttle.configure()
+	supervisor.start()
 	return initiator.Start()
 }
 
-// StopFIXEngine stops the FIX initiator.
+// StopFIXEngine cancels resting orders and stops the FIX initiator.
 func StopFIXEngine() {
+	cancelOnExit()
+	supervisor.shutdown()
 	if initiator != nil {
 		initiator.Stop()
 	}
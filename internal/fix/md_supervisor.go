@@ -0,0 +1,484 @@
+package fix
+
+import (
+	"Options_Hedger/internal/data"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/field"
+	"github.com/quickfixgo/fix44/marketdatarequest"
+	"github.com/quickfixgo/quickfix"
+)
+
+const indexSymbol = "BTC-DERIBIT-INDEX"
+
+// MDReqState is the lifecycle of one MarketDataRequest (MDReqID).
+type MDReqState int32
+
+const (
+	MDReqPending   MDReqState = iota // sent, nothing received yet
+	MDReqStreaming                   // at least one W/X received
+	MDReqRejected                    // 35=Y received
+	MDReqReplaced                    // superseded by a resubscription
+)
+
+func (s MDReqState) String() string {
+	switch s {
+	case MDReqPending:
+		return "pending"
+	case MDReqStreaming:
+		return "streaming"
+	case MDReqRejected:
+		return "rejected"
+	case MDReqReplaced:
+		return "replaced"
+	}
+	return "unknown"
+}
+
+// MDRequestStatus is a read-only view of one MarketDataRequest.
+type MDRequestStatus struct {
+	ID        string
+	Symbols   int
+	State     MDReqState
+	SentNs    int64
+	LastMsgNs int64
+	Attempts  int
+	Reason    string
+}
+
+type mdRequest struct {
+	id       string
+	symbols  []string
+	withIdx  bool
+	sentNs   int64
+	lastNs   atomic.Int64
+	state    atomic.Int32
+	attempts int    // guarded by mdSupervisor.mu
+	retryAt  int64  // guarded by mdSupervisor.mu
+	reason   string // guarded by mdSupervisor.mu
+}
+
+// mdSupervisor watches market data health per MDReqID and per symbol,
+// handles MarketDataRequestReject (35=Y) and resubscribes selectively.
+// Symbol health is published through data.SetSymbolStale for strategies.
+// A symbol is stale when its current subscription delivered nothing within
+// staleNs, or when it went silent for silentNs after delivering; the longer
+// silence threshold keeps quiet far strikes live, and the resubscription's
+// snapshot revives a book that was only quiet. The index ticks continuously,
+// so it is resubscribed when it stops updating for staleNs.
+type mdSupervisor struct {
+	mu        sync.Mutex
+	reqs      map[string]*mdRequest
+	symOwner  []atomic.Pointer[mdRequest] // by symbol index
+	idxOwner  *mdRequest                  // guarded by mu, request carrying the index
+	symLast   []int64                     // atomic, last W/X per symbol (ns)
+	indexLast int64                       // atomic, last index update (ns)
+	anyData   int64                       // atomic, last W/X of any symbol (ns)
+	logonNs   int64                       // atomic, 0 while logged out
+	fullSubNs int64                       // guarded by mu, last full subscription
+	session   atomic.Pointer[quickfix.SessionID]
+	reqSeq    uint64
+
+	staleNs     int64
+	silentNs    int64
+	startupNs   int64
+	checkEvery  time.Duration
+	retryBaseNs int64
+	retryMaxNs  int64
+	maxAttempts int
+
+	stop chan struct{}
+}
+
+var supervisor = newMDSupervisor()
+
+func newMDSupervisor() *mdSupervisor {
+	return &mdSupervisor{reqs: make(map[string]*mdRequest)}
+}
+
+// configure reads FIX_MD_* settings (called on start, after .env is loaded).
+func (s *mdSupervisor) configure() {
+	s.staleNs = envMs("FIX_MD_STALE_MS", 60_000)
+	s.silentNs = envMs("FIX_MD_SILENT_MS", 300_000)
+	s.startupNs = envMs("FIX_MD_STARTUP_MS", 15_000)
+	s.checkEvery = time.Duration(envMs("FIX_MD_CHECK_MS", 1_000))
+	s.retryBaseNs = envMs("FIX_MD_RETRY_MS", 2_000)
+	s.retryMaxNs = 60 * int64(time.Second)
+	s.maxAttempts = envInt("FIX_MD_MAX_RETRIES", 5)
+}
+
+// envMs reads a millisecond setting and returns nanoseconds.
+func envMs(key string, defMs int64) int64 {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if x, err := strconv.ParseInt(v, 10, 64); err == nil && x > 0 {
+			defMs = x
+		}
+	}
+	return defMs * int64(time.Millisecond)
+}
+
+func envInt(key string, def int) int {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if x, err := strconv.Atoi(v); err == nil && x > 0 {
+			return x
+		}
+	}
+	return def
+}
+
+// resize allocates per-symbol tables (call from SetOptionSymbols).
+func (s *mdSupervisor) resize(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.symOwner = make([]atomic.Pointer[mdRequest], n)
+	s.symLast = make([]int64, n)
+}
+
+func (s *mdSupervisor) start() {
+	s.configure()
+	s.stop = make(chan struct{})
+	go s.run(s.stop)
+}
+
+func (s *mdSupervisor) shutdown() {
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+func (s *mdSupervisor) run(stop chan struct{}) {
+	t := time.NewTicker(s.checkEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			s.check(data.Nanotime())
+		}
+	}
+}
+
+// onLogon resets request state and sends the initial full subscription.
+func (s *mdSupervisor) onLogon(id quickfix.SessionID) {
+	now := data.Nanotime()
+	s.session.Store(&id)
+	atomic.StoreInt64(&s.logonNs, now)
+
+	s.mu.Lock()
+	s.reqs = make(map[string]*mdRequest)
+	s.idxOwner = nil
+	for i := range s.symOwner {
+		s.symOwner[i].Store(nil)
+		atomic.StoreInt64(&s.symLast[i], 0)
+	}
+	s.fullSubNs = now
+	s.mu.Unlock()
+
+	if err := s.subscribe("BTC_OPTIONS", symTab.Load().active(), true, 0); err != nil {
+		log.Println("[FIX] MarketDataRequest send error:", err)
+	} else {
+		log.Println("[FIX] MarketDataRequest sent for options + BTC-USD Index")
+	}
+}
+
+// onLogout marks every symbol stale: nothing streams without a session.
+func (s *mdSupervisor) onLogout() {
+	s.session.Store(nil)
+	atomic.StoreInt64(&s.logonNs, 0)
+	data.SetAllSymbolsStale()
+	log.Println("[FIX-MD] logged out: all symbols marked stale")
+}
+
+// onSymbolData is called from FromApp for every W/X of a subscribed option.
+func (s *mdSupervisor) onSymbolData(idx int32, now int64) {
+	atomic.StoreInt64(&s.anyData, now)
+	if int(idx) >= len(s.symLast) {
+		return
+	}
+	atomic.StoreInt64(&s.symLast[idx], now)
+	if r := s.symOwner[idx].Load(); r != nil {
+		r.lastNs.Store(now)
+		r.state.CompareAndSwap(int32(MDReqPending), int32(MDReqStreaming))
+	}
+	if !data.SymbolLive(idx) {
+		data.SetSymbolStale(idx, false)
+	}
+}
+
+// onIndexData is called from FromApp whenever the index price is refreshed.
+func (s *mdSupervisor) onIndexData(now int64) {
+	atomic.StoreInt64(&s.indexLast, now)
+	atomic.StoreInt64(&s.anyData, now)
+}
+
+// onReject handles MarketDataRequestReject (35=Y).
+func (s *mdSupervisor) onReject(reqID, reason, text string) {
+	now := data.Nanotime()
+	s.mu.Lock()
+	r, ok := s.reqs[reqID]
+	if !ok {
+		s.mu.Unlock()
+		log.Printf("[FIX-MD] reject for unknown MDReqID=%s reason=%s text=%q", reqID, reason, text)
+		return
+	}
+	r.state.Store(int32(MDReqRejected))
+	r.attempts++
+	r.reason = strings.TrimSpace(reason + " " + text)
+	r.retryAt = now + s.backoff(r.attempts)
+	s.mu.Unlock()
+
+	for _, sym := range r.symbols {
+		data.SetSymbolStale(getSymbolIndex(sym), true)
+	}
+	log.Printf("[FIX-MD] MDReqID=%s rejected (reason=%s text=%q, attempt %d/%d, %d symbols)",
+		reqID, reason, text, r.attempts, s.maxAttempts, len(r.symbols))
+}
+
+func (s *mdSupervisor) backoff(attempt int) int64 {
+	d := s.retryBaseNs
+	for i := 1; i < attempt && d < s.retryMaxNs; i++ {
+		d *= 2
+	}
+	if d > s.retryMaxNs {
+		d = s.retryMaxNs
+	}
+	return d
+}
+
+type resub struct {
+	symbols  []string
+	withIdx  bool
+	attempts int
+	cancel   map[*mdRequest][]string // owner -> symbols to unsubscribe first
+}
+
+// check runs on the supervisor ticker: startup silence, rejects, symbols
+// whose subscription never delivered or went silent, stale index.
+func (s *mdSupervisor) check(now int64) {
+	if s.session.Load() == nil || atomic.LoadInt64(&s.logonNs) == 0 {
+		return
+	}
+	var work []resub
+
+	s.mu.Lock()
+	// 1) Logged on (or fully resubscribed) but nothing streams at all
+	if atomic.LoadInt64(&s.anyData) < s.fullSubNs && now-s.fullSubNs > s.startupNs {
+		log.Printf("[FIX-MD] no market data %.1fs after subscribe: resubscribing all", float64(now-s.fullSubNs)/1e9)
+		s.fullSubNs = now
+		cancel := make(map[*mdRequest][]string)
+		for _, r := range s.reqs {
+			if MDReqState(r.state.Load()) != MDReqReplaced {
+				cancel[r] = r.symbols
+				r.state.Store(int32(MDReqReplaced))
+			}
+		}
+		work = append(work, resub{symbols: symTab.Load().active(), withIdx: true, cancel: cancel})
+	} else {
+		// 2) Rejected requests due for retry
+		for _, r := range s.reqs {
+			if MDReqState(r.state.Load()) != MDReqRejected || r.retryAt > now {
+				continue
+			}
+			if r.attempts >= s.maxAttempts {
+				continue
+			}
+			r.state.Store(int32(MDReqReplaced))
+			work = append(work, resub{symbols: r.symbols, withIdx: r.withIdx, attempts: r.attempts})
+		}
+
+		// 3) Symbols whose current subscription delivered no W/X within staleNs,
+		//    or that delivered and then stayed silent for silentNs
+		var stale []string
+		silent := 0
+		cancel := make(map[*mdRequest][]string)
+		for i, sym := range symTab.Load().byIdx {
+			if i >= len(s.symOwner) {
+				break
+			}
+			if sym == "" {
+				continue
+			}
+			owner := s.symOwner[i].Load()
+			if owner == nil || MDReqState(owner.state.Load()) == MDReqRejected {
+				continue
+			}
+			if last := atomic.LoadInt64(&s.symLast[i]); last >= owner.sentNs {
+				if now-last <= s.silentNs {
+					continue
+				}
+				silent++
+			} else if now-owner.sentNs <= s.staleNs {
+				continue
+			}
+			data.SetSymbolStale(int32(i), true)
+			stale = append(stale, sym)
+			cancel[owner] = append(cancel[owner], sym)
+		}
+		// 4) Index silent for staleNs since its last update or subscription
+		withIdx := false
+		if o := s.idxOwner; o != nil && MDReqState(o.state.Load()) != MDReqRejected {
+			base := atomic.LoadInt64(&s.indexLast)
+			if o.sentNs > base {
+				base = o.sentNs
+			}
+			if now-base > s.staleNs {
+				log.Printf("[FIX-MD] index silent for %ds: resubscribing", (now-base)/int64(time.Second))
+				withIdx = true
+				cancel[o] = append(cancel[o], indexSymbol)
+			}
+		}
+		if n := len(stale) - silent; n > 0 {
+			log.Printf("[FIX-MD] %d symbols without data >%ds after subscribe: resubscribing", n, s.staleNs/int64(time.Second))
+		}
+		if silent > 0 {
+			log.Printf("[FIX-MD] %d symbols silent >%ds: marked stale, resubscribing", silent, s.silentNs/int64(time.Second))
+		}
+		if len(stale) > 0 || withIdx {
+			work = append(work, resub{symbols: stale, withIdx: withIdx, cancel: cancel})
+		}
+	}
+	s.mu.Unlock()
+
+	for _, w := range work {
+		for owner, syms := range w.cancel {
+			s.unsubscribe(owner.id, syms)
+		}
+		if err := s.subscribe("", w.symbols, w.withIdx, w.attempts); err != nil {
+			log.Printf("[FIX-MD] resubscribe error: %v", err)
+		}
+	}
+}
+
+// subscribe sends a snapshot+updates MarketDataRequest and takes ownership
+// of the given symbols. An empty id allocates a fresh MDReqID.
+func (s *mdSupervisor) subscribe(id string, symbols []string, withIdx bool, attempts int) error {
+	sid := s.session.Load()
+	if sid == nil {
+		return fmt.Errorf("no FIX session")
+	}
+	if id == "" {
+		id = fmt.Sprintf("MD-%d", atomic.AddUint64(&s.reqSeq, 1))
+	}
+	r := &mdRequest{id: id, symbols: symbols, withIdx: withIdx, sentNs: data.Nanotime(), attempts: attempts}
+
+	s.mu.Lock()
+	s.reqs[id] = r
+	if withIdx {
+		s.idxOwner = r
+	}
+	for _, sym := range symbols {
+		if idx := getSymbolIndex(sym); idx >= 0 && int(idx) < len(s.symOwner) {
+			s.symOwner[idx].Store(r)
+		}
+	}
+	s.mu.Unlock()
+
+	req := newMDRequest(id, enum.SubscriptionRequestType_SNAPSHOT_PLUS_UPDATES, symbols, withIdx)
+	return quickfix.SendToTarget(req, *sid)
+}
+
+// release drops ownership of symbols and unsubscribes them from their
+// current MDReqIDs (runtime Unsubscribe). Indexes must still be mapped.
+func (s *mdSupervisor) release(symbols []string) {
+	cancel := make(map[string][]string)
+	s.mu.Lock()
+	for _, sym := range symbols {
+		idx := getSymbolIndex(sym)
+		if idx < 0 || int(idx) >= len(s.symOwner) {
+			continue
+		}
+		if owner := s.symOwner[idx].Swap(nil); owner != nil {
+			cancel[owner.id] = append(cancel[owner.id], sym)
+		}
+		atomic.StoreInt64(&s.symLast[idx], 0)
+	}
+	s.mu.Unlock()
+	for id, syms := range cancel {
+		s.unsubscribe(id, syms)
+	}
+}
+
+// unsubscribe sends SubscriptionRequestType=2 for symbols of an MDReqID.
+func (s *mdSupervisor) unsubscribe(id string, symbols []string) {
+	sid := s.session.Load()
+	if sid == nil || len(symbols) == 0 {
+		return
+	}
+	req := newMDRequest(id, enum.SubscriptionRequestType_DISABLE_PREVIOUS_SNAPSHOT_PLUS_UPDATE_REQUEST, symbols, false)
+	if err := quickfix.SendToTarget(req, *sid); err != nil {
+		log.Printf("[FIX-MD] unsubscribe %s error: %v", id, err)
+	}
+}
+
+// newMDRequest builds a bid/offer MarketDataRequest for the given symbols.
+func newMDRequest(id string, subType enum.SubscriptionRequestType, symbols []string, withIdx bool) *marketdatarequest.MarketDataRequest {
+	mdReq := marketdatarequest.New(
+		field.NewMDReqID(id),
+		field.NewSubscriptionRequestType(subType),
+		field.NewMarketDepth(data.BookDepth()),
+	)
+	mdReq.Set(field.NewMDUpdateType(enum.MDUpdateType_INCREMENTAL_REFRESH))
+	mdReq.Set(field.NewAggregatedBook(true))
+
+	// Add MDEntryTypes (Bid + Offer)
+	mdEntryGroup := marketdatarequest.NewNoMDEntryTypesRepeatingGroup()
+	bidEntry := mdEntryGroup.Add()
+	bidEntry.Set(field.NewMDEntryType(enum.MDEntryType_BID))
+	askEntry := mdEntryGroup.Add()
+	askEntry.Set(field.NewMDEntryType(enum.MDEntryType_OFFER))
+	mdReq.SetGroup(mdEntryGroup)
+
+	symGroup := marketdatarequest.NewNoRelatedSymRepeatingGroup()
+	for _, sym := range symbols {
+		entry := symGroup.Add()
+		entry.Set(field.NewSymbol(sym))
+	}
+	if withIdx {
+		idxEntry := symGroup.Add()
+		idxEntry.Set(field.NewSymbol(indexSymbol))
+	}
+	mdReq.SetGroup(symGroup)
+	return &mdReq
+}
+
+// MDStatus returns the state of every MarketDataRequest of the session.
+func MDStatus() []MDRequestStatus {
+	s := supervisor
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]MDRequestStatus, 0, len(s.reqs))
+	for _, r := range s.reqs {
+		out = append(out, MDRequestStatus{
+			ID:        r.id,
+			Symbols:   len(r.symbols),
+			State:     MDReqState(r.state.Load()),
+			SentNs:    r.sentNs,
+			LastMsgNs: r.lastNs.Load(),
+			Attempts:  r.attempts,
+			Reason:    r.reason,
+		})
+	}
+	return out
+}
+
+// SymbolLastUpdateNs returns the last W/X receive time of a symbol (0 = never).
+func SymbolLastUpdateNs(idx int32) int64 {
+	s := supervisor
+	if idx < 0 || int(idx) >= len(s.symLast) {
+		return 0
+	}
+	return atomic.LoadInt64(&s.symLast[idx])
+}
+
+// IndexLastUpdateNs returns the last index price receive time (0 = never).
+func IndexLastUpdateNs() int64 { return atomic.LoadInt64(&supervisor.indexLast) }
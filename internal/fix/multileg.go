@@ -0,0 +1,155 @@
+package fix
+
+import (
+	"strings"
+	"time"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/field"
+	"github.com/quickfixgo/fix44/newordermultileg"
+	"github.com/quickfixgo/quickfix"
+)
+
+// MultilegLeg is one leg of a combo, expressed for a BUY of the combo. On a
+// tracked order LastPx (last fill) and AvgPx are the leg's fill prices
+// (LegLastPx 637), 0 when the exchange did not report one.
+type MultilegLeg struct {
+	Symbol string
+	Side   enum.Side
+	Ratio  float64 // usually 1
+	LastPx float64
+	AvgPx  float64
+}
+
+// MultilegReq is a limit order on a multileg combo (NewOrderMultileg 35=AB).
+// Price is the net combo price per unit (debit for BUY, credit for SELL).
+type MultilegReq struct {
+	Legs        []MultilegLeg
+	Side        enum.Side
+	Price       float64
+	Qty         float64
+	TIF         enum.TimeInForce // usually IOC
+	ClOrdPrefix string
+	ClOrdID     string // generated when empty
+}
+
+// SendMultileg sends a NewOrderMultileg and tracks it like a single order;
+// Order.Symbol is the legs joined with "/".
+func SendMultileg(req *MultilegReq) (string, error) {
+	if err := checkPreTrade(OrderCheck{Side: req.Side, Price: req.Price, Qty: req.Qty, Legs: req.Legs}); err != nil {
+		return "", err
+	}
+	pfx := req.ClOrdPrefix
+	if pfx == "" {
+		pfx = "ML"
+	}
+	if req.ClOrdID == "" {
+		req.ClOrdID = newClOrdID(pfx)
+	}
+	tif := req.TIF
+	if tif == "" {
+		tif = enum.TimeInForce_IMMEDIATE_OR_CANCEL
+	}
+	if err := throttle.acquire(reqOrder, tif == enum.TimeInForce_IMMEDIATE_OR_CANCEL); err != nil {
+		return "", err
+	}
+
+	ord := newordermultileg.New(
+		field.NewClOrdID(req.ClOrdID),
+		field.NewSide(req.Side),
+		field.NewTransactTime(time.Now()),
+		field.NewOrdType(enum.OrdType_LIMIT),
+	)
+	ord.SetTimeInForce(tif)
+	ord.SetOrderQty(fixDec(req.Qty))
+	ord.SetPrice(fixDec(req.Price))
+
+	legs := newordermultileg.NewNoLegsRepeatingGroup()
+	for _, l := range req.Legs {
+		g := legs.Add()
+		g.SetLegSymbol(l.Symbol)
+		g.SetLegSide(string(l.Side))
+		ratio := l.Ratio
+		if ratio <= 0 {
+			ratio = 1
+		}
+		g.SetLegRatioQty(fixDec(ratio))
+	}
+	ord.SetNoLegs(legs)
+
+	orders.track(Order{
+		ClOrdID: req.ClOrdID,
+		Symbol:  legNames(req.Legs),
+		Side:    req.Side,
+		Price:   req.Price,
+		Qty:     req.Qty,
+		TIF:     tif,
+		Prefix:  pfx,
+		Legs:    append([]MultilegLeg(nil), req.Legs...),
+	})
+	if err := sendToSession(ord); err != nil {
+		orders.sendFailed(req.ClOrdID, err)
+		return req.ClOrdID, err
+	}
+	return req.ClOrdID, nil
+}
+
+// legNames is the local name of a combo: its leg symbols joined with "/".
+func legNames(legs []MultilegLeg) string {
+	names := make([]string, len(legs))
+	for i, l := range legs {
+		names[i] = l.Symbol
+	}
+	return strings.Join(names, "/")
+}
+
+// legGroup parses the NoLegs (555) group of an execution report.
+func legGroup() *quickfix.RepeatingGroup {
+	return quickfix.NewRepeatingGroup(555, quickfix.GroupTemplate{
+		quickfix.GroupElement(600),  // LegSymbol
+		quickfix.GroupElement(654),  // LegRefID
+		quickfix.GroupElement(623),  // LegRatioQty
+		quickfix.GroupElement(624),  // LegSide
+		quickfix.GroupElement(566),  // LegPrice
+		quickfix.GroupElement(687),  // LegQty
+		quickfix.GroupElement(637),  // LegLastPx
+		quickfix.GroupElement(1418), // LegLastQty
+	})
+}
+
+// legFills returns legs with the leg prices of a fill of last units applied
+// (cum includes them). legs is shared with earlier snapshots, so a priced
+// fill gets a copy; unpriced legs keep their previous values.
+func legFills(msg *quickfix.Message, legs []MultilegLeg, cum, last float64) []MultilegLeg {
+	g := legGroup()
+	if last <= 0 || cum <= 0 || msg.Body.GetGroup(g) != nil {
+		return legs
+	}
+	out := append([]MultilegLeg(nil), legs...)
+	for j := range out {
+		out[j].LastPx = 0 // priced by this report only
+	}
+	for i := 0; i < g.Len(); i++ {
+		e := g.Get(i)
+		sym, err := e.GetString(600)
+		if err != nil {
+			continue
+		}
+		var px quickfix.FIXFloat
+		if e.GetField(637, &px) != nil {
+			continue
+		}
+		for j := range out {
+			if l := &out[j]; l.Symbol == sym {
+				l.LastPx = float64(px)
+				if l.AvgPx == 0 {
+					l.AvgPx = l.LastPx
+				} else {
+					l.AvgPx = (l.AvgPx*(cum-last) + l.LastPx*last) / cum
+				}
+				break
+			}
+		}
+	}
+	return out
+}
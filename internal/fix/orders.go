@@ -0,0 +1,628 @@
+package fix
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/quickfix"
+)
+
+// OrdState is the lifecycle state of a tracked order (FIX OrdStatus 39).
+type OrdState uint8
+
+const (
+	OrdPendingNew OrdState = iota // sent, no ExecutionReport yet
+	OrdNew
+	OrdPartiallyFilled
+	OrdFilled
+	OrdCanceled
+	OrdRejected
+	OrdExpired
+	OrdPendingCancel
+	OrdPendingReplace
+)
+
+func (s OrdState) String() string {
+	switch s {
+	case OrdPendingNew:
+		return "PENDING_NEW"
+	case OrdNew:
+		return "NEW"
+	case OrdPartiallyFilled:
+		return "PARTIALLY_FILLED"
+	case OrdFilled:
+		return "FILLED"
+	case OrdCanceled:
+		return "CANCELED"
+	case OrdRejected:
+		return "REJECTED"
+	case OrdExpired:
+		return "EXPIRED"
+	case OrdPendingCancel:
+		return "PENDING_CANCEL"
+	case OrdPendingReplace:
+		return "PENDING_REPLACE"
+	}
+	return "UNKNOWN"
+}
+
+// Terminal reports whether no further fills can happen.
+func (s OrdState) Terminal() bool {
+	return s == OrdFilled || s == OrdCanceled || s == OrdRejected || s == OrdExpired
+}
+
+// Order is a snapshot of one tracked order.
+type Order struct {
+	ClOrdID      string
+	OrigClOrdID  string // previous ClOrdID after a replace
+	OrderID      string // exchange order id (37)
+	Symbol       string
+	Side         enum.Side
+	Price        float64
+	Qty          float64
+	TIF          enum.TimeInForce
+	State        OrdState
+	CumQty       float64
+	LeavesQty    float64
+	AvgPx        float64
+	LastQty      float64 // last fill
+	LastPx       float64
+	LastFee      float64       // commission (12) of the last fill, BTC
+	Fees         float64       // cumulative commission, BTC
+	LastExecID   string        // ExecID (17) of the last report
+	Legs         []MultilegLeg // multileg orders only (read-only, replaced per fill)
+	RejectReason string        // text of the last reject (order or cancel)
+	Prefix       string        // ClOrdPrefix of the strategy that sent it
+	SentAt       time.Time
+	UpdatedAt    time.Time
+}
+
+// prefix returns the ClOrdID prefix for follow-up requests.
+func (o Order) prefix() string {
+	if o.Prefix == "" {
+		return "ORD"
+	}
+	return o.Prefix
+}
+
+// OrderEventKind classifies an order update.
+type OrderEventKind uint8
+
+const (
+	EvAccepted       OrderEventKind = iota // 150=0
+	EvFill                                 // 150=F (partial or full)
+	EvCanceled                             // 150=4
+	EvReplaced                             // 150=5
+	EvRejected                             // 150=8 or send failure
+	EvExpired                              // 150=C
+	EvPending                              // 150=6/E/A
+	EvStatus                               // 150=I and other reports
+	EvCancelRejected                       // 35=9
+)
+
+func (k OrderEventKind) String() string {
+	return [...]string{"ACCEPTED", "FILL", "CANCELED", "REPLACED", "REJECTED", "EXPIRED", "PENDING", "STATUS", "CANCEL_REJECTED"}[k]
+}
+
+// OrderEvent is delivered to callbacks and subscription channels.
+type OrderEvent struct {
+	Kind  OrderEventKind
+	Order Order
+}
+
+// orderManager tracks orders by ClOrdID from ExecutionReports (35=8) and
+// OrderCancelReject (35=9). Terminal orders are pruned after retention.
+type orderManager struct {
+	mu        sync.Mutex
+	byClOrdID map[string]*Order
+	alias     map[string]string // replaced / cancel-request ClOrdID -> current ClOrdID
+	inserts   int
+	execs     map[string]struct{} // fill ExecIDs already applied (resends)
+	execOrder []string
+
+	lmu       sync.Mutex
+	listeners atomic.Pointer[[]orderListener]
+	nextID    int
+	dropped   uint64 // atomic, events dropped on full channels
+
+	retention time.Duration
+}
+
+type orderListener struct {
+	id int
+	fn func(OrderEvent)
+}
+
+var orders = newOrderManager()
+
+const maxExecIDs = 4096
+
+func newOrderManager() *orderManager {
+	m := &orderManager{
+		byClOrdID: make(map[string]*Order),
+		alias:     make(map[string]string),
+		execs:     make(map[string]struct{}),
+		retention: time.Hour,
+	}
+	m.listeners.Store(&[]orderListener{})
+	return m
+}
+
+// GetOrder returns the order for a ClOrdID (current or replaced one).
+func GetOrder(clOrdID string) (Order, bool) {
+	orders.mu.Lock()
+	defer orders.mu.Unlock()
+	if o := orders.lookup(clOrdID); o != nil {
+		return *o, true
+	}
+	return Order{}, false
+}
+
+// OpenOrders returns every order that is not in a terminal state.
+func OpenOrders() []Order {
+	orders.mu.Lock()
+	defer orders.mu.Unlock()
+	out := make([]Order, 0, len(orders.byClOrdID))
+	for _, o := range orders.byClOrdID {
+		if !o.State.Terminal() {
+			out = append(out, *o)
+		}
+	}
+	return out
+}
+
+// OnOrderEvent registers fn for every order update and returns a function
+// removing it. fn runs on the FIX goroutine and must not block.
+func OnOrderEvent(fn func(OrderEvent)) (remove func()) {
+	m := orders
+	m.lmu.Lock()
+	m.nextID++
+	id := m.nextID
+	cur := *m.listeners.Load()
+	next := append(append(make([]orderListener, 0, len(cur)+1), cur...), orderListener{id, fn})
+	m.listeners.Store(&next)
+	m.lmu.Unlock()
+
+	return func() {
+		m.lmu.Lock()
+		defer m.lmu.Unlock()
+		cur := *m.listeners.Load()
+		next := make([]orderListener, 0, len(cur))
+		for _, l := range cur {
+			if l.id != id {
+				next = append(next, l)
+			}
+		}
+		m.listeners.Store(&next)
+	}
+}
+
+// SubscribeOrders returns a buffered channel of order updates. Events are
+// dropped (and counted) when the channel is full; cancel stops delivery.
+func SubscribeOrders(buf int) (<-chan OrderEvent, func()) {
+	ch := make(chan OrderEvent, buf)
+	remove := OnOrderEvent(func(ev OrderEvent) {
+		select {
+		case ch <- ev:
+		default:
+			if n := atomic.AddUint64(&orders.dropped, 1); n&(n-1) == 0 {
+				log.Printf("[FIX-ORD] subscriber full, dropped %d events", n)
+			}
+		}
+	})
+	return ch, remove
+}
+
+// WaitOrder blocks until the order reaches a terminal state or ctx ends,
+// and returns its latest snapshot.
+func WaitOrder(ctx context.Context, clOrdID string) (Order, error) {
+	done := make(chan Order, 1)
+	remove := OnOrderEvent(func(ev OrderEvent) {
+		if !ev.Order.State.Terminal() {
+			return
+		}
+		if ev.Order.ClOrdID == clOrdID || ev.Order.OrigClOrdID == clOrdID {
+			select {
+			case done <- ev.Order:
+			default:
+			}
+		}
+	})
+	defer remove()
+
+	o, ok := GetOrder(clOrdID)
+	if !ok {
+		return Order{}, ErrUnknownOrder
+	}
+	if o.State.Terminal() {
+		return o, nil
+	}
+	select {
+	case o = <-done:
+		return o, nil
+	case <-ctx.Done():
+		o, _ = GetOrder(clOrdID)
+		return o, ctx.Err()
+	}
+}
+
+// lookup resolves a ClOrdID through aliases (caller holds mu).
+func (m *orderManager) lookup(id string) *Order {
+	if o, ok := m.byClOrdID[id]; ok {
+		return o
+	}
+	if cur, ok := m.alias[id]; ok {
+		return m.byClOrdID[cur]
+	}
+	return nil
+}
+
+// track registers an order about to be sent.
+func (m *orderManager) track(o Order) {
+	now := time.Now()
+	o.State, o.LeavesQty, o.SentAt, o.UpdatedAt = OrdPendingNew, o.Qty, now, now
+	m.mu.Lock()
+	m.byClOrdID[o.ClOrdID] = &o
+	m.inserts++
+	if m.inserts&255 == 0 {
+		m.prune(now)
+	}
+	m.mu.Unlock()
+}
+
+// prune drops terminal orders older than retention (caller holds mu).
+func (m *orderManager) prune(now time.Time) {
+	for id, o := range m.byClOrdID {
+		if o.State.Terminal() && now.Sub(o.UpdatedAt) > m.retention {
+			delete(m.byClOrdID, id)
+		}
+	}
+	for a, cur := range m.alias {
+		if _, ok := m.byClOrdID[cur]; !ok {
+			delete(m.alias, a)
+		}
+	}
+}
+
+// markPending flags a cancel/replace in flight and aliases its ClOrdID to
+// the order. It returns the previous state for restoreState.
+func (m *orderManager) markPending(clOrdID, reqID string, st OrdState) OrdState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o := m.lookup(clOrdID)
+	if o == nil {
+		return st
+	}
+	prev := o.State
+	o.State, o.UpdatedAt = st, time.Now()
+	m.alias[reqID] = o.ClOrdID
+	return prev
+}
+
+// restoreState undoes markPending when the request could not be sent.
+func (m *orderManager) restoreState(clOrdID string, st OrdState) {
+	m.mu.Lock()
+	if o := m.lookup(clOrdID); o != nil && (o.State == OrdPendingCancel || o.State == OrdPendingReplace) {
+		o.State = st
+	}
+	m.mu.Unlock()
+}
+
+// sendFailed marks an order rejected locally when the session refused it.
+func (m *orderManager) sendFailed(clOrdID string, err error) {
+	m.mu.Lock()
+	o := m.lookup(clOrdID)
+	if o == nil {
+		m.mu.Unlock()
+		return
+	}
+	o.State, o.LeavesQty, o.RejectReason, o.UpdatedAt = OrdRejected, 0, err.Error(), time.Now()
+	snap := *o
+	m.mu.Unlock()
+	m.emit(OrderEvent{Kind: EvRejected, Order: snap})
+}
+
+func (m *orderManager) emit(ev OrderEvent) {
+	for _, l := range *m.listeners.Load() {
+		l.fn(ev)
+	}
+}
+
+// onExecutionReport applies a 35=8 message.
+func (m *orderManager) onExecutionReport(msg *quickfix.Message) {
+	clOrdID := getStr(msg, 11)
+	if clOrdID == "" {
+		return
+	}
+	origID := getStr(msg, 41)
+	execType := getStr(msg, 150)
+	status := getStr(msg, 39)
+
+	m.mu.Lock()
+	o := m.lookup(clOrdID)
+	if o == nil && origID != "" {
+		o = m.lookup(origID)
+	}
+	if o == nil {
+		// Not sent by this process (other session / restart): track it anyway
+		o = &Order{ClOrdID: clOrdID, SentAt: time.Now()}
+		m.byClOrdID[clOrdID] = o
+	}
+
+	// Replace acknowledged: re-key under the new ClOrdID
+	if execType == "5" && o.ClOrdID != clOrdID {
+		delete(m.byClOrdID, o.ClOrdID)
+		m.alias[o.ClOrdID] = clOrdID
+		o.OrigClOrdID, o.ClOrdID = o.ClOrdID, clOrdID
+		m.byClOrdID[clOrdID] = o
+	}
+
+	if v := getStr(msg, 37); v != "" {
+		o.OrderID = v
+	}
+	if v := getStr(msg, 55); v != "" {
+		o.Symbol = v
+	}
+	if v := getStr(msg, 54); v != "" {
+		o.Side = enum.Side(v)
+	}
+	if v, ok := getFloat(msg, 44); ok {
+		o.Price = v
+	}
+	if v, ok := getFloat(msg, 38); ok {
+		o.Qty = v
+	}
+	if v, ok := getFloat(msg, 14); ok {
+		o.CumQty = v
+	}
+	if v, ok := getFloat(msg, 151); ok {
+		o.LeavesQty = v
+	}
+	if v, ok := getFloat(msg, 6); ok {
+		o.AvgPx = v
+	}
+	if st, ok := ordStateOf(status); ok {
+		o.State = st
+	}
+
+	o.LastExecID = getStr(msg, 17)
+	kind := EvStatus
+	switch execType {
+	case "0":
+		kind = EvAccepted
+	case "F", "1", "2":
+		if !m.firstExec(o.LastExecID) {
+			break // resent fill: already counted
+		}
+		kind = EvFill
+		o.LastQty, _ = getFloat(msg, 32)
+		o.LastPx, _ = getFloat(msg, 31)
+		o.LastFee, _ = getFloat(msg, 12)
+		o.Fees += o.LastFee
+		if len(o.Legs) > 0 {
+			o.Legs = legFills(msg, o.Legs, o.CumQty, o.LastQty)
+		}
+	case "4":
+		kind = EvCanceled
+	case "5":
+		kind = EvReplaced
+	case "8":
+		kind = EvRejected
+		o.RejectReason = rejectText(msg, 103)
+	case "C":
+		kind = EvExpired
+	case "6", "E", "A":
+		kind = EvPending
+	}
+	o.UpdatedAt = time.Now()
+	snap := *o
+	m.mu.Unlock()
+
+	if kind == EvRejected {
+		log.Printf("[FIX-ORD] %s %s rejected: %s", snap.ClOrdID, snap.Symbol, snap.RejectReason)
+	}
+	m.emit(OrderEvent{Kind: kind, Order: snap})
+}
+
+// firstExec records a fill ExecID and reports whether it is new (caller
+// holds mu). Reports without an ExecID are always applied.
+func (m *orderManager) firstExec(id string) bool {
+	if id == "" {
+		return true
+	}
+	if _, dup := m.execs[id]; dup {
+		return false
+	}
+	m.execs[id] = struct{}{}
+	m.execOrder = append(m.execOrder, id)
+	if len(m.execOrder) > maxExecIDs {
+		delete(m.execs, m.execOrder[0])
+		m.execOrder = m.execOrder[1:]
+	}
+	return true
+}
+
+// onCancelReject applies a 35=9 message to the order it refers to.
+func (m *orderManager) onCancelReject(msg *quickfix.Message) {
+	clOrdID, origID := getStr(msg, 11), getStr(msg, 41)
+
+	m.mu.Lock()
+	o := m.lookup(origID)
+	if o == nil {
+		o = m.lookup(clOrdID)
+	}
+	if o == nil {
+		m.mu.Unlock()
+		log.Printf("[FIX-ORD] cancel reject for unknown order %s/%s: %s", clOrdID, origID, rejectText(msg, 102))
+		return
+	}
+	if st, ok := ordStateOf(getStr(msg, 39)); ok {
+		o.State = st
+	} else if o.State == OrdPendingCancel || o.State == OrdPendingReplace {
+		o.State = OrdNew
+		if o.CumQty > 0 {
+			o.State = OrdPartiallyFilled
+		}
+	}
+	o.RejectReason = rejectText(msg, 102)
+	o.UpdatedAt = time.Now()
+	snap := *o
+	m.mu.Unlock()
+
+	log.Printf("[FIX-ORD] cancel/replace of %s rejected: %s", snap.ClOrdID, snap.RejectReason)
+	m.emit(OrderEvent{Kind: EvCancelRejected, Order: snap})
+}
+
+// onBusinessReject applies a BusinessMessageReject (35=j) that refers to
+// one of our orders (BusinessRejectRefID 379 = ClOrdID).
+func (m *orderManager) onBusinessReject(msg *quickfix.Message) {
+	ref := getStr(msg, 379)
+	m.mu.Lock()
+	o := m.lookup(ref)
+	if o == nil || o.State.Terminal() {
+		m.mu.Unlock()
+		log.Printf("[FIX-ORD] business reject ref=%s: %s", ref, rejectText(msg, 380))
+		return
+	}
+	o.State, o.LeavesQty, o.RejectReason, o.UpdatedAt = OrdRejected, 0, rejectText(msg, 380), time.Now()
+	snap := *o
+	m.mu.Unlock()
+
+	log.Printf("[FIX-ORD] %s %s rejected: %s", snap.ClOrdID, snap.Symbol, snap.RejectReason)
+	m.emit(OrderEvent{Kind: EvRejected, Order: snap})
+}
+
+// ordStateOf maps OrdStatus (39).
+func ordStateOf(s string) (OrdState, bool) {
+	switch s {
+	case "0":
+		return OrdNew, true
+	case "1":
+		return OrdPartiallyFilled, true
+	case "2":
+		return OrdFilled, true
+	case "4":
+		return OrdCanceled, true
+	case "6":
+		return OrdPendingCancel, true
+	case "8":
+		return OrdRejected, true
+	case "A":
+		return OrdPendingNew, true
+	case "C":
+		return OrdExpired, true
+	case "E":
+		return OrdPendingReplace, true
+	}
+	return 0, false
+}
+
+// rejectText combines Text (58) with a reason code tag (103 / 102).
+func rejectText(msg *quickfix.Message, codeTag quickfix.Tag) string {
+	text, code := getStr(msg, 58), getStr(msg, codeTag)
+	switch {
+	case text != "" && code != "":
+		return text + " (" + code + ")"
+	case text != "":
+		return text
+	case code != "":
+		return "reason " + code
+	}
+	return "unknown"
+}
+
+func getStr(msg *quickfix.Message, tag quickfix.Tag) string {
+	var f quickfix.FIXString
+	if err := msg.Body.GetField(tag, &f); err != nil {
+		return ""
+	}
+	return f.String()
+}
+
+func getFloat(msg *quickfix.Message, tag quickfix.Tag) (float64, bool) {
+	var f quickfix.FIXFloat
+	if err := msg.Body.GetField(tag, &f); err != nil {
+		return 0, false
+	}
+	return float64(f), true
+}
+
+// ErrNoSession is returned when no FIX session is logged on.
+var ErrNoSession = errors.New("fix: no session logged on")
+
+// LoggedOn reports whether the FIX session is currently logged on.
+func LoggedOn() bool { return supervisor.session.Load() != nil }
+
+// sendToSession sends an order-entry message on the logged-on session, or
+// through the installed OrderTransport.
+func sendToSession(m quickfix.Messagable) error {
+	if t := transport.Load(); t != nil {
+		return (*t).SendOrderMsg(m.ToMessage())
+	}
+	sid := supervisor.session.Load()
+	if sid == nil {
+		return ErrNoSession
+	}
+	return quickfix.SendToTarget(m, *sid)
+}
+
+// OrderCheck describes an outgoing order for the pre-trade hook. Multileg
+// orders carry Legs and a net Price; cancels are never checked.
+type OrderCheck struct {
+	Symbol     string
+	Side       enum.Side
+	Price      float64
+	Qty        float64
+	Replace    bool // cancel/replace of a resting order
+	Reduce     bool // caller claims the order only reduces a position
+	Legs       []MultilegLeg
+	OpenOrders int // currently open tracked orders
+}
+
+// PreTradeCheck validates orders before they reach the FIX session.
+type PreTradeCheck interface {
+	CheckOrder(OrderCheck) error
+}
+
+var preTrade atomic.Pointer[PreTradeCheck]
+
+// SetPreTradeCheck installs the pre-trade hook (nil removes it).
+func SetPreTradeCheck(c PreTradeCheck) {
+	if c == nil {
+		preTrade.Store(nil)
+		return
+	}
+	preTrade.Store(&c)
+}
+
+// checkPreTrade runs the hook; a violation is logged and returned as is.
+func checkPreTrade(c OrderCheck) error {
+	p := preTrade.Load()
+	if p == nil {
+		return nil
+	}
+	c.OpenOrders = orders.openCount()
+	if err := (*p).CheckOrder(c); err != nil {
+		log.Printf("[FIX-ORD] pre-trade reject %s %s %g@%g: %v", sideToStr(c.Side), c.Symbol, c.Qty, c.Price, err)
+		return err
+	}
+	return nil
+}
+
+// openCount returns the number of non-terminal orders.
+func (m *orderManager) openCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, o := range m.byClOrdID {
+		if !o.State.Terminal() {
+			n++
+		}
+	}
+	return n
+}
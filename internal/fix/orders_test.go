@@ -0,0 +1,131 @@
+package fix
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/quickfix"
+)
+
+// report builds an inbound message with the given body fields.
+func report(fields map[quickfix.Tag]string) *quickfix.Message {
+	m := quickfix.NewMessage()
+	for tag, v := range fields {
+		m.Body.SetString(tag, v)
+	}
+	return m
+}
+
+// er is an ExecutionReport (35=8) for ClOrdID "C1".
+func er(execType, status, execID string, cum, last, px float64) *quickfix.Message {
+	f := map[quickfix.Tag]string{11: "C1", 37: "X1", 150: execType, 39: status, 17: execID}
+	if cum > 0 {
+		f[14] = fmtFloat(cum)
+		f[151] = fmtFloat(2 - cum)
+		f[6] = fmtFloat(px)
+	}
+	if last > 0 {
+		f[32], f[31], f[12] = fmtFloat(last), fmtFloat(px), "0.0003"
+	}
+	return report(f)
+}
+
+func fmtFloat(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+func TestOrderTransitions(t *testing.T) {
+	type step struct {
+		msg   *quickfix.Message
+		cxRej bool // apply as OrderCancelReject (35=9)
+		kind  OrderEventKind
+		state OrdState
+		cum   float64
+		fees  float64
+	}
+	tests := []struct {
+		name    string
+		pending OrdState // marked before the steps (0 = none)
+		steps   []step
+	}{
+		{
+			name: "accept, partial, full",
+			steps: []step{
+				{msg: er("0", "0", "E0", 0, 0, 0), kind: EvAccepted, state: OrdNew},
+				{msg: er("F", "1", "E1", 1, 1, 0.01), kind: EvFill, state: OrdPartiallyFilled, cum: 1, fees: 0.0003},
+				{msg: er("F", "2", "E2", 2, 1, 0.01), kind: EvFill, state: OrdFilled, cum: 2, fees: 0.0006},
+			},
+		},
+		{
+			name: "duplicate ExecID is not booked twice",
+			steps: []step{
+				{msg: er("F", "1", "E1", 1, 1, 0.01), kind: EvFill, state: OrdPartiallyFilled, cum: 1, fees: 0.0003},
+				{msg: er("F", "1", "E1", 1, 1, 0.01), kind: EvStatus, state: OrdPartiallyFilled, cum: 1, fees: 0.0003},
+			},
+		},
+		{
+			name: "reject",
+			steps: []step{
+				{msg: er("8", "8", "E0", 0, 0, 0), kind: EvRejected, state: OrdRejected},
+			},
+		},
+		{
+			name:    "cancel acknowledged",
+			pending: OrdPendingCancel,
+			steps: []step{
+				{msg: er("4", "4", "E0", 0, 0, 0), kind: EvCanceled, state: OrdCanceled},
+			},
+		},
+		{
+			name:    "cancel rejected restores the live state",
+			pending: OrdPendingCancel,
+			steps: []step{
+				{msg: report(map[quickfix.Tag]string{11: "CX", 41: "C1", 58: "too late"}), cxRej: true, kind: EvCancelRejected, state: OrdNew},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newOrderManager()
+			var got []OrderEvent
+			m.listeners.Store(&[]orderListener{{id: 1, fn: func(ev OrderEvent) { got = append(got, ev) }}})
+			m.track(Order{ClOrdID: "C1", Symbol: "BTC-X", Side: enum.Side_BUY, Price: 0.01, Qty: 2})
+			if tt.pending != 0 {
+				m.markPending("C1", "CX", tt.pending)
+			}
+			for i, s := range tt.steps {
+				if s.cxRej {
+					m.onCancelReject(s.msg)
+				} else {
+					m.onExecutionReport(s.msg)
+				}
+				if len(got) != i+1 {
+					t.Fatalf("step %d: %d events, want %d", i, len(got), i+1)
+				}
+				ev := got[i]
+				if ev.Kind != s.kind || ev.Order.State != s.state || ev.Order.CumQty != s.cum {
+					t.Errorf("step %d: %s %s cum=%g, want %s %s cum=%g", i, ev.Kind, ev.Order.State, ev.Order.CumQty, s.kind, s.state, s.cum)
+				}
+				if diff := ev.Order.Fees - s.fees; diff > 1e-12 || diff < -1e-12 {
+					t.Errorf("step %d: fees %g, want %g", i, ev.Order.Fees, s.fees)
+				}
+			}
+		})
+	}
+}
+
+func TestReplaceRekeysOrder(t *testing.T) {
+	m := newOrderManager()
+	m.track(Order{ClOrdID: "C1", Symbol: "BTC-X", Side: enum.Side_SELL, Price: 0.02, Qty: 1})
+	m.markPending("C1", "C2", OrdPendingReplace)
+	m.onExecutionReport(report(map[quickfix.Tag]string{11: "C2", 41: "C1", 150: "5", 39: "0", 44: "0.019"}))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, old := m.lookup("C2"), m.lookup("C1")
+	if cur == nil || cur != old {
+		t.Fatalf("C1 and C2 must resolve to the same order")
+	}
+	if cur.ClOrdID != "C2" || cur.OrigClOrdID != "C1" || cur.State != OrdNew || cur.Price != 0.019 {
+		t.Errorf("replaced order = %+v", *cur)
+	}
+}
@@ -12,7 +12,6 @@ import (
 	"github.com/quickfixgo/enum"
 	"github.com/quickfixgo/field"
 	"github.com/quickfixgo/fix44/newordersingle"
-	"github.com/quickfixgo/quickfix"
 	"github.com/shopspring/decimal"
 )
 
@@ -22,33 +21,17 @@ import (
 
 // SendOrder sends a NewOrderSingle for box spread detection result.
 func SendOrder(d model.Depth, side enum.Side) {
-	// NewOrderSingle
-	order := newordersingle.New(
-		field.NewClOrdID(newClOrdID("BOX")),
-		field.NewSide(side),
-		field.NewTransactTime(time.Now()),
-		field.NewOrdType(enum.OrdType_LIMIT),
-	)
-	order.Set(field.NewSymbol(d.Instrument))
-	// order.Set(field.NewTimeInForce(enum.TimeInForce_IMMEDIATE_OR_CANCEL))
-	order.Set(field.NewTimeInForce(enum.TimeInForce_GOOD_TILL_CANCEL))
-
+	req := OrderReq{Symbol: d.Instrument, Side: side, TIF: enum.TimeInForce_GOOD_TILL_CANCEL, ClOrdPrefix: "BOX"}
 	if side == enum.Side_BUY {
-		order.Set(field.NewOrderQty(decimal.NewFromFloat(d.AskQty), 0))
-		order.Set(field.NewPrice(decimal.NewFromFloat(d.Ask), 0))
+		req.Price, req.Qty = d.Ask, d.AskQty
 	} else if side == enum.Side_SELL {
-		order.Set(field.NewOrderQty(decimal.NewFromFloat(d.BidQty), 0))
-		order.Set(field.NewPrice(decimal.NewFromFloat(d.Bid), 0))
+		req.Price, req.Qty = d.Bid, d.BidQty
 	}
 
-	if err := quickfix.Send(order); err != nil {
+	if _, err := SendOrderReq(&req); err != nil {
 		log.Println("[FIX] SendOrder error:", err)
 	} else {
-		if side == enum.Side_BUY {
-			log.Printf("[FIX] Sent BUY %s @ %.6f Qty=%.6f", d.Instrument, d.Ask, d.AskQty)
-		} else {
-			log.Printf("[FIX] Sent SELL %s @ %.6f Qty=%.6f", d.Instrument, d.Bid, d.BidQty)
-		}
+		log.Printf("[FIX] Sent %s %s @ %.6f Qty=%.6f", sideToStr(side), d.Instrument, req.Price, req.Qty)
 	}
 }
 
@@ -64,6 +47,8 @@ type OrderReq struct {
 	Qty         float64
 	TIF         enum.TimeInForce // usually IOC
 	ClOrdPrefix string
+	ClOrdID     string // generated by the send helpers when empty
+	Reduce      bool   // closes exposure (unwind / flatten); see OrderCheck
 }
 
 var seq uint64
@@ -74,8 +59,61 @@ func newClOrdID(prefix string) string {
 	return fmt.Sprintf("%s%s-%d", prefix, time.Now().UTC().Format("150405.000"), n)
 }
 
+// SendOrderReq sends one limit order and registers it with the order
+// manager under req.ClOrdID (generated when empty). Track it with GetOrder,
+// OnOrderEvent or SubscribeOrders. Orders refused by the pre-trade hook or
+// the credit throttler are neither sent nor tracked.
+func SendOrderReq(req *OrderReq) (string, error) {
+	if err := checkPreTrade(OrderCheck{Symbol: req.Symbol, Side: req.Side, Price: req.Price, Qty: req.Qty, Reduce: req.Reduce}); err != nil {
+		return "", err
+	}
+	pfx := req.ClOrdPrefix
+	if pfx == "" {
+		pfx = "ORD"
+	}
+	if req.ClOrdID == "" {
+		req.ClOrdID = newClOrdID(pfx)
+	}
+	tif := req.TIF
+	if tif == "" {
+		// default to GTC (Good Till Cancel)
+		// tif = enum.TimeInForce_IMMEDIATE_OR_CANCEL
+		tif = enum.TimeInForce_GOOD_TILL_CANCEL
+	}
+	if err := throttle.acquire(reqOrder, tif == enum.TimeInForce_IMMEDIATE_OR_CANCEL); err != nil {
+		return "", err
+	}
+
+	ord := newordersingle.New(
+		field.NewClOrdID(req.ClOrdID),
+		field.NewSide(req.Side),
+		field.NewTransactTime(time.Now()),
+		field.NewOrdType(enum.OrdType_LIMIT),
+	)
+	ord.Set(field.NewSymbol(req.Symbol))
+	ord.Set(field.NewTimeInForce(tif))
+	ord.Set(field.NewOrderQty(fixDec(req.Qty)))
+	ord.Set(field.NewPrice(fixDec(req.Price)))
+
+	orders.track(Order{
+		ClOrdID: req.ClOrdID,
+		Symbol:  req.Symbol,
+		Side:    req.Side,
+		Price:   req.Price,
+		Qty:     req.Qty,
+		TIF:     tif,
+		Prefix:  pfx,
+	})
+	if err := sendToSession(ord); err != nil {
+		orders.sendFailed(req.ClOrdID, err)
+		return req.ClOrdID, err
+	}
+	return req.ClOrdID, nil
+}
+
 // SendBatch sends multiple IOC orders concurrently.
-// It returns the first error per order index (nil if success).
+// It returns the first error per order index (nil if success); each
+// reqs[i].ClOrdID is filled in for tracking.
 func SendBatch(reqs []OrderReq) []error {
 	errs := make([]error, len(reqs))
 	var wg sync.WaitGroup
@@ -85,30 +123,8 @@ func SendBatch(reqs []OrderReq) []error {
 		i := i
 		go func() {
 			defer wg.Done()
-			req := reqs[i]
-			pfx := req.ClOrdPrefix
-			if pfx == "" {
-				pfx = "ORD"
-			}
-			tif := req.TIF
-			if tif == "" {
-				// default to GTC (Good Till Cancel)
-				// tif = enum.TimeInForce_IMMEDIATE_OR_CANCEL
-				tif = enum.TimeInForce_GOOD_TILL_CANCEL
-			}
-
-			ord := newordersingle.New(
-				field.NewClOrdID(newClOrdID(pfx)),
-				field.NewSide(req.Side),
-				field.NewTransactTime(time.Now()),
-				field.NewOrdType(enum.OrdType_LIMIT),
-			)
-			ord.Set(field.NewSymbol(req.Symbol))
-			ord.Set(field.NewTimeInForce(tif))
-			ord.Set(field.NewOrderQty(decimal.NewFromFloat(req.Qty), 0))
-			ord.Set(field.NewPrice(decimal.NewFromFloat(req.Price), 0))
-
-			if err := quickfix.Send(ord); err != nil {
+			req := &reqs[i]
+			if _, err := SendOrderReq(req); err != nil {
 				errs[i] = err
 				log.Printf("[FIX] SendBatch error: %v (sym=%s side=%s px=%.6f qty=%.6f)",
 					err, req.Symbol, sideToStr(req.Side), req.Price, req.Qty)
@@ -134,3 +150,13 @@ func sideToStr(s enum.Side) string {
 		return fmt.Sprintf("Side(%s)", s)
 	}
 }
+
+// fixDec returns v with the scale needed to write it exactly (a zero scale
+// would round option prices and fractional quantities to integers).
+func fixDec(v float64) (decimal.Decimal, int32) {
+	d := decimal.NewFromFloat(v)
+	if e := d.Exponent(); e < 0 {
+		return d, -e
+	}
+	return d, 0
+}
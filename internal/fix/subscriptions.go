@@ -0,0 +1,90 @@
+package fix
+
+import (
+	"Options_Hedger/internal/data"
+	"fmt"
+	"log"
+)
+
+// Subscribe adds instruments at runtime: each symbol gets a data slot, is
+// published in the symbol table and, if a session is up, subscribed with its
+// own MDReqID (SubscriptionRequestType=1). It returns the symbol index per
+// input (-1 on failure); already subscribed symbols keep their index.
+func Subscribe(symbols []string) ([]int32, error) {
+	idxs := make([]int32, len(symbols))
+	var added []string
+	var firstErr error
+
+	symTabMu.Lock()
+	t := symTab.Load().clone()
+	for i, sym := range symbols {
+		if idx, ok := t.byName[sym]; ok {
+			idxs[i] = idx
+			continue
+		}
+		idx, err := data.AddSymbol(sym)
+		if err != nil {
+			idxs[i] = -1
+			if firstErr == nil {
+				firstErr = fmt.Errorf("subscribe %s: %w", sym, err)
+			}
+			continue
+		}
+		for int(idx) >= len(t.byIdx) {
+			t.byIdx = append(t.byIdx, "")
+		}
+		t.byIdx[idx] = sym
+		t.byName[sym] = idx
+		idxs[i] = idx
+		added = append(added, sym)
+	}
+	symTab.Store(t)
+	symTabMu.Unlock()
+
+	if len(added) == 0 {
+		return idxs, firstErr
+	}
+	if supervisor.session.Load() == nil {
+		log.Printf("[FIX-MD] %d symbols added; subscription deferred until logon", len(added))
+		return idxs, firstErr
+	}
+	if err := supervisor.subscribe("", added, false, 0); err != nil {
+		return idxs, fmt.Errorf("MarketDataRequest: %w", err)
+	}
+	log.Printf("[FIX-MD] subscribed %d symbols at runtime", len(added))
+	return idxs, firstErr
+}
+
+// Unsubscribe removes instruments at runtime (SubscriptionRequestType=2),
+// drops them from the symbol table and frees their data slots.
+// It returns the freed symbol indexes.
+func Unsubscribe(symbols []string) []int32 {
+	supervisor.release(symbols)
+
+	var freed []int32
+	symTabMu.Lock()
+	t := symTab.Load().clone()
+	for _, sym := range symbols {
+		idx, ok := t.byName[sym]
+		if !ok {
+			continue
+		}
+		delete(t.byName, sym)
+		t.byIdx[idx] = ""
+		freed = append(freed, idx)
+	}
+	symTab.Store(t)
+	symTabMu.Unlock()
+
+	// Slots are freed only after FromApp can no longer resolve the symbols
+	for _, idx := range freed {
+		data.RemoveSymbol(idx)
+	}
+	if len(freed) > 0 {
+		log.Printf("[FIX-MD] unsubscribed %d symbols at runtime", len(freed))
+	}
+	return freed
+}
+
+// SubscribedSymbols returns the currently subscribed option symbols.
+func SubscribedSymbols() []string { return symTab.Load().active() }
@@ -0,0 +1,236 @@
+package fix
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrThrottled is returned when an order-entry request gets no credit in
+// time (or at once, for fail-fast requests).
+var ErrThrottled = errors.New("fix: rate limit credits exhausted")
+
+// reqKind is the class of an order-entry request for the throttler.
+type reqKind uint8
+
+const (
+	reqOrder  reqKind = iota // new order, multileg, replace
+	reqCancel                // cancel, mass cancel: served before orders
+)
+
+// throttler models Deribit's matching-engine credit pool as a token bucket:
+// each request costs credits, the pool refills at a fixed rate up to a cap.
+// Waiting cancels are always served before waiting orders.
+//
+// Env:
+//
+//	FIX_THROTTLE=1                  (0 disables)
+//	FIX_CREDITS_MAX=50000           (pool size = burst)
+//	FIX_CREDITS_REFILL=10000        (credits per second)
+//	FIX_CREDIT_COST=500             (new order / replace / multileg)
+//	FIX_CREDIT_COST_CANCEL=500      (cancel / mass cancel)
+//	FIX_THROTTLE_MAX_WAIT_MS=1000   (queued requests fail after this)
+//	FIX_THROTTLE_IOC_FAIL_FAST=1    (IOC orders never queue)
+type throttler struct {
+	mu      sync.Mutex
+	enabled bool
+	max     float64
+	refill  float64 // credits per ns
+	cost    [2]float64
+	maxWait time.Duration
+	iocFast bool
+	credits float64
+	lastNs  int64
+	queues  [2][]*creditWaiter // by reqKind
+	timer   *time.Timer
+	stats   ThrottleStats
+	waitSum time.Duration
+	maxSeen time.Duration
+	started time.Time
+}
+
+type creditWaiter struct {
+	cost    float64
+	ready   chan struct{}
+	granted bool // guarded by throttler.mu
+}
+
+var throttle = &throttler{}
+
+// configure reads FIX_THROTTLE / FIX_CREDIT* settings (called on start).
+func (t *throttler) configure() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.enabled = strings.TrimSpace(os.Getenv("FIX_THROTTLE")) != "0"
+	t.max = envFloat("FIX_CREDITS_MAX", 50_000)
+	t.refill = envFloat("FIX_CREDITS_REFILL", 10_000) / float64(time.Second)
+	t.cost[reqOrder] = envFloat("FIX_CREDIT_COST", 500)
+	t.cost[reqCancel] = envFloat("FIX_CREDIT_COST_CANCEL", 500)
+	t.maxWait = time.Duration(envMs("FIX_THROTTLE_MAX_WAIT_MS", 1_000))
+	t.iocFast = strings.TrimSpace(os.Getenv("FIX_THROTTLE_IOC_FAIL_FAST")) != "0"
+	t.credits, t.lastNs, t.started = t.max, time.Now().UnixNano(), time.Now()
+}
+
+func envFloat(key string, def float64) float64 {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if x, err := strconv.ParseFloat(v, 64); err == nil && x >= 0 {
+			return x
+		}
+	}
+	return def
+}
+
+// acquire takes the credits for one request, waiting up to maxWait behind
+// earlier requests of the same or higher priority. IOC requests return
+// ErrThrottled instead of queueing when FIX_THROTTLE_IOC_FAIL_FAST is on.
+func (t *throttler) acquire(kind reqKind, ioc bool) error {
+	t.mu.Lock()
+	if !t.enabled || t.refill <= 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	t.refillLocked()
+	cost := t.cost[kind]
+	ahead := len(t.queues[reqCancel])
+	if kind == reqOrder {
+		ahead += len(t.queues[reqOrder])
+	}
+	if ahead == 0 && t.credits >= cost {
+		t.credits -= cost
+		t.stats.Granted++
+		t.mu.Unlock()
+		return nil
+	}
+	if (ioc && t.iocFast) || t.maxWait <= 0 {
+		t.stats.Rejected++
+		t.mu.Unlock()
+		return ErrThrottled
+	}
+	w := &creditWaiter{cost: cost, ready: make(chan struct{})}
+	t.queues[kind] = append(t.queues[kind], w)
+	t.dispatchLocked()
+	t.mu.Unlock()
+
+	start := time.Now()
+	timer := time.NewTimer(t.maxWait)
+	defer timer.Stop()
+	select {
+	case <-w.ready:
+		t.recordWait(time.Since(start))
+		return nil
+	case <-timer.C:
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if w.granted {
+		// Granted while the timer fired
+		t.recordWaitLocked(time.Since(start))
+		return nil
+	}
+	q := t.queues[kind]
+	for i := range q {
+		if q[i] == w {
+			t.queues[kind] = append(q[:i], q[i+1:]...)
+			break
+		}
+	}
+	t.stats.Rejected++
+	t.stats.TimedOut++
+	return ErrThrottled
+}
+
+func (t *throttler) refillLocked() {
+	now := time.Now().UnixNano()
+	t.credits += float64(now-t.lastNs) * t.refill
+	if t.credits > t.max {
+		t.credits = t.max
+	}
+	t.lastNs = now
+}
+
+// dispatchLocked grants queued requests in priority order while credits
+// last and schedules itself for when the next head can be served.
+func (t *throttler) dispatchLocked() {
+	t.refillLocked()
+	for {
+		kind := reqCancel
+		if len(t.queues[kind]) == 0 {
+			kind = reqOrder
+		}
+		if len(t.queues[kind]) == 0 {
+			return
+		}
+		w := t.queues[kind][0]
+		if t.credits < w.cost {
+			if t.timer == nil {
+				wait := time.Duration((w.cost-t.credits)/t.refill) + time.Microsecond
+				t.timer = time.AfterFunc(wait, t.dispatch)
+			}
+			return
+		}
+		t.credits -= w.cost
+		t.queues[kind] = t.queues[kind][1:]
+		w.granted = true
+		t.stats.Granted++
+		close(w.ready)
+	}
+}
+
+func (t *throttler) dispatch() {
+	t.mu.Lock()
+	t.timer = nil
+	t.dispatchLocked()
+	t.mu.Unlock()
+}
+
+func (t *throttler) recordWait(d time.Duration) {
+	t.mu.Lock()
+	t.recordWaitLocked(d)
+	t.mu.Unlock()
+}
+
+func (t *throttler) recordWaitLocked(d time.Duration) {
+	t.stats.Queued++
+	t.waitSum += d
+	if d > t.maxSeen {
+		t.maxSeen = d
+	}
+}
+
+// ThrottleStats are the order-entry throttler counters since start.
+type ThrottleStats struct {
+	Enabled       bool          `json:"enabled"`
+	Credits       float64       `json:"credits"`
+	MaxCredits    float64       `json:"max_credits"`
+	Granted       uint64        `json:"granted"`
+	Queued        uint64        `json:"queued"`   // granted after waiting
+	Rejected      uint64        `json:"rejected"` // fail-fast + timed out
+	TimedOut      uint64        `json:"timed_out"`
+	WaitingCancel int           `json:"waiting_cancels"`
+	WaitingOrders int           `json:"waiting_orders"`
+	AvgWait       time.Duration `json:"avg_wait_ns"`
+	MaxWait       time.Duration `json:"max_wait_ns"`
+	Since         time.Time     `json:"since"`
+}
+
+// ThrottleStatus returns the current credit level and queueing metrics.
+func ThrottleStatus() ThrottleStats {
+	t := throttle
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.enabled {
+		t.refillLocked()
+	}
+	s := t.stats
+	s.Enabled, s.Credits, s.MaxCredits, s.Since = t.enabled, t.credits, t.max, t.started
+	s.WaitingCancel, s.WaitingOrders = len(t.queues[reqCancel]), len(t.queues[reqOrder])
+	s.MaxWait = t.maxSeen
+	if s.Queued > 0 {
+		s.AvgWait = t.waitSum / time.Duration(s.Queued)
+	}
+	return s
+}
@@ -0,0 +1,90 @@
+package fix
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestThrottler returns an enabled throttler with credits in the pool,
+// refilling one 100-credit request every refillEvery.
+func newTestThrottler(credits float64, refillEvery, maxWait time.Duration) *throttler {
+	return &throttler{
+		enabled: true,
+		max:     1000,
+		refill:  100 / float64(refillEvery),
+		cost:    [2]float64{100, 100},
+		maxWait: maxWait,
+		iocFast: true,
+		credits: credits,
+		lastNs:  time.Now().UnixNano(),
+	}
+}
+
+func TestThrottleAcquire(t *testing.T) {
+	tests := []struct {
+		name     string
+		credits  float64
+		refill   time.Duration // per 100 credits
+		kind     reqKind
+		ioc      bool
+		maxWait  time.Duration
+		wantErr  bool
+		timedOut uint64
+	}{
+		{"credits available", 500, time.Hour, reqOrder, false, time.Second, false, 0},
+		{"ioc fails fast when empty", 0, time.Hour, reqOrder, true, time.Second, true, 0},
+		{"no wait allowed", 0, time.Hour, reqCancel, false, 0, true, 0},
+		{"queued request times out", 0, time.Hour, reqOrder, false, 20 * time.Millisecond, true, 1},
+		{"queued request is served by the refill", 90, 10 * time.Millisecond, reqOrder, false, time.Second, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestThrottler(tt.credits, tt.refill, tt.maxWait)
+			err := th.acquire(tt.kind, tt.ioc)
+			if tt.wantErr != errors.Is(err, ErrThrottled) || (!tt.wantErr && err != nil) {
+				t.Fatalf("acquire() = %v, want throttled=%v", err, tt.wantErr)
+			}
+			if th.stats.TimedOut != tt.timedOut {
+				t.Errorf("timed out = %d, want %d", th.stats.TimedOut, tt.timedOut)
+			}
+		})
+	}
+}
+
+func TestThrottleServesCancelsFirst(t *testing.T) {
+	th := newTestThrottler(0, 20*time.Millisecond, time.Second)
+	served := make(chan reqKind, 2)
+	enqueue := func(kind reqKind) {
+		go func() {
+			if err := th.acquire(kind, false); err == nil {
+				served <- kind
+			}
+		}()
+	}
+	queued := func(kind reqKind) bool {
+		th.mu.Lock()
+		defer th.mu.Unlock()
+		return len(th.queues[kind]) > 0
+	}
+
+	// The order queues first, the cancel behind it must still go first
+	enqueue(reqOrder)
+	for !queued(reqOrder) {
+		time.Sleep(time.Millisecond)
+	}
+	enqueue(reqCancel)
+	for !queued(reqCancel) {
+		time.Sleep(time.Millisecond)
+	}
+	for i, want := range []reqKind{reqCancel, reqOrder} {
+		select {
+		case got := <-served:
+			if got != want {
+				t.Fatalf("grant %d went to kind %d, want %d", i, got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("grant %d not served", i)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package fix
+
+import (
+	"sync/atomic"
+
+	"github.com/quickfixgo/quickfix"
+)
+
+// OrderTransport carries outgoing order-entry messages (35=D/AB/F/G/q).
+// The default sends on the logged-on FIX session; paper trading installs a
+// simulator that answers through Deliver.
+type OrderTransport interface {
+	SendOrderMsg(msg *quickfix.Message) error
+}
+
+var transport atomic.Pointer[OrderTransport]
+
+// SetOrderTransport replaces the FIX session for order entry (nil restores it).
+func SetOrderTransport(t OrderTransport) {
+	if t == nil {
+		transport.Store(nil)
+		return
+	}
+	transport.Store(&t)
+}
+
+// Deliver processes an inbound order-entry message (35=8/9/r/j) as if it had
+// been received on the FIX session. It reports whether the type was handled.
+func Deliver(msg *quickfix.Message) bool {
+	msgType, _ := msg.Header.GetString(quickfix.Tag(35))
+	switch msgType {
+	case "8":
+		orders.onExecutionReport(msg)
+	case "9":
+		orders.onCancelReject(msg)
+	case "r":
+		onMassCancelReport(msg)
+	case "j":
+		orders.onBusinessReject(msg)
+	default:
+		return false
+	}
+	return true
+}
+
+// RawMDTap receives every market data message (35=W/X) as received, before
+// it is applied. Calls are made on the FIX receive goroutine; raw is only
+// valid during the call.
+type RawMDTap interface {
+	OnRawMD(raw []byte, ns int64)
+}
+
+var rawMDTap atomic.Pointer[RawMDTap]
+
+// SetRawMDTap installs the raw market data tap (nil removes it).
+func SetRawMDTap(t RawMDTap) {
+	if t == nil {
+		rawMDTap.Store(nil)
+		return
+	}
+	rawMDTap.Store(&t)
+}
@@ -0,0 +1,530 @@
+package fixsim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"Options_Hedger/internal/data"
+	"Options_Hedger/internal/pricing"
+	"Options_Hedger/internal/recorder"
+
+	"github.com/quickfixgo/quickfix"
+)
+
+// indexSymbol is the Deribit index instrument of MarketDataRequests.
+const indexSymbol = "BTC-DERIBIT-INDEX"
+
+// MarketConfig for the simulated market. Without FIXSIM_REPLAY, books are
+// Black-76 prices at a flat vol around a random-walk index; with it, the
+// recorded top of book is streamed at FIXSIM_REPLAY_SPEED.
+//
+// Env:
+//
+//	FIXSIM_INDEX=100000          (starting index price)
+//	FIXSIM_INDEX_VOL=0.5         (annualized index volatility)
+//	FIXSIM_VOL=0.6               (option implied volatility)
+//	FIXSIM_SPREAD_TICKS=2        (half spread)
+//	FIXSIM_MAX_QTY=20            (max displayed size per side)
+//	FIXSIM_TICK_MS=100           (market step)
+//	FIXSIM_REQUOTE=0.2           (share of books requoted per step)
+//	FIXSIM_ARB_SEC=0             (every N s misprice one option, 0 = never)
+//	FIXSIM_ARB_TICKS=20          (mispricing, in ticks below fair)
+//	FIXSIM_ARB_HOLD_MS=1000      (how long the mispricing stays)
+//	FIXSIM_SEED=1                (random seed)
+//	FIXSIM_REPLAY=               (recorder file or directory to stream instead)
+//	FIXSIM_REPLAY_SPEED=1        (replay speed factor)
+//	FIXSIM_MAX_INSTRUMENTS=2000  (book capacity)
+type MarketConfig struct {
+	Index       float64
+	IndexVol    float64
+	Vol         float64
+	SpreadTicks float64
+	MaxQty      float64
+	Step        time.Duration
+	Requote     float64
+	ArbEvery    time.Duration
+	ArbTicks    float64
+	ArbHold     time.Duration
+	Seed        int64
+	Replay      string
+	ReplaySpeed float64
+	Capacity    int
+}
+
+// MarketConfigFromEnv reads the FIXSIM_* market settings.
+func MarketConfigFromEnv() MarketConfig {
+	return MarketConfig{
+		Index:       envFloat("FIXSIM_INDEX", 100_000),
+		IndexVol:    envFloat("FIXSIM_INDEX_VOL", 0.5),
+		Vol:         envFloat("FIXSIM_VOL", 0.6),
+		SpreadTicks: envFloat("FIXSIM_SPREAD_TICKS", 2),
+		MaxQty:      envFloat("FIXSIM_MAX_QTY", 20),
+		Step:        time.Duration(envFloat("FIXSIM_TICK_MS", 100) * float64(time.Millisecond)),
+		Requote:     envFloat("FIXSIM_REQUOTE", 0.2),
+		ArbEvery:    time.Duration(envFloat("FIXSIM_ARB_SEC", 0) * float64(time.Second)),
+		ArbTicks:    envFloat("FIXSIM_ARB_TICKS", 20),
+		ArbHold:     time.Duration(envFloat("FIXSIM_ARB_HOLD_MS", 1000) * float64(time.Millisecond)),
+		Seed:        int64(envFloat("FIXSIM_SEED", 1)),
+		Replay:      env("FIXSIM_REPLAY", ""),
+		ReplaySpeed: envFloat("FIXSIM_REPLAY_SPEED", 1),
+		Capacity:    int(envFloat("FIXSIM_MAX_INSTRUMENTS", 2000)),
+	}
+}
+
+type quote struct {
+	bid, bidQty, ask, askQty float64
+}
+
+// simBook is one instrument; its top of book is mirrored into data so the
+// paper matcher fills against it.
+type simBook struct {
+	sym   string
+	meta  data.InstrumentMeta
+	idx   int32
+	vol   float64
+	shift float64 // BTC added to fair value (arb injection)
+	q     quote
+}
+
+// Market owns the simulated books. All state belongs to the Run goroutine;
+// other goroutines go through do.
+type Market struct {
+	cfg   MarketConfig
+	rng   *rand.Rand
+	calls chan func()
+	out   func(*quickfix.Message, quickfix.SessionID)
+
+	index float64
+	books map[string]*simBook
+	list  []*simBook                               // creation order, for a seeded walk
+	subs  map[quickfix.SessionID]map[string]string // session -> symbol -> MDReqID
+
+	arb     *simBook
+	arbEnd  time.Time
+	nextArb time.Time
+
+	replay   bool
+	files    []string
+	rd       *recorder.Reader
+	rec      recorder.Record
+	recBase  int64 // recorded time of the first record
+	wallBase time.Time
+}
+
+// NewMarket sizes the book tables and opens the replay files, if any.
+func NewMarket(cfg MarketConfig) (*Market, error) {
+	data.SetCapacity(cfg.Capacity)
+	data.InitOrderBooks(nil, nil)
+	m := &Market{
+		cfg:   cfg,
+		rng:   rand.New(rand.NewSource(cfg.Seed)),
+		calls: make(chan func()),
+		index: cfg.Index,
+		books: make(map[string]*simBook),
+		subs:  make(map[quickfix.SessionID]map[string]string),
+	}
+	if cfg.Replay != "" {
+		files, err := replayFiles(cfg.Replay)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("fixsim: no recordings in %s", cfg.Replay)
+		}
+		m.files, m.replay = files, true
+	}
+	return m, nil
+}
+
+func replayFiles(path string) ([]string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []string{path}, nil
+	}
+	files, err := filepath.Glob(filepath.Join(path, "md-*.bin"))
+	sort.Strings(files)
+	return files, err
+}
+
+// Mode describes the market source.
+func (m *Market) Mode() string {
+	if m.replay {
+		return fmt.Sprintf("replaying %d files at %gx", len(m.files), m.cfg.ReplaySpeed)
+	}
+	return fmt.Sprintf("random market, index %.0f vol %g", m.cfg.Index, m.cfg.Vol)
+}
+
+// Run steps the market until ctx is done; out sends to a client session.
+func (m *Market) Run(ctx context.Context, out func(*quickfix.Message, quickfix.SessionID)) {
+	m.out = out
+	t := time.NewTicker(m.cfg.Step)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case f := <-m.calls:
+			f()
+		case now := <-t.C:
+			if m.replay {
+				m.replayUntil(now)
+			} else {
+				m.step(now)
+			}
+		}
+	}
+}
+
+// do runs f on the market goroutine and waits for it.
+func (m *Market) do(f func()) {
+	done := make(chan struct{})
+	m.calls <- func() { f(); close(done) }
+	<-done
+}
+
+// Subscribe sends a snapshot of each symbol to the session and, with
+// updates, streams its changes under reqID. It returns unknown symbols.
+func (m *Market) Subscribe(id quickfix.SessionID, reqID string, symbols []string, updates bool) (unknown []string) {
+	m.do(func() {
+		for _, sym := range symbols {
+			if sym != indexSymbol && m.book(sym, time.Now()) == nil {
+				unknown = append(unknown, sym)
+				continue
+			}
+			if updates {
+				if m.subs[id] == nil {
+					m.subs[id] = make(map[string]string)
+				}
+				m.subs[id][sym] = reqID
+			}
+			m.out(m.snapshot(reqID, sym), id)
+		}
+	})
+	return unknown
+}
+
+// Unsubscribe stops streaming sym ("" = everything) to the session.
+func (m *Market) Unsubscribe(id quickfix.SessionID, sym string) {
+	m.do(func() {
+		if sym == "" {
+			delete(m.subs, id)
+			return
+		}
+		delete(m.subs[id], sym)
+	})
+}
+
+// Ensure creates books for symbols about to be traded.
+func (m *Market) Ensure(symbols []string) {
+	if len(symbols) == 0 {
+		return
+	}
+	m.do(func() {
+		for _, sym := range symbols {
+			m.book(sym, time.Now())
+		}
+	})
+}
+
+// book returns the book of sym, creating and quoting it on first use; nil
+// for names that are not Deribit options (or expired, unless replaying).
+func (m *Market) book(sym string, now time.Time) *simBook {
+	if b, ok := m.books[sym]; ok {
+		return b
+	}
+	meta, ok := data.ResolveInstrument(sym)
+	if !ok || meta.OptionType == data.NotOption || (!m.replay && !meta.Expiry().After(now)) {
+		return nil
+	}
+	idx, err := data.AddSymbol(sym)
+	if err != nil {
+		log.Printf("[FIXSIM] %s: %v", sym, err)
+		return nil
+	}
+	b := &simBook{sym: sym, meta: meta, idx: idx, vol: m.cfg.Vol * (0.95 + 0.1*m.rng.Float64())}
+	m.books[sym] = b
+	m.list = append(m.list, b)
+	if !m.replay {
+		m.requote(b, now)
+	}
+	return b
+}
+
+// step moves the index and requotes a random share of the books.
+func (m *Market) step(now time.Time) {
+	dt := m.cfg.Step.Hours() / (24 * 365)
+	v := m.cfg.IndexVol
+	m.setIndex(m.index * math.Exp(v*math.Sqrt(dt)*m.rng.NormFloat64()-0.5*v*v*dt))
+
+	if m.arb != nil && now.After(m.arbEnd) {
+		m.arb.shift = 0
+		m.requote(m.arb, now)
+		m.arb = nil
+	}
+	if m.cfg.ArbEvery > 0 && len(m.list) > 0 && m.arb == nil && !now.Before(m.nextArb) {
+		if !m.nextArb.IsZero() {
+			b := m.list[m.rng.Intn(len(m.list))]
+			b.shift = -m.cfg.ArbTicks * tickSize(b.q.ask)
+			m.arb, m.arbEnd = b, now.Add(m.cfg.ArbHold)
+			log.Printf("[FIXSIM] mispricing %s by %g ticks for %s", b.sym, m.cfg.ArbTicks, m.cfg.ArbHold)
+			m.requote(b, now)
+		}
+		m.nextArb = now.Add(m.cfg.ArbEvery)
+	}
+	for _, b := range m.list {
+		if m.rng.Float64() < m.cfg.Requote {
+			m.requote(b, now)
+		}
+	}
+}
+
+// requote prices b at the current index: Black-76 fair value in BTC, rounded
+// to the Deribit tick, a fixed half spread and random sizes.
+func (m *Market) requote(b *simBook, now time.Time) {
+	T := b.meta.Expiry().Sub(now).Hours() / (24 * 365)
+	if T <= 0 {
+		m.publish(b, quote{})
+		return
+	}
+	fair := pricing.Price(b.meta.IsCall(), m.index, b.meta.Strike, T, 0, b.vol) + b.shift
+	tick := tickSize(fair)
+	half := m.cfg.SpreadTicks * tick
+	q := quote{
+		bid: math.Floor((fair-half)/tick+1e-9) * tick,
+		ask: math.Ceil((fair+half)/tick-1e-9) * tick,
+	}
+	if q.ask < tick {
+		q.ask = tick
+	}
+	if q.bid >= q.ask {
+		q.bid = q.ask - tick
+	}
+	q.bid, q.ask = math.Round(q.bid*1e4)/1e4, math.Round(q.ask*1e4)/1e4 // exact decimals on the wire
+	if q.bid > 0 {
+		q.bidQty = m.size()
+	} else {
+		q.bid = 0
+	}
+	q.askQty = m.size()
+	m.publish(b, q)
+}
+
+// size returns a random displayed size, 0.1 contract lots.
+func (m *Market) size() float64 {
+	return math.Max(0.1, math.Round(m.rng.Float64()*m.cfg.MaxQty*10)/10)
+}
+
+// tickSize is the Deribit BTC option tick for a price.
+func tickSize(px float64) float64 {
+	if px >= 0.005 {
+		return 0.0005
+	}
+	return 0.0001
+}
+
+// setIndex updates the index and streams it.
+func (m *Market) setIndex(px float64) {
+	m.index = px
+	data.SetIndexPrice(px)
+	for id, syms := range m.subs {
+		if reqID, ok := syms[indexSymbol]; ok {
+			msg := mdMessage("X", reqID, indexSymbol, px)
+			addEntries(msg, []mdEntry{{action: "1", typ: "3", px: px}})
+			m.out(msg, id)
+		}
+	}
+}
+
+// publish replaces the quote of b and streams the change as incremental
+// updates: a moved level is deleted and re-added, a resized one changed.
+func (m *Market) publish(b *simBook, q quote) {
+	old := b.q
+	b.q = q
+	data.WriteDepthFast(int(b.idx), q.bid, q.bidQty, q.ask, q.askQty)
+
+	var entries []mdEntry
+	side := func(typ string, oldPx, oldQty, px, qty float64) {
+		switch {
+		case oldQty > 0 && (px != oldPx || qty <= 0):
+			entries = append(entries, mdEntry{action: "2", typ: typ, px: oldPx})
+			if qty > 0 {
+				entries = append(entries, mdEntry{action: "0", typ: typ, px: px, qty: qty})
+			}
+		case qty > 0 && oldQty <= 0:
+			entries = append(entries, mdEntry{action: "0", typ: typ, px: px, qty: qty})
+		case qty > 0 && qty != oldQty:
+			entries = append(entries, mdEntry{action: "1", typ: typ, px: px, qty: qty})
+		}
+	}
+	side("0", old.bid, old.bidQty, q.bid, q.bidQty)
+	side("1", old.ask, old.askQty, q.ask, q.askQty)
+	if len(entries) == 0 {
+		return
+	}
+	for id, syms := range m.subs {
+		if reqID, ok := syms[b.sym]; ok {
+			msg := mdMessage("X", reqID, b.sym, m.index)
+			addEntries(msg, entries)
+			m.out(msg, id)
+		}
+	}
+}
+
+// snapshot builds the full refresh (35=W) of sym.
+func (m *Market) snapshot(reqID, sym string) *quickfix.Message {
+	msg := mdMessage("W", reqID, sym, m.index)
+	if sym == indexSymbol {
+		addEntries(msg, []mdEntry{{typ: "3", px: m.index}})
+		return msg
+	}
+	var entries []mdEntry
+	if q := m.books[sym].q; q.bidQty > 0 || q.askQty > 0 {
+		if q.bidQty > 0 {
+			entries = append(entries, mdEntry{typ: "0", px: q.bid, qty: q.bidQty})
+		}
+		if q.askQty > 0 {
+			entries = append(entries, mdEntry{typ: "1", px: q.ask, qty: q.askQty})
+		}
+	}
+	addEntries(msg, entries)
+	return msg
+}
+
+type mdEntry struct {
+	action string // 279, incremental only
+	typ    string // 269: 0 bid, 1 offer, 3 index
+	px     float64
+	qty    float64
+}
+
+func mdMessage(msgType, reqID, sym string, index float64) *quickfix.Message {
+	msg := quickfix.NewMessage()
+	msg.Header.SetString(35, msgType)
+	msg.Body.SetString(262, reqID)
+	msg.Body.SetString(55, sym)
+	if index > 0 {
+		msg.Body.SetString(810, fmtNum(index))
+	}
+	return msg
+}
+
+func addEntries(msg *quickfix.Message, entries []mdEntry) {
+	g := quickfix.NewRepeatingGroup(268, quickfix.GroupTemplate{
+		quickfix.GroupElement(279),
+		quickfix.GroupElement(269),
+		quickfix.GroupElement(270),
+		quickfix.GroupElement(271),
+	})
+	for _, e := range entries {
+		f := g.Add()
+		if e.action != "" {
+			f.SetString(279, e.action)
+		}
+		f.SetString(269, e.typ)
+		f.SetString(270, fmtNum(e.px))
+		if e.typ != "3" && e.action != "2" {
+			f.SetString(271, fmtNum(e.qty))
+		}
+	}
+	msg.Body.SetGroup(g)
+}
+
+func fmtNum(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+// replayUntil streams the recorded records due by now.
+func (m *Market) replayUntil(now time.Time) {
+	for {
+		if m.rd == nil && !m.openNext(now) {
+			return
+		}
+		due := m.wallBase.Add(time.Duration(float64(m.rec.Time-m.recBase) / math.Max(m.cfg.ReplaySpeed, 1e-9)))
+		if due.After(now) {
+			return
+		}
+		m.apply(m.rec, now)
+		if !m.read() {
+			m.rd.Close()
+			m.rd = nil
+		}
+	}
+}
+
+// openNext opens the next recording and reads its first record.
+func (m *Market) openNext(now time.Time) bool {
+	for len(m.files) > 0 {
+		path := m.files[0]
+		m.files = m.files[1:]
+		rd, err := recorder.Open(path)
+		if err != nil {
+			log.Printf("[FIXSIM] replay %v", err)
+			continue
+		}
+		m.rd = rd
+		if !m.read() {
+			rd.Close()
+			m.rd = nil
+			continue
+		}
+		if m.wallBase.IsZero() {
+			m.recBase, m.wallBase = m.rec.Time, now
+		}
+		log.Printf("[FIXSIM] replaying %s", path)
+		return true
+	}
+	if m.files != nil {
+		log.Printf("[FIXSIM] replay finished, books frozen")
+		m.files = nil
+	}
+	return false
+}
+
+// read loads the next record of the current file.
+func (m *Market) read() bool {
+	rec, err := m.rd.Next()
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			log.Printf("[FIXSIM] replay: %v", err)
+		}
+		return false
+	}
+	m.rec = rec
+	return true
+}
+
+func (m *Market) apply(rec recorder.Record, now time.Time) {
+	switch rec.Kind {
+	case recorder.KindIndex:
+		m.setIndex(rec.Index)
+	case recorder.KindUpdate:
+		u := rec.Update
+		if int(u.SymbolIdx) >= len(m.rd.Symbols) {
+			return
+		}
+		b := m.book(m.rd.Symbols[u.SymbolIdx], now)
+		if b == nil {
+			return
+		}
+		q := b.q
+		if u.TwoSided {
+			q.bid, q.bidQty, q.ask, q.askQty = u.Price, u.Qty, u.AskPrice, u.AskQty
+		} else if u.IsBid {
+			q.bid, q.bidQty = u.Price, u.Qty
+		} else {
+			q.ask, q.askQty = u.Price, u.Qty
+		}
+		m.publish(b, q)
+	}
+}
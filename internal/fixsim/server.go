@@ -0,0 +1,310 @@
+// Package fixsim is a local stand-in for the Deribit FIX gateway: a
+// QuickFIX/Go acceptor that checks the Deribit logon signature, streams
+// simulated (or recorded) option books and the BTC index, and fills orders
+// with the paper trading matcher. Point the hedger's FIX session at it to
+// run the whole pipeline without an exchange connection.
+package fixsim
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"Options_Hedger/internal/paper"
+
+	"github.com/quickfixgo/quickfix"
+)
+
+// Config for the simulator.
+//
+// Env:
+//
+//	FIXSIM_ADDR=127.0.0.1:9881     (listen address)
+//	FIXSIM_SENDER=DERIBITSERVER     (our CompID = the hedger's TargetCompID)
+//	FIXSIM_CLIENT=                  (the hedger's SenderCompID, required)
+//	FIXSIM_CLIENT_ID / FIXSIM_CLIENT_SECRET (default DERIBIT_CLIENT_ID / _SECRET;
+//	                                 an empty secret skips the signature check)
+//	FIXSIM_LOGON_SKEW_SEC=60        (max logon timestamp age)
+//
+// The market is configured by MarketConfig, fills by paper.Config
+// (HEDGE_PAPER_LATENCY_MS, HEDGE_PAPER_FEE*).
+type Config struct {
+	Addr      string
+	Sender    string
+	Client    string
+	ClientID  string
+	Secret    string
+	LogonSkew time.Duration
+	Market    MarketConfig
+	Matching  paper.Config
+}
+
+// ConfigFromEnv reads FIXSIM_* settings.
+func ConfigFromEnv() Config {
+	return Config{
+		Addr:      env("FIXSIM_ADDR", "127.0.0.1:9881"),
+		Sender:    env("FIXSIM_SENDER", "DERIBITSERVER"),
+		Client:    env("FIXSIM_CLIENT", ""),
+		ClientID:  env("FIXSIM_CLIENT_ID", os.Getenv("DERIBIT_CLIENT_ID")),
+		Secret:    env("FIXSIM_CLIENT_SECRET", os.Getenv("DERIBIT_CLIENT_SECRET")),
+		LogonSkew: time.Duration(envFloat("FIXSIM_LOGON_SKEW_SEC", 60) * float64(time.Second)),
+		Market:    MarketConfigFromEnv(),
+		Matching:  paper.ConfigFromEnv(),
+	}
+}
+
+func env(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+func envFloat(key string, def float64) float64 {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if x, err := strconv.ParseFloat(v, 64); err == nil && x >= 0 {
+			return x
+		}
+	}
+	return def
+}
+
+// Server implements quickfix.Application for the simulated gateway. Order
+// reports go to the session that logged on last: one order-entry client.
+type Server struct {
+	cfg      Config
+	market   *Market
+	matcher  *paper.Simulator
+	acceptor *quickfix.Acceptor
+	session  atomic.Pointer[quickfix.SessionID]
+	cancel   context.CancelFunc
+}
+
+// New builds the server; Start listens.
+func New(cfg Config) (*Server, error) {
+	if cfg.Client == "" {
+		return nil, fmt.Errorf("fixsim: FIXSIM_CLIENT (the hedger's SenderCompID) is required")
+	}
+	m, err := NewMarket(cfg.Market)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{cfg: cfg, market: m, matcher: paper.New(cfg.Matching)}
+	s.matcher.SetOutput(s.sendReport)
+	return s, nil
+}
+
+// settings builds the acceptor configuration (one session for the client).
+func (s *Server) settings() (*quickfix.Settings, error) {
+	host, port, ok := strings.Cut(s.cfg.Addr, ":")
+	if !ok {
+		return nil, fmt.Errorf("fixsim: bad FIXSIM_ADDR %q", s.cfg.Addr)
+	}
+	cfg := fmt.Sprintf(`[DEFAULT]
+ConnectionType=acceptor
+SocketAcceptHost=%s
+SocketAcceptPort=%s
+SenderCompID=%s
+StartTime=00:00:00
+EndTime=23:59:59
+ResetOnLogon=Y
+UseDataDictionary=N
+ValidateIncomingMessage=N
+RejectInvalidMessage=N
+CheckLatency=N
+
+[SESSION]
+BeginString=FIX.4.4
+TargetCompID=%s
+`, host, port, s.cfg.Sender, s.cfg.Client)
+	return quickfix.ParseSettings(strings.NewReader(cfg))
+}
+
+// Start begins streaming the market and accepts the client session.
+func (s *Server) Start() error {
+	settings, err := s.settings()
+	if err != nil {
+		return err
+	}
+	a, err := quickfix.NewAcceptor(s, quickfix.NewMemoryStoreFactory(), settings, quickfix.NewNullLogFactory())
+	if err != nil {
+		return err
+	}
+	s.acceptor = a
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	go s.market.Run(ctx, s.send)
+	go s.matcher.Run(ctx)
+	if err := a.Start(); err != nil {
+		return err
+	}
+	log.Printf("[FIXSIM] listening on %s as %s for %s (%s)", s.cfg.Addr, s.cfg.Sender, s.cfg.Client, s.market.Mode())
+	return nil
+}
+
+// Stop logs the client out and stops the market.
+func (s *Server) Stop() {
+	if s.acceptor != nil {
+		s.acceptor.Stop()
+	}
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+func (s *Server) OnCreate(id quickfix.SessionID) {}
+
+func (s *Server) OnLogon(id quickfix.SessionID) {
+	s.session.Store(&id)
+	log.Printf("[FIXSIM] %s logged on", id.TargetCompID)
+}
+
+func (s *Server) OnLogout(id quickfix.SessionID) {
+	s.market.Unsubscribe(id, "")
+	log.Printf("[FIXSIM] %s logged out", id.TargetCompID)
+}
+
+func (s *Server) ToAdmin(msg *quickfix.Message, id quickfix.SessionID) {}
+
+func (s *Server) ToApp(msg *quickfix.Message, id quickfix.SessionID) error { return nil }
+
+// FromAdmin authenticates the Logon (35=A) like Deribit: RawData (96) is
+// "<timestamp ms>.<base64 nonce>" and Password (554) is
+// base64(sha256(RawData + secret)).
+func (s *Server) FromAdmin(msg *quickfix.Message, id quickfix.SessionID) quickfix.MessageRejectError {
+	if t, _ := msg.Header.GetString(35); t != "A" {
+		return nil
+	}
+	if err := s.checkLogon(msg); err != nil {
+		log.Printf("[FIXSIM] logon from %s rejected: %v", id.TargetCompID, err)
+		return quickfix.RejectLogon{Text: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) checkLogon(msg *quickfix.Message) error {
+	user, _ := msg.Body.GetString(553)
+	raw, _ := msg.Body.GetString(96)
+	pass, _ := msg.Body.GetString(554)
+	ts, nonce, ok := strings.Cut(raw, ".")
+	if !ok || pass == "" {
+		return fmt.Errorf("missing RawData/Password")
+	}
+	ms, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad timestamp %q", ts)
+	}
+	if age := time.Since(time.UnixMilli(ms)); age > s.cfg.LogonSkew || age < -s.cfg.LogonSkew {
+		return fmt.Errorf("timestamp off by %s", age.Round(time.Millisecond))
+	}
+	if b, err := base64.StdEncoding.DecodeString(nonce); err != nil || len(b) == 0 {
+		return fmt.Errorf("bad nonce")
+	}
+	if s.cfg.ClientID != "" && user != s.cfg.ClientID {
+		return fmt.Errorf("unknown client id %q", user)
+	}
+	if s.cfg.Secret != "" {
+		sum := sha256.Sum256([]byte(raw + s.cfg.Secret))
+		want := base64.StdEncoding.EncodeToString(sum[:])
+		if subtle.ConstantTimeCompare([]byte(want), []byte(pass)) != 1 {
+			return fmt.Errorf("invalid signature")
+		}
+	}
+	return nil
+}
+
+// FromApp serves market data requests and order entry.
+func (s *Server) FromApp(msg *quickfix.Message, id quickfix.SessionID) quickfix.MessageRejectError {
+	msgType, _ := msg.Header.GetString(35)
+	switch msgType {
+	case "V":
+		s.onMDRequest(msg, id)
+	case "D", "AB", "F", "G", "q":
+		// The matcher keeps the message past this call: hand it a copy
+		cp := quickfix.NewMessage()
+		if err := quickfix.ParseMessage(cp, bytes.NewBuffer(append([]byte(nil), msg.Bytes()...))); err != nil {
+			log.Printf("[FIXSIM] unreadable %s: %v", msgType, err)
+			return nil
+		}
+		s.market.Ensure(orderSymbols(cp))
+		if err := s.matcher.SendOrderMsg(cp); err != nil {
+			log.Printf("[FIXSIM] %s dropped: %v", msgType, err)
+		}
+	default:
+		log.Printf("[FIXSIM] unsupported message type %s", msgType)
+	}
+	return nil
+}
+
+// orderSymbols returns the instruments an order-entry message refers to.
+func orderSymbols(msg *quickfix.Message) []string {
+	var out []string
+	if sym, err := msg.Body.GetString(55); err == nil && sym != "" {
+		out = append(out, sym)
+	}
+	g := quickfix.NewRepeatingGroup(555, quickfix.GroupTemplate{quickfix.GroupElement(600)})
+	if msg.Body.GetGroup(g) == nil {
+		for i := 0; i < g.Len(); i++ {
+			if sym, err := g.Get(i).GetString(600); err == nil {
+				out = append(out, sym)
+			}
+		}
+	}
+	return out
+}
+
+func (s *Server) onMDRequest(msg *quickfix.Message, id quickfix.SessionID) {
+	reqID, _ := msg.Body.GetString(262)
+	subType, _ := msg.Body.GetString(263)
+	var symbols []string
+	g := quickfix.NewRepeatingGroup(146, quickfix.GroupTemplate{quickfix.GroupElement(55)})
+	if msg.Body.GetGroup(g) == nil {
+		for i := 0; i < g.Len(); i++ {
+			if sym, err := g.Get(i).GetString(55); err == nil {
+				symbols = append(symbols, sym)
+			}
+		}
+	}
+	if subType == "2" {
+		for _, sym := range symbols {
+			s.market.Unsubscribe(id, sym)
+		}
+		return
+	}
+	if unknown := s.market.Subscribe(id, reqID, symbols, subType == "1"); len(unknown) > 0 {
+		log.Printf("[FIXSIM] MDReqID=%s: %d unknown symbols (%s)", reqID, len(unknown), strings.Join(unknown, ","))
+		if len(unknown) == len(symbols) {
+			rej := quickfix.NewMessage()
+			rej.Header.SetString(35, "Y")
+			rej.Body.SetString(262, reqID)
+			rej.Body.SetString(281, "0") // unknown symbol
+			rej.Body.SetString(58, "unknown symbol "+strings.Join(unknown, ","))
+			s.send(rej, id)
+		}
+	}
+}
+
+// sendReport is the matcher's output.
+func (s *Server) sendReport(msg *quickfix.Message) {
+	id := s.session.Load()
+	if id == nil {
+		log.Printf("[FIXSIM] no client session, report dropped")
+		return
+	}
+	s.send(msg, *id)
+}
+
+func (s *Server) send(msg *quickfix.Message, id quickfix.SessionID) {
+	if err := quickfix.SendToTarget(msg, id); err != nil {
+		log.Printf("[FIXSIM] send to %s failed: %v", id.TargetCompID, err)
+	}
+}
@@ -0,0 +1,214 @@
+package fixsim
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"Options_Hedger/internal/data"
+	"Options_Hedger/internal/fix"
+	"Options_Hedger/internal/paper"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/quickfix"
+)
+
+// logonMsg builds a Deribit-style Logon body signed with secret.
+func logonMsg(user, secret string, at time.Time) *quickfix.Message {
+	raw := strconv.FormatInt(at.UnixMilli(), 10) + "." + base64.StdEncoding.EncodeToString([]byte("nonce"))
+	sum := sha256.Sum256([]byte(raw + secret))
+	m := quickfix.NewMessage()
+	m.Header.SetString(35, "A")
+	m.Body.SetString(553, user)
+	m.Body.SetString(96, raw)
+	m.Body.SetString(554, base64.StdEncoding.EncodeToString(sum[:]))
+	return m
+}
+
+func TestCheckLogon(t *testing.T) {
+	s := &Server{cfg: Config{ClientID: "id", Secret: "secret", LogonSkew: time.Minute}}
+	now := time.Now()
+	tests := []struct {
+		name string
+		msg  *quickfix.Message
+		ok   bool
+	}{
+		{"valid", logonMsg("id", "secret", now), true},
+		{"wrong secret", logonMsg("id", "other", now), false},
+		{"unknown client", logonMsg("someone", "secret", now), false},
+		{"stale timestamp", logonMsg("id", "secret", now.Add(-2*time.Minute)), false},
+		{"no password", func() *quickfix.Message {
+			m := logonMsg("id", "secret", now)
+			m.Body.Remove(554)
+			return m
+		}(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.checkLogon(tt.msg); (err == nil) != tt.ok {
+				t.Fatalf("checkLogon() = %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+// mdTap records the message types received per symbol.
+type mdTap struct {
+	mu   sync.Mutex
+	seen map[string]bool // "W:<symbol>" / "X:<symbol>"
+}
+
+func (t *mdTap) OnRawMD(raw []byte, _ int64) {
+	typ, sym := fieldOf(raw, "35"), fieldOf(raw, "55")
+	t.mu.Lock()
+	t.seen[typ+":"+sym] = true
+	t.mu.Unlock()
+}
+
+func (t *mdTap) has(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.seen[key]
+}
+
+func fieldOf(raw []byte, tag string) string {
+	for _, f := range bytes.Split(raw, []byte{1}) {
+		if k, v, ok := strings.Cut(string(f), "="); ok && k == tag {
+			return v
+		}
+	}
+	return ""
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// TestSessionRoundTrip runs the hedger's FIX application against the
+// simulator on a loopback port: signed logon, 35=V answered by W then X,
+// and an IOC 35=D filled through 35=8.
+func TestSessionRoundTrip(t *testing.T) {
+	t.Setenv("DERIBIT_CLIENT_ID", "hedger-id")
+	t.Setenv("DERIBIT_CLIENT_SECRET", "hedger-secret")
+	t.Setenv("FIX_CANCEL_ON_EXIT", "0")
+
+	port := freePort(t)
+	mkt := MarketConfigFromEnv()
+	mkt.Step, mkt.Requote, mkt.Capacity = 20*time.Millisecond, 1, 64
+	srv, err := New(Config{
+		Addr:      fmt.Sprintf("127.0.0.1:%d", port),
+		Sender:    "DERIBITSERVER",
+		Client:    "HEDGER",
+		ClientID:  "hedger-id",
+		Secret:    "hedger-secret",
+		LogonSkew: time.Minute,
+		Market:    mkt,
+		Matching:  paper.Config{Latency: time.Millisecond, MatchEvery: 5 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+
+	// One option a month out; simulator and client share this process's book slot
+	sym := "BTC-" + strings.ToUpper(time.Now().AddDate(0, 1, 0).Format("2Jan06")) + "-100000-C"
+	idx, err := data.AddSymbol(sym)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fix.SetOptionSymbols([]string{sym})
+	tap := &mdTap{seen: map[string]bool{}}
+	fix.SetRawMDTap(tap)
+	defer fix.SetRawMDTap(nil)
+
+	dir := t.TempDir()
+	cfg := fmt.Sprintf(`[DEFAULT]
+ConnectionType=initiator
+BeginString=FIX.4.4
+ReconnectInterval=1
+SenderCompID=HEDGER
+TargetCompID=DERIBITSERVER
+FileStorePath=%s
+HeartBtInt=30
+StartTime=00:00:00
+EndTime=23:59:59
+UseDataDictionary=N
+
+[SESSION]
+ResetOnLogon=Y
+SocketConnectHost=127.0.0.1
+SocketConnectPort=%d
+ValidateIncomingMessage=N
+RejectInvalidMessage=N
+CheckLatency=N
+`, filepath.Join(dir, "store"), port)
+	path := filepath.Join(dir, "quickfix.cfg")
+	if err := os.WriteFile(path, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fix.InitFIXEngine(path); err != nil {
+		t.Fatal(err)
+	}
+	defer fix.StopFIXEngine()
+
+	waitFor(t, "logon", fix.LoggedOn)
+	waitFor(t, "snapshot (35=W)", func() bool { return tap.has("W:" + sym) })
+	waitFor(t, "incremental refresh (35=X)", func() bool { return tap.has("X:" + sym) })
+
+	var ask, askQty float64
+	waitFor(t, "two-sided book", func() bool {
+		d := data.ReadDepthFast(int(idx))
+		ask, askQty = d.AskPrice, d.AskQty
+		return d.BidPrice > 0 && ask > 0 && askQty > 0
+	})
+
+	id, err := fix.SendOrderReq(&fix.OrderReq{
+		Symbol: sym,
+		Side:   enum.Side_BUY,
+		Price:  ask * 1.5, // marketable through any requote
+		Qty:    1,
+		TIF:    enum.TimeInForce_IMMEDIATE_OR_CANCEL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	o, err := fix.WaitOrder(ctx, id)
+	if err != nil {
+		t.Fatalf("WaitOrder: %v (state %s)", err, o.State)
+	}
+	if o.State != fix.OrdFilled || o.CumQty != 1 || o.AvgPx <= 0 || o.OrderID == "" {
+		t.Fatalf("order = %s cum=%g avg=%g id=%q, want filled 1 with an exchange id", o.State, o.CumQty, o.AvgPx, o.OrderID)
+	}
+}
@@ -2,6 +2,7 @@ package notify
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -85,3 +86,57 @@ func (t *Telegram) Send(ctx context.Context, text string) error {
 	}
 	return nil
 }
+
+// Commands long-polls getUpdates until ctx is done and passes every text
+// message from the configured chat to handle; a non-empty reply is sent
+// back. Messages sent before Commands started are ignored so a backlog is
+// never replayed after a restart.
+func (t *Telegram) Commands(ctx context.Context, handle func(text string) string) {
+	client := &http.Client{Timeout: 40 * time.Second}
+	since := time.Now().Unix()
+	var offset int64
+	for ctx.Err() == nil {
+		u := fmt.Sprintf("%s/bot%s/getUpdates?timeout=30&offset=%d", t.apiBase, t.token, offset)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return
+		}
+		var r struct {
+			OK     bool `json:"ok"`
+			Result []struct {
+				UpdateID int64 `json:"update_id"`
+				Message  *struct {
+					Date int64  `json:"date"`
+					Text string `json:"text"`
+					Chat struct {
+						ID int64 `json:"id"`
+					} `json:"chat"`
+				} `json:"message"`
+			} `json:"result"`
+		}
+		resp, err := client.Do(req)
+		if err == nil {
+			err = json.NewDecoder(resp.Body).Decode(&r)
+			resp.Body.Close()
+		}
+		if err != nil || !r.OK {
+			select {
+			case <-ctx.Done():
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+		for _, up := range r.Result {
+			offset = up.UpdateID + 1
+			m := up.Message
+			if m == nil || m.Chat.ID != t.chatID || m.Date < since || m.Text == "" {
+				continue
+			}
+			if reply := handle(m.Text); reply != "" {
+				sctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+				_ = t.Send(sctx, reply)
+				cancel()
+			}
+		}
+	}
+}
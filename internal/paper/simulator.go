@@ -0,0 +1,593 @@
+// Package paper simulates order execution against the live SharedBook so the
+// full pipeline can run without sending orders to Deribit.
+package paper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"Options_Hedger/internal/data"
+	"Options_Hedger/internal/fix"
+
+	"github.com/quickfixgo/enum"
+	"github.com/quickfixgo/fix44/newordermultileg"
+	"github.com/quickfixgo/quickfix"
+)
+
+// Config for the simulator.
+//
+// Env:
+//
+//	HEDGE_PAPER=1                 (enable paper trading)
+//	HEDGE_PAPER_LATENCY_MS=5      (order -> matching delay)
+//	HEDGE_PAPER_FEE=0.0003        (BTC per contract)
+//	HEDGE_PAPER_FEE_CAP=0.125     (fee cap as a fraction of the option price)
+//	HEDGE_PAPER_MATCH_MS=10       (resting order matching interval)
+type Config struct {
+	Latency    time.Duration
+	Fee        float64
+	FeeCap     float64
+	MatchEvery time.Duration
+}
+
+// Enabled reports whether HEDGE_PAPER is set.
+func Enabled() bool { return strings.TrimSpace(os.Getenv("HEDGE_PAPER")) == "1" }
+
+// ConfigFromEnv reads HEDGE_PAPER_* settings.
+func ConfigFromEnv() Config {
+	return Config{
+		Latency:    time.Duration(envFloat("HEDGE_PAPER_LATENCY_MS", 5) * float64(time.Millisecond)),
+		Fee:        envFloat("HEDGE_PAPER_FEE", 0.0003),
+		FeeCap:     envFloat("HEDGE_PAPER_FEE_CAP", 0.125),
+		MatchEvery: time.Duration(envFloat("HEDGE_PAPER_MATCH_MS", 10) * float64(time.Millisecond)),
+	}
+}
+
+func envFloat(key string, def float64) float64 {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if x, err := strconv.ParseFloat(v, 64); err == nil && x >= 0 {
+			return x
+		}
+	}
+	return def
+}
+
+// Stats are the simulator counters since start.
+type Stats struct {
+	Orders    uint64  `json:"orders"`
+	Fills     uint64  `json:"fills"`
+	Cancels   uint64  `json:"cancels"`
+	Rejects   uint64  `json:"rejects"`
+	Resting   int     `json:"resting"`
+	FilledQty float64 `json:"filled_qty"`
+	FeesBTC   float64 `json:"fees_btc"`
+}
+
+// ErrQueueFull is returned when the simulator cannot keep up.
+var ErrQueueFull = errors.New("paper: order queue full")
+
+// leg of a simulated order; single orders have one leg with ratio 1.
+type leg struct {
+	idx   int32
+	sym   string
+	side  enum.Side
+	ratio float64
+}
+
+type simOrder struct {
+	clOrdID string
+	orderID string
+	symbol  string // "" for multileg (the order manager keeps the leg names)
+	side    enum.Side
+	price   float64
+	qty     float64
+	tif     enum.TimeInForce
+	legs    []leg
+	cum     float64
+	avgPx   float64
+	legPx   []float64 // multileg: leg prices of the last fill
+}
+
+func (o *simOrder) leaves() float64 { return o.qty - o.cum }
+
+// legSide is the side traded on l: selling a combo trades every leg reversed.
+func (o *simOrder) legSide(l leg) enum.Side {
+	if o.symbol == "" && o.side == enum.Side_SELL {
+		return flip(l.side)
+	}
+	return l.side
+}
+
+type inbound struct {
+	due time.Time
+	msg *quickfix.Message
+}
+
+// taken is how much of a displayed touch the simulator already consumed;
+// it resets whenever the touch is republished.
+type taken struct {
+	stamp int64
+	qty   float64
+}
+
+// Simulator is a fix.OrderTransport that fills orders against the current
+// top of book, limited to the displayed quantity, and reports back through
+// fix.Deliver like the exchange would.
+type Simulator struct {
+	cfg Config
+	in  chan inbound
+	out func(*quickfix.Message) // fix.Deliver unless SetOutput
+
+	mu      sync.Mutex
+	resting map[string]*simOrder // by ClOrdID
+	taken   map[int64]*taken     // by idx<<1 | isBid
+	stats   Stats
+
+	seq uint64
+}
+
+// New returns a simulator; call Install and run it with Run.
+func New(cfg Config) *Simulator {
+	return &Simulator{
+		cfg:     cfg,
+		in:      make(chan inbound, 1024),
+		out:     func(m *quickfix.Message) { fix.Deliver(m) },
+		resting: make(map[string]*simOrder),
+		taken:   make(map[int64]*taken),
+	}
+}
+
+var active atomic.Pointer[Simulator]
+
+// Install routes all order entry to s.
+func Install(s *Simulator) {
+	active.Store(s)
+	fix.SetOrderTransport(s)
+	log.Printf("[PAPER] paper trading: orders are simulated (%+v)", s.cfg)
+}
+
+// SetOutput sends the simulator's reports to out instead of fix.Deliver
+// (e.g. onto a FIX session). Call before Run.
+func (s *Simulator) SetOutput(out func(*quickfix.Message)) { s.out = out }
+
+// Active returns the installed simulator (nil in live mode).
+func Active() *Simulator { return active.Load() }
+
+// SendOrderMsg implements fix.OrderTransport.
+func (s *Simulator) SendOrderMsg(msg *quickfix.Message) error {
+	select {
+	case s.in <- inbound{due: time.Now().Add(s.cfg.Latency), msg: msg}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Stats returns the current counters.
+func (s *Simulator) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stats
+	st.Resting = len(s.resting)
+	return st
+}
+
+// Run processes requests in arrival order and matches resting orders until
+// ctx is done.
+func (s *Simulator) Run(ctx context.Context) {
+	t := time.NewTicker(s.cfg.MatchEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case r := <-s.in:
+			if d := time.Until(r.due); d > 0 {
+				time.Sleep(d)
+			}
+			s.handle(r.msg)
+		case <-t.C:
+			s.matchResting()
+		}
+	}
+}
+
+func (s *Simulator) handle(msg *quickfix.Message) {
+	msgType, _ := msg.Header.GetString(quickfix.Tag(35))
+	switch msgType {
+	case "D":
+		s.onNewOrder(msg, nil)
+	case "AB":
+		legs, err := parseLegs(msg)
+		if err != nil {
+			s.reject(msg, err.Error())
+			return
+		}
+		s.onNewOrder(msg, legs)
+	case "F":
+		s.onCancel(msg)
+	case "G":
+		s.onReplace(msg)
+	case "q":
+		s.onMassCancel(msg)
+	default:
+		log.Printf("[PAPER] unsupported message type %s", msgType)
+	}
+}
+
+func (s *Simulator) onNewOrder(msg *quickfix.Message, legs []leg) {
+	o := &simOrder{
+		clOrdID: str(msg, 11),
+		side:    enum.Side(str(msg, 54)),
+		price:   num(msg, 44),
+		qty:     num(msg, 38),
+		tif:     enum.TimeInForce(str(msg, 59)),
+		legs:    legs,
+	}
+	if legs == nil {
+		o.symbol = str(msg, 55)
+		idx := data.LookupSymbol(o.symbol)
+		if idx < 0 {
+			s.reject(msg, "unknown instrument "+o.symbol)
+			return
+		}
+		o.legs = []leg{{idx: idx, sym: o.symbol, side: o.side, ratio: 1}}
+	}
+	if o.qty <= 0 {
+		s.reject(msg, "invalid quantity")
+		return
+	}
+	s.mu.Lock()
+	s.seq++
+	o.orderID = fmt.Sprintf("PAPER-%d", s.seq)
+	s.stats.Orders++
+	s.mu.Unlock()
+
+	s.report(o, "", "0", "0", 0, 0, 0, "")
+	s.match(o, false)
+	s.settle(o)
+}
+
+// settle rests or cancels what is left of a freshly matched order.
+func (s *Simulator) settle(o *simOrder) {
+	if o.leaves() <= 1e-12 {
+		return
+	}
+	if o.tif == enum.TimeInForce_IMMEDIATE_OR_CANCEL {
+		s.mu.Lock()
+		s.stats.Cancels++
+		s.mu.Unlock()
+		s.report(o, "", "4", "4", 0, 0, 0, "")
+		return
+	}
+	s.mu.Lock()
+	s.resting[o.clOrdID] = o
+	s.mu.Unlock()
+}
+
+// match fills o against the current touch. Incoming orders take the touch
+// price; resting orders fill at their own limit when the market crosses it.
+func (s *Simulator) match(o *simOrder, resting bool) {
+	rem := o.leaves()
+	if rem <= 1e-12 {
+		return
+	}
+	// Cash paid per unit (buys minus sells) and the units every leg shows
+	net, avail := 0.0, math.Inf(1)
+	legPx := make([]float64, len(o.legs))
+	stamps := make([]int64, len(o.legs))
+	for i, l := range o.legs {
+		side := o.legSide(l)
+		d := data.ReadDepthFast(int(l.idx))
+		px, qty := d.AskPrice, d.AskQty
+		if side == enum.Side_SELL {
+			px, qty = d.BidPrice, d.BidQty
+		}
+		if px <= 0 || qty <= 0 {
+			return
+		}
+		qty -= s.consumed(l.idx, side == enum.Side_SELL, d.LastUpdateNs)
+		avail = math.Min(avail, qty/l.ratio)
+		legPx[i], stamps[i] = px, d.LastUpdateNs
+		if side == enum.Side_BUY {
+			net += px * l.ratio
+		} else {
+			net -= px * l.ratio
+		}
+	}
+	crosses := net <= o.price+1e-12
+	if o.side == enum.Side_SELL {
+		net = -net // received per unit
+		crosses = net >= o.price-1e-12
+	}
+	if !crosses || avail <= 1e-12 {
+		return
+	}
+
+	q := math.Min(rem, avail)
+	px := net
+	if resting {
+		px = o.price
+		// the difference to the touch goes to the first leg
+		l := o.legs[0]
+		d := (px - net) / l.ratio
+		if (o.legSide(l) == enum.Side_BUY) != (o.side == enum.Side_BUY) {
+			d = -d
+		}
+		legPx[0] += d
+	}
+	if o.symbol == "" {
+		o.legPx = legPx
+	}
+	fee := 0.0
+	for i, l := range o.legs {
+		s.consume(l.idx, o.legSide(l) == enum.Side_SELL, stamps[i], q*l.ratio)
+		fee += math.Min(s.cfg.Fee*q*l.ratio, s.cfg.FeeCap*legPx[i]*q*l.ratio)
+	}
+	o.avgPx = (o.avgPx*o.cum + px*q) / (o.cum + q)
+	o.cum += q
+
+	s.mu.Lock()
+	s.stats.Fills++
+	s.stats.FilledQty += q
+	s.stats.FeesBTC += fee
+	if o.leaves() <= 1e-12 {
+		delete(s.resting, o.clOrdID)
+	}
+	s.mu.Unlock()
+
+	status := "1"
+	if o.leaves() <= 1e-12 {
+		status = "2"
+	}
+	s.report(o, "", "F", status, q, px, fee, "")
+}
+
+func (s *Simulator) matchResting() {
+	s.mu.Lock()
+	list := make([]*simOrder, 0, len(s.resting))
+	for _, o := range s.resting {
+		list = append(list, o)
+	}
+	s.mu.Unlock()
+	for _, o := range list {
+		s.match(o, true)
+	}
+}
+
+func (s *Simulator) consumed(idx int32, bid bool, stamp int64) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t := s.taken[takenKey(idx, bid)]; t != nil && t.stamp == stamp {
+		return t.qty
+	}
+	return 0
+}
+
+func (s *Simulator) consume(idx int32, bid bool, stamp int64, qty float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := takenKey(idx, bid)
+	t := s.taken[k]
+	if t == nil || t.stamp != stamp {
+		t = &taken{stamp: stamp}
+		s.taken[k] = t
+	}
+	t.qty += qty
+}
+
+func takenKey(idx int32, bid bool) int64 {
+	k := int64(idx) << 1
+	if bid {
+		k |= 1
+	}
+	return k
+}
+
+func (s *Simulator) onCancel(msg *quickfix.Message) {
+	id, orig := str(msg, 11), str(msg, 41)
+	s.mu.Lock()
+	o := s.resting[orig]
+	delete(s.resting, orig)
+	if o != nil {
+		s.stats.Cancels++
+	}
+	s.mu.Unlock()
+	if o == nil {
+		s.cancelReject(id, orig, "1", "unknown order")
+		return
+	}
+	o.clOrdID = id // the cancel is acknowledged under its own ClOrdID
+	s.report(o, orig, "4", "4", 0, 0, 0, "")
+}
+
+func (s *Simulator) onReplace(msg *quickfix.Message) {
+	id, orig := str(msg, 11), str(msg, 41)
+	s.mu.Lock()
+	o := s.resting[orig]
+	if o != nil {
+		if q := num(msg, 38); q <= o.cum {
+			o = nil
+		} else {
+			delete(s.resting, orig)
+			o.price, o.qty = num(msg, 44), q
+			o.clOrdID = id
+			s.resting[id] = o
+		}
+	}
+	s.mu.Unlock()
+	if o == nil {
+		s.cancelReject(id, orig, "2", "unknown order or quantity below filled")
+		return
+	}
+	status := "0"
+	if o.cum > 0 {
+		status = "1"
+	}
+	s.report(o, orig, "5", status, 0, 0, 0, "")
+	s.match(o, false)
+}
+
+func (s *Simulator) onMassCancel(msg *quickfix.Message) {
+	sym := str(msg, 55)
+	s.mu.Lock()
+	var list []*simOrder
+	for id, o := range s.resting {
+		if sym == "" || o.symbol == sym {
+			list = append(list, o)
+			delete(s.resting, id)
+		}
+	}
+	s.stats.Cancels += uint64(len(list))
+	s.mu.Unlock()
+	for _, o := range list {
+		s.report(o, "", "4", "4", 0, 0, 0, "")
+	}
+
+	r := quickfix.NewMessage()
+	r.Header.SetString(35, "r")
+	r.Body.SetString(11, str(msg, 11))
+	typ := str(msg, 530)
+	if typ == "" {
+		typ = string(enum.MassCancelResponse_CANCEL_ALL_ORDERS)
+	}
+	r.Body.SetString(530, typ)
+	r.Body.SetString(531, typ)
+	r.Body.SetString(533, strconv.Itoa(len(list)))
+	s.out(r)
+}
+
+// report delivers an ExecutionReport for o; origID (41) is set on cancel
+// and replace acknowledgements.
+func (s *Simulator) report(o *simOrder, origID, execType, status string, lastQty, lastPx, fee float64, text string) {
+	m := quickfix.NewMessage()
+	m.Header.SetString(35, "8")
+	b := &m.Body
+	b.SetString(11, o.clOrdID)
+	if origID != "" {
+		b.SetString(41, origID)
+	}
+	b.SetString(37, o.orderID)
+	b.SetString(17, s.execID())
+	b.SetString(150, execType)
+	b.SetString(39, status)
+	if o.symbol != "" {
+		b.SetString(55, o.symbol)
+	}
+	b.SetString(54, string(o.side))
+	b.SetString(44, fmtNum(o.price))
+	b.SetString(38, fmtNum(o.qty))
+	b.SetString(14, fmtNum(o.cum))
+	leaves := o.leaves()
+	if status == "4" || status == "8" {
+		leaves = 0
+	}
+	b.SetString(151, fmtNum(leaves))
+	b.SetString(6, fmtNum(o.avgPx))
+	if lastQty > 0 {
+		b.SetString(32, fmtNum(lastQty))
+		b.SetString(31, fmtNum(lastPx))
+		b.SetString(12, fmtNum(fee))
+		if o.symbol == "" && len(o.legPx) == len(o.legs) {
+			g := quickfix.NewRepeatingGroup(555, quickfix.GroupTemplate{
+				quickfix.GroupElement(600), quickfix.GroupElement(624), quickfix.GroupElement(637),
+			})
+			for i, l := range o.legs {
+				e := g.Add()
+				e.SetString(600, l.sym)
+				e.SetString(624, string(l.side))
+				e.SetString(637, fmtNum(o.legPx[i]))
+			}
+			b.SetGroup(g)
+		}
+	}
+	if text != "" {
+		b.SetString(58, text)
+	}
+	s.out(m)
+}
+
+func (s *Simulator) reject(msg *quickfix.Message, text string) {
+	s.mu.Lock()
+	s.stats.Rejects++
+	s.seq++
+	id := fmt.Sprintf("PAPER-%d", s.seq)
+	s.mu.Unlock()
+	o := &simOrder{clOrdID: str(msg, 11), orderID: id, symbol: str(msg, 55), side: enum.Side(str(msg, 54)),
+		price: num(msg, 44), qty: num(msg, 38)}
+	s.report(o, "", "8", "8", 0, 0, 0, text)
+}
+
+func (s *Simulator) cancelReject(id, orig, responseTo, text string) {
+	m := quickfix.NewMessage()
+	m.Header.SetString(35, "9")
+	m.Body.SetString(11, id)
+	m.Body.SetString(41, orig)
+	m.Body.SetString(39, "8")
+	m.Body.SetString(434, responseTo)
+	m.Body.SetString(102, "1")
+	m.Body.SetString(58, text)
+	s.out(m)
+}
+
+func (s *Simulator) execID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	return fmt.Sprintf("PX-%d", s.seq)
+}
+
+func parseLegs(msg *quickfix.Message) ([]leg, error) {
+	g, err := newordermultileg.FromMessage(msg).GetNoLegs()
+	if err != nil {
+		return nil, fmt.Errorf("invalid legs: %v", err)
+	}
+	legs := make([]leg, 0, g.Len())
+	for i := 0; i < g.Len(); i++ {
+		e := g.Get(i)
+		sym, _ := e.GetLegSymbol()
+		side, _ := e.GetLegSide()
+		ratio, _ := e.GetLegRatioQty()
+		idx := data.LookupSymbol(sym)
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown instrument %s", sym)
+		}
+		r, _ := ratio.Float64()
+		if r <= 0 {
+			r = 1
+		}
+		legs = append(legs, leg{idx: idx, sym: sym, side: enum.Side(side), ratio: r})
+	}
+	if len(legs) == 0 {
+		return nil, errors.New("no legs")
+	}
+	return legs, nil
+}
+
+func str(msg *quickfix.Message, tag quickfix.Tag) string {
+	v, _ := msg.Body.GetString(tag)
+	return v
+}
+
+func num(msg *quickfix.Message, tag quickfix.Tag) float64 {
+	v, _ := strconv.ParseFloat(str(msg, tag), 64)
+	return v
+}
+
+func fmtNum(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+func flip(s enum.Side) enum.Side {
+	if s == enum.Side_BUY {
+		return enum.Side_SELL
+	}
+	return enum.Side_BUY
+}
@@ -0,0 +1,243 @@
+// Package positions keeps the hedger's option positions per instrument,
+// built from fills and reconciled against Deribit.
+package positions
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"Options_Hedger/internal/data"
+	"Options_Hedger/internal/fix"
+
+	"github.com/quickfixgo/enum"
+)
+
+// Position is the net position of one instrument.
+type Position struct {
+	Symbol      string    `json:"symbol"`
+	Qty         float64   `json:"qty"`          // signed contracts, + long
+	AvgPx       float64   `json:"avg_price"`    // BTC, of the open quantity
+	RealizedBTC float64   `json:"realized_btc"` // net of fees
+	FeesBTC     float64   `json:"fees_btc"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// apply adds a signed fill, realizing PnL on the closed part.
+func (p *Position) apply(q, px float64) {
+	switch {
+	case p.Qty == 0 || (p.Qty > 0) == (q > 0):
+		p.AvgPx = (p.AvgPx*abs(p.Qty) + px*abs(q)) / (abs(p.Qty) + abs(q))
+		p.Qty += q
+	default:
+		closed := min(abs(q), abs(p.Qty))
+		sign := 1.0
+		if p.Qty < 0 {
+			sign = -1
+		}
+		p.RealizedBTC += (px - p.AvgPx) * closed * sign
+		p.Qty += q
+		if abs(p.Qty) < 1e-12 {
+			p.Qty, p.AvgPx = 0, 0
+		} else if (p.Qty > 0) != (sign > 0) {
+			p.AvgPx = px // flipped through zero
+		}
+	}
+	p.UpdatedAt = time.Now()
+}
+
+var (
+	mu   sync.RWMutex
+	book = map[string]*Position{}
+)
+
+// Get returns the position of symbol (zero if flat/unknown).
+func Get(symbol string) Position {
+	mu.RLock()
+	defer mu.RUnlock()
+	if p, ok := book[symbol]; ok {
+		return *p
+	}
+	return Position{Symbol: symbol}
+}
+
+// All returns every non-flat position sorted by symbol.
+func All() []Position {
+	mu.RLock()
+	out := make([]Position, 0, len(book))
+	for _, p := range book {
+		if p.Qty != 0 {
+			out = append(out, *p)
+		}
+	}
+	mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Symbol < out[j].Symbol })
+	return out
+}
+
+// Filter returns the non-flat positions for which keep returns true.
+func Filter(keep func(p Position, m data.InstrumentMeta) bool) []Position {
+	var out []Position
+	for _, p := range All() {
+		m, _ := data.ResolveInstrument(p.Symbol)
+		if keep(p, m) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// PnLBTC returns realized PnL and the open PnL of every position marked at
+// the book mid (positions without a quote are marked at their average price).
+func PnLBTC() (realized, open float64) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, p := range book {
+		realized += p.RealizedBTC
+		if p.Qty == 0 {
+			continue
+		}
+		if m := midOf(p.Symbol); m > 0 {
+			open += (m - p.AvgPx) * p.Qty
+		}
+	}
+	return realized, open
+}
+
+// ApplyFill records a fill of qty at px (BTC) on symbol.
+func ApplyFill(symbol string, side enum.Side, qty, px float64) {
+	if qty <= 0 || symbol == "" {
+		return
+	}
+	q := qty
+	if side == enum.Side_SELL {
+		q = -qty
+	}
+	mu.Lock()
+	p, ok := book[symbol]
+	if !ok {
+		p = &Position{Symbol: symbol}
+		book[symbol] = p
+	}
+	p.apply(q, px)
+	mu.Unlock()
+}
+
+// applyFee charges a commission to symbol's realized PnL.
+func applyFee(symbol string, fee float64) {
+	if fee == 0 || symbol == "" {
+		return
+	}
+	mu.Lock()
+	p, ok := book[symbol]
+	if !ok {
+		p = &Position{Symbol: symbol}
+		book[symbol] = p
+	}
+	p.FeesBTC += fee
+	p.RealizedBTC -= fee
+	mu.Unlock()
+}
+
+// onOrderEvent feeds fills from the FIX order manager. Multileg fills are
+// split into legs at the leg prices of the report; without them the legs are
+// priced at the current mid, shifted to add up to the combo price. The order
+// manager reports each ExecID as EvFill once, so resent fills never get here.
+func onOrderEvent(ev fix.OrderEvent) {
+	if ev.Kind != fix.EvFill || ev.Order.LastQty <= 0 {
+		return
+	}
+	o := ev.Order
+
+	if len(o.Legs) == 0 {
+		ApplyFill(o.Symbol, o.Side, o.LastQty, o.LastPx)
+		applyFee(o.Symbol, o.LastFee)
+		return
+	}
+	px := legPrices(o)
+	for i, l := range o.Legs {
+		side := l.Side
+		if o.Side == enum.Side_SELL {
+			side = flip(side)
+		}
+		ApplyFill(l.Symbol, side, o.LastQty*ratioOf(l), px[i])
+		applyFee(l.Symbol, o.LastFee/float64(len(o.Legs)))
+	}
+}
+
+// legPrices returns the price of every leg of the last combo fill. Unpriced
+// legs are marked at the mid and the first of them absorbs the difference
+// to the combo price (LastPx, per unit of the combo bought).
+func legPrices(o fix.Order) []float64 {
+	px := make([]float64, len(o.Legs))
+	net, first := 0.0, -1
+	for i, l := range o.Legs {
+		px[i] = l.LastPx
+		if px[i] <= 0 {
+			px[i] = midOf(l.Symbol)
+			if first < 0 {
+				first = i
+			}
+		}
+		if l.Side == enum.Side_BUY {
+			net += px[i] * ratioOf(l)
+		} else {
+			net -= px[i] * ratioOf(l)
+		}
+	}
+	if first < 0 {
+		return px
+	}
+	l := o.Legs[first]
+	d := (o.LastPx - net) / ratioOf(l)
+	if l.Side == enum.Side_SELL {
+		d = -d
+	}
+	px[first] += d
+	return px
+}
+
+func ratioOf(l fix.MultilegLeg) float64 {
+	if l.Ratio <= 0 {
+		return 1
+	}
+	return l.Ratio
+}
+
+// set overwrites a position with exchange values (reconciliation).
+func set(symbol string, qty, avgPx float64) {
+	mu.Lock()
+	p, ok := book[symbol]
+	if !ok {
+		p = &Position{Symbol: symbol}
+		book[symbol] = p
+	}
+	p.Qty, p.AvgPx, p.UpdatedAt = qty, avgPx, time.Now()
+	mu.Unlock()
+}
+
+func midOf(symbol string) float64 {
+	idx := data.LookupSymbol(symbol)
+	if idx < 0 {
+		return 0
+	}
+	d := data.ReadDepthFast(int(idx))
+	if d.BidPrice > 0 && d.AskPrice > 0 {
+		return (d.BidPrice + d.AskPrice) / 2
+	}
+	return d.BidPrice + d.AskPrice
+}
+
+func flip(s enum.Side) enum.Side {
+	if s == enum.Side_BUY {
+		return enum.Side_SELL
+	}
+	return enum.Side_BUY
+}
+
+func abs(x float64) float64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
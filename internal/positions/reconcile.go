@@ -0,0 +1,210 @@
+package positions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Options_Hedger/internal/auth"
+	"Options_Hedger/internal/fix"
+	"Options_Hedger/internal/notify"
+)
+
+// Mismatch is a difference between the local book and Deribit.
+type Mismatch struct {
+	Symbol   string  `json:"symbol"`
+	Local    float64 `json:"local"`
+	Exchange float64 `json:"exchange"`
+}
+
+// Reconcile settings.
+//
+// Env:
+//
+//	HEDGE_POS_RECONCILE=on      (off: no Deribit load or reconcile, e.g. against cmd/fixsim)
+//	HEDGE_POS_RECONCILE_SEC=60  (0 = startup only)
+//	HEDGE_POS_ADOPT=1           (overwrite the local book with exchange values on confirmed mismatch)
+var (
+	reconcileEvery time.Duration
+	adopt          bool
+	notifier       notify.Notifier
+
+	recMu    sync.Mutex
+	pending  map[string]float64 // mismatches seen once: symbol -> exchange qty
+	lastRecs time.Time
+)
+
+// Track follows fills from the FIX order manager only: the book starts flat
+// and is never reconciled (paper trading).
+func Track() { fix.OnOrderEvent(onOrderEvent) }
+
+// Start loads positions from Deribit, follows fills from the FIX order
+// manager and reconciles periodically until ctx is done. With
+// HEDGE_POS_RECONCILE=off it only tracks fills, like Track.
+func Start(ctx context.Context, ntf notify.Notifier) {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("HEDGE_POS_RECONCILE"))) {
+	case "off", "0", "false":
+		log.Printf("[POS] reconcile off: book starts flat, Deribit positions not loaded")
+		Track()
+		return
+	}
+	notifier = ntf
+	reconcileEvery = time.Duration(envInt("HEDGE_POS_RECONCILE_SEC", 60)) * time.Second
+	adopt = strings.TrimSpace(os.Getenv("HEDGE_POS_ADOPT")) != "0"
+
+	Track()
+
+	if ex, err := fetchPositions(ctx); err != nil {
+		log.Printf("[POS] initial load failed: %v", err)
+	} else {
+		for sym, p := range ex {
+			set(sym, p.Size, p.AveragePrice)
+		}
+		log.Printf("[POS] loaded %d positions from Deribit", len(ex))
+	}
+
+	if reconcileEvery <= 0 {
+		return
+	}
+	go func() {
+		t := time.NewTicker(reconcileEvery)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if _, err := Reconcile(ctx); err != nil {
+					log.Printf("[POS] reconcile failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Reconcile compares the local book with private/get_positions. A mismatch
+// must show up twice in a row (fills racing the snapshot) before it is
+// alerted and, with HEDGE_POS_ADOPT, adopted. Confirmed mismatches are returned.
+func Reconcile(ctx context.Context) ([]Mismatch, error) {
+	ex, err := fetchPositions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	syms := map[string]struct{}{}
+	for s := range ex {
+		syms[s] = struct{}{}
+	}
+	for _, p := range All() {
+		syms[p.Symbol] = struct{}{}
+	}
+
+	recMu.Lock()
+	prev := pending
+	pending = map[string]float64{}
+	var confirmed []Mismatch
+	for s := range syms {
+		local, remote := Get(s).Qty, ex[s].Size
+		if abs(local-remote) < 1e-9 {
+			continue
+		}
+		if q, ok := prev[s]; ok && abs(q-remote) < 1e-9 {
+			confirmed = append(confirmed, Mismatch{Symbol: s, Local: local, Exchange: remote})
+		} else {
+			pending[s] = remote
+		}
+	}
+	lastRecs = time.Now()
+	recMu.Unlock()
+
+	if len(confirmed) == 0 {
+		return nil, nil
+	}
+	sort.Slice(confirmed, func(i, j int) bool { return confirmed[i].Symbol < confirmed[j].Symbol })
+	msg := "[POSITION MISMATCH]"
+	for _, m := range confirmed {
+		msg += fmt.Sprintf("\n%s local=%g deribit=%g", m.Symbol, m.Local, m.Exchange)
+		if adopt {
+			set(m.Symbol, ex[m.Symbol].Size, ex[m.Symbol].AveragePrice)
+		}
+	}
+	if adopt {
+		msg += "\n(local book adopted Deribit values)"
+	}
+	log.Print(msg)
+	if notifier != nil {
+		nctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		_ = notifier.Send(nctx, msg)
+		cancel()
+	}
+	return confirmed, nil
+}
+
+// LastReconcile returns the time of the last successful reconciliation.
+func LastReconcile() time.Time {
+	recMu.Lock()
+	defer recMu.Unlock()
+	return lastRecs
+}
+
+type restPosition struct {
+	Instrument   string  `json:"instrument_name"`
+	Size         float64 `json:"size"` // signed
+	AveragePrice float64 `json:"average_price"`
+}
+
+// fetchPositions calls private/get_positions for BTC options.
+func fetchPositions(ctx context.Context) (map[string]restPosition, error) {
+	token, err := auth.FetchToken(os.Getenv("DERIBIT_CLIENT_ID"), os.Getenv("DERIBIT_CLIENT_SECRET"))
+	if err != nil {
+		return nil, err
+	}
+	rctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(rctx, http.MethodGet,
+		"https://www.deribit.com/api/v2/private/get_positions?currency=BTC&kind=option", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("[POS] fetch failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	var r struct {
+		Result []restPosition `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("[POS] decode failed: %w", err)
+	}
+	if r.Error != nil {
+		return nil, fmt.Errorf("[POS] deribit: %s", r.Error.Message)
+	}
+	out := make(map[string]restPosition, len(r.Result))
+	for _, p := range r.Result {
+		if p.Size != 0 {
+			out[p.Instrument] = p
+		}
+	}
+	return out, nil
+}
+
+func envInt(key string, def int) int {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if x, err := strconv.Atoi(v); err == nil && x >= 0 {
+			return x
+		}
+	}
+	return def
+}
@@ -0,0 +1,135 @@
+// Package pricing values Deribit inverse (BTC-settled) European options with
+// Black-76 on the index: the premium is quoted in BTC, the model works in USD
+// and converts at the forward (premium BTC = value USD / F).
+package pricing
+
+import "math"
+
+const (
+	msPerYear = 365 * 24 * 3600 * 1000.0
+
+	minVol = 1e-4
+	maxVol = 10.0
+)
+
+// YearFrac returns the time from nowMs to expiryMs in years (ACT/365).
+func YearFrac(expiryMs, nowMs int64) float64 {
+	return float64(expiryMs-nowMs) / msPerYear
+}
+
+// PriceUSD returns the discounted Black-76 value in USD of one option on F.
+func PriceUSD(call bool, F, K, T, r, vol float64) float64 {
+	df := math.Exp(-r * math.Max(T, 0))
+	if T <= 0 || vol <= 0 {
+		if call {
+			return df * math.Max(F-K, 0)
+		}
+		return df * math.Max(K-F, 0)
+	}
+	d1, d2 := d12(F, K, T, vol)
+	if call {
+		return df * (F*normCDF(d1) - K*normCDF(d2))
+	}
+	return df * (K*normCDF(-d2) - F*normCDF(-d1))
+}
+
+// Price returns the premium in BTC (the Deribit quote) of one option.
+func Price(call bool, F, K, T, r, vol float64) float64 {
+	if F <= 0 {
+		return 0
+	}
+	return PriceUSD(call, F, K, T, r, vol) / F
+}
+
+// ImpliedVol solves Price(vol) = premium (BTC). ok is false when the premium
+// is outside the no-arbitrage range or the solver does not converge.
+func ImpliedVol(call bool, premium, F, K, T, r float64) (float64, bool) {
+	if premium <= 0 || F <= 0 || K <= 0 || T <= 0 {
+		return 0, false
+	}
+	target := premium * F
+	if target <= PriceUSD(call, F, K, T, r, minVol) || target >= PriceUSD(call, F, K, T, r, maxVol) {
+		return 0, false
+	}
+
+	// Newton from the Brenner-Subrahmanyam guess, kept inside a bisection bracket
+	lo, hi := minVol, maxVol
+	vol := math.Sqrt(2*math.Pi/T) * target / F
+	if vol <= lo || vol >= hi {
+		vol = 0.5 * (lo + hi)
+	}
+	for i := 0; i < 100; i++ {
+		diff := PriceUSD(call, F, K, T, r, vol) - target
+		if math.Abs(diff) < 1e-9*F {
+			return vol, true
+		}
+		if diff > 0 {
+			hi = vol
+		} else {
+			lo = vol
+		}
+		next := vol
+		if v := vegaUSD(F, K, T, r, vol); v > 1e-12 {
+			next = vol - diff/v
+		}
+		if next <= lo || next >= hi {
+			next = 0.5 * (lo + hi)
+		}
+		if math.Abs(next-vol) < 1e-10 {
+			return next, true
+		}
+		vol = next
+	}
+	return 0, false
+}
+
+// Sensitivities of one option, per contract (1 BTC underlying).
+type Sensitivities struct {
+	Delta    float64 // dV/dF, BTC
+	DeltaInv float64 // BTC-settled delta: Delta less the premium held in BTC
+	Gamma    float64 // d2V/dF2, BTC per USD
+	Theta    float64 // USD per calendar day
+	Vega     float64 // USD per vol point (0.01)
+	Rho      float64 // USD per 1% rate
+}
+
+// Greeks returns the sensitivities of the USD value at vol.
+func Greeks(call bool, F, K, T, r, vol float64) Sensitivities {
+	if F <= 0 || K <= 0 || T <= 0 || vol <= 0 {
+		return Sensitivities{}
+	}
+	df := math.Exp(-r * T)
+	sq := math.Sqrt(T)
+	d1, _ := d12(F, K, T, vol)
+	pdf := normPDF(d1)
+	v := PriceUSD(call, F, K, T, r, vol)
+
+	var s Sensitivities
+	if call {
+		s.Delta = df * normCDF(d1)
+	} else {
+		s.Delta = -df * normCDF(-d1)
+	}
+	s.DeltaInv = s.Delta - v/F
+	s.Gamma = df * pdf / (F * vol * sq)
+	s.Vega = df * F * pdf * sq / 100
+	// Black-76: dV/dT = -r*V + df*F*pdf*vol/(2*sqrt(T)); theta is its negative
+	s.Theta = (r*v - df*F*pdf*vol/(2*sq)) / 365
+	s.Rho = -T * v / 100
+	return s
+}
+
+func d12(F, K, T, vol float64) (float64, float64) {
+	sd := vol * math.Sqrt(T)
+	d1 := (math.Log(F/K) + 0.5*sd*sd) / sd
+	return d1, d1 - sd
+}
+
+func vegaUSD(F, K, T, r, vol float64) float64 {
+	d1, _ := d12(F, K, T, vol)
+	return math.Exp(-r*T) * F * normPDF(d1) * math.Sqrt(T)
+}
+
+func normCDF(x float64) float64 { return 0.5 * math.Erfc(-x/math.Sqrt2) }
+
+func normPDF(x float64) float64 { return math.Exp(-0.5*x*x) / math.Sqrt(2*math.Pi) }
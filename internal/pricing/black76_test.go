@@ -0,0 +1,64 @@
+package pricing
+
+import (
+	"math"
+	"testing"
+)
+
+func TestImpliedVolRoundTrip(t *testing.T) {
+	tests := []struct {
+		name          string
+		call          bool
+		F, K, T, r, v float64
+	}{
+		{"atm call", true, 100_000, 100_000, 30.0 / 365, 0, 0.6},
+		{"otm call", true, 100_000, 130_000, 90.0 / 365, 0.05, 0.75},
+		{"otm put", false, 100_000, 70_000, 90.0 / 365, 0.05, 0.9},
+		{"itm put short dated", false, 100_000, 105_000, 2.0 / 365, 0, 0.45},
+		{"low vol", true, 100_000, 102_000, 0.5, 0.03, 0.15},
+		{"high vol long dated", false, 100_000, 60_000, 1.5, 0.04, 1.8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			premium := Price(tt.call, tt.F, tt.K, tt.T, tt.r, tt.v)
+			got, ok := ImpliedVol(tt.call, premium, tt.F, tt.K, tt.T, tt.r)
+			if !ok || math.Abs(got-tt.v) > 1e-6 {
+				t.Fatalf("ImpliedVol(Price(vol=%g)) = %g, %v", tt.v, got, ok)
+			}
+		})
+	}
+}
+
+func TestImpliedVolOutOfRange(t *testing.T) {
+	F, K, T := 100_000.0, 90_000.0, 30.0/365
+	tests := []struct {
+		name    string
+		premium float64
+	}{
+		{"zero premium", 0},
+		{"below intrinsic", 0.9 * (F - K) / F},
+		{"above the forward", 1.01},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v, ok := ImpliedVol(true, tt.premium, F, K, T, 0); ok {
+				t.Fatalf("ImpliedVol(%g) = %g, want no solution", tt.premium, v)
+			}
+		})
+	}
+}
+
+func TestPutCallParity(t *testing.T) {
+	F, K, T, r, vol := 100_000.0, 95_000.0, 60.0/365, 0.05, 0.7
+	c, p := PriceUSD(true, F, K, T, r, vol), PriceUSD(false, F, K, T, r, vol)
+	if want := math.Exp(-r*T) * (F - K); math.Abs(c-p-want) > 1e-6 {
+		t.Fatalf("C-P = %g, want %g", c-p, want)
+	}
+	gc, gp := Greeks(true, F, K, T, r, vol), Greeks(false, F, K, T, r, vol)
+	if d := gc.Delta - gp.Delta; math.Abs(d-math.Exp(-r*T)) > 1e-12 {
+		t.Errorf("call delta - put delta = %g, want the discount factor", d)
+	}
+	if gc.Gamma != gp.Gamma || gc.Vega != gp.Vega {
+		t.Errorf("gamma/vega differ between call and put: %+v %+v", gc, gp)
+	}
+}
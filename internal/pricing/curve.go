@@ -0,0 +1,224 @@
+package pricing
+
+import (
+	"context"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"Options_Hedger/internal/data"
+)
+
+// CurveConfig for the term structure builder.
+//
+// Env:
+//
+//	HEDGE_CURVE_SEC=5          (rebuild interval, 0 disables)
+//	HEDGE_CURVE_MIN_STRIKES=3  (call/put pairs needed per expiry)
+type CurveConfig struct {
+	Interval   time.Duration
+	MinStrikes int
+}
+
+// CurveConfigFromEnv returns the builder config, ok=false when
+// HEDGE_CURVE_SEC=0.
+func CurveConfigFromEnv() (CurveConfig, bool) {
+	c := CurveConfig{Interval: 5 * time.Second, MinStrikes: 3}
+	if v, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv("HEDGE_CURVE_SEC")), 64); err == nil && v >= 0 {
+		c.Interval = time.Duration(v * float64(time.Second))
+	}
+	if v, err := strconv.Atoi(strings.TrimSpace(os.Getenv("HEDGE_CURVE_MIN_STRIKES"))); err == nil && v >= 2 {
+		c.MinStrikes = v
+	}
+	return c, c.Interval > 0
+}
+
+// ExpiryRate is the put-call parity implied discounting of one expiry.
+// Premiums are BTC but paid at today's index S, so in USD
+//
+//	(C - P) * S = DF * (F - K)
+//
+// across strikes: the slope gives the discount factor DF (the rate a box
+// between any two strikes lends at) and the intercept the synthetic forward F.
+type ExpiryRate struct {
+	Expiry   string  `json:"expiry"`
+	ExpiryMs int64   `json:"expiry_ms"`
+	T        float64 `json:"t"`
+	Forward  float64 `json:"forward"`
+	DF       float64 `json:"df"`
+	RateUSD  float64 `json:"rate_usd"` // -ln(DF)/T
+	Basis    float64 `json:"basis"`    // ln(F/S)/T
+	RateBTC  float64 `json:"rate_btc"` // RateUSD - Basis
+	Strikes  int     `json:"strikes"`
+	RMSE     float64 `json:"rmse_usd"` // parity residual per strike
+}
+
+// TermStructure is one build of the curve, expiries in time order.
+type TermStructure struct {
+	At       time.Time    `json:"at"`
+	Index    float64      `json:"index"`
+	Expiries []ExpiryRate `json:"expiries"`
+}
+
+// Expiry returns the rates of an expiry timestamp.
+func (t *TermStructure) Expiry(expiryMs int64) (ExpiryRate, bool) {
+	if t == nil {
+		return ExpiryRate{}, false
+	}
+	for _, e := range t.Expiries {
+		if e.ExpiryMs == expiryMs {
+			return e, true
+		}
+	}
+	return ExpiryRate{}, false
+}
+
+var curve atomic.Pointer[TermStructure]
+
+// Curve returns the latest published term structure (nil before the first).
+func Curve() *TermStructure { return curve.Load() }
+
+// CurveBuilder rebuilds the term structure from the subscribed call/put
+// pairs (the legs the box engine combines) and publishes it for Curve.
+type CurveBuilder struct {
+	cfg CurveConfig
+}
+
+// NewCurveBuilder returns a builder; Run starts it.
+func NewCurveBuilder(cfg CurveConfig) *CurveBuilder {
+	return &CurveBuilder{cfg: cfg}
+}
+
+// Run rebuilds every Interval until ctx is done.
+func (b *CurveBuilder) Run(ctx context.Context) {
+	log.Printf("[CURVE] rebuilding every %s (min %d strikes)", b.cfg.Interval, b.cfg.MinStrikes)
+	t := time.NewTicker(b.cfg.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			b.Build(time.Now())
+		}
+	}
+}
+
+// parity is one strike with both legs quoted: y = (C - P) * S in USD,
+// weighted by the inverse squared width of its quotes.
+type parity struct {
+	k, y, wt float64
+}
+
+// Build computes the term structure once and publishes it.
+func (b *CurveBuilder) Build(now time.Time) *TermStructure {
+	S := data.GetIndexPrice()
+	ts := &TermStructure{At: now, Index: S}
+	if S <= 0 {
+		curve.Store(ts)
+		return ts
+	}
+	nowMs := now.UnixMilli()
+
+	type pair struct{ call, put int32 }
+	type key struct {
+		expiry int64
+		strike float64
+	}
+	pairs := map[key]*pair{}
+	labels := map[int64]string{}
+	n := data.GetSymbolCount()
+	for i := int32(0); i < n; i++ {
+		meta, ok := data.InstrumentAt(i)
+		if !ok || meta.OptionType == data.NotOption || meta.ExpiryMs <= nowMs {
+			continue
+		}
+		k := key{meta.ExpiryMs, meta.Strike}
+		p := pairs[k]
+		if p == nil {
+			p = &pair{-1, -1}
+			pairs[k] = p
+			labels[meta.ExpiryMs] = meta.ExpiryLabel()
+		}
+		if meta.IsCall() {
+			p.call = i
+		} else {
+			p.put = i
+		}
+	}
+
+	chains := map[int64][]parity{}
+	for k, p := range pairs {
+		if p.call < 0 || p.put < 0 || !data.SymbolLive(p.call) || !data.SymbolLive(p.put) {
+			continue
+		}
+		c, q := data.ReadDepthFast(int(p.call)), data.ReadDepthFast(int(p.put))
+		if c.BidPrice <= 0 || c.AskPrice <= 0 || q.BidPrice <= 0 || q.AskPrice <= 0 {
+			continue
+		}
+		mid := 0.5*(c.BidPrice+c.AskPrice) - 0.5*(q.BidPrice+q.AskPrice)
+		width := (c.AskPrice - c.BidPrice + q.AskPrice - q.BidPrice) * S
+		wt := 1 / math.Max(width*width, 1)
+		chains[k.expiry] = append(chains[k.expiry], parity{k: k.strike, y: mid * S, wt: wt})
+	}
+
+	for exp, pts := range chains {
+		if len(pts) < b.cfg.MinStrikes {
+			continue
+		}
+		e, ok := fitParity(pts)
+		if !ok {
+			continue
+		}
+		e.Expiry, e.ExpiryMs = labels[exp], exp
+		e.T = YearFrac(exp, nowMs)
+		if e.T > 0 {
+			e.RateUSD = -math.Log(e.DF) / e.T
+			e.Basis = math.Log(e.Forward/S) / e.T
+			e.RateBTC = e.RateUSD - e.Basis
+		}
+		ts.Expiries = append(ts.Expiries, e)
+	}
+	sort.Slice(ts.Expiries, func(i, j int) bool { return ts.Expiries[i].ExpiryMs < ts.Expiries[j].ExpiryMs })
+	curve.Store(ts)
+	return ts
+}
+
+// fitParity regresses y = DF*F - DF*K by weighted least squares. ok is false
+// for a degenerate strike set or an implausible discount factor.
+func fitParity(pts []parity) (ExpiryRate, bool) {
+	var sw, sk, sy, skk, sky float64
+	for _, p := range pts {
+		sw += p.wt
+		sk += p.wt * p.k
+		sy += p.wt * p.y
+		skk += p.wt * p.k * p.k
+		sky += p.wt * p.k * p.y
+	}
+	den := sw*skk - sk*sk
+	if den <= 0 || math.Abs(den) < 1e-12*sw*skk {
+		return ExpiryRate{}, false
+	}
+	slope := (sw*sky - sk*sy) / den
+	icpt := (sy - slope*sk) / sw
+	df := -slope
+	if df <= 0.5 || df > 1.5 {
+		return ExpiryRate{}, false
+	}
+	var ss float64
+	for _, p := range pts {
+		r := p.y - (icpt + slope*p.k)
+		ss += r * r
+	}
+	return ExpiryRate{
+		Forward: icpt / df,
+		DF:      df,
+		Strikes: len(pts),
+		RMSE:    math.Sqrt(ss / float64(len(pts))),
+	}, true
+}
@@ -0,0 +1,149 @@
+package pricing
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"Options_Hedger/internal/data"
+)
+
+// Config for the greeks worker.
+//
+// Env:
+//
+//	HEDGE_GREEKS_MS=1000   (recompute cadence, 0 disables)
+//	HEDGE_GREEKS_RATE=0    (continuously compounded USD rate)
+type Config struct {
+	Interval time.Duration
+	Rate     float64
+}
+
+// ConfigFromEnv returns the worker config, ok=false when HEDGE_GREEKS_MS=0.
+func ConfigFromEnv() (Config, bool) {
+	c := Config{Interval: time.Second}
+	if v, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv("HEDGE_GREEKS_MS")), 64); err == nil && v >= 0 {
+		c.Interval = time.Duration(v * float64(time.Millisecond))
+	}
+	if v, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv("HEDGE_GREEKS_RATE")), 64); err == nil {
+		c.Rate = v
+	}
+	return c, c.Interval > 0
+}
+
+// slotState remembers the inputs of the last computation per symbol slot.
+type slotState struct {
+	name    string
+	seq     uint64
+	forward float64
+}
+
+// Worker recomputes implied vols and greeks for every subscribed option on
+// its own goroutine and publishes them with data.WriteGreeksFast. Options are
+// priced on their expiry's parity forward (Curve), or the index before the
+// curve has one. A slot is skipped while neither its book nor the forward
+// moved.
+type Worker struct {
+	cfg   Config
+	slots []slotState
+}
+
+// NewWorker returns a worker; Run starts it.
+func NewWorker(cfg Config) *Worker {
+	return &Worker{cfg: cfg}
+}
+
+// Run recomputes every Interval until ctx is done.
+func (w *Worker) Run(ctx context.Context) {
+	log.Printf("[GREEKS] recomputing every %s (rate %.4f)", w.cfg.Interval, w.cfg.Rate)
+	t := time.NewTicker(w.cfg.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			w.Pass(time.Now())
+		}
+	}
+}
+
+// Pass recomputes changed slots once and returns how many were written. A
+// book that gives no vol is written as an entry without vols or greeks, so
+// readers don't keep using the previous ones.
+func (w *Worker) Pass(now time.Time) int {
+	S := data.GetIndexPrice()
+	if S <= 0 {
+		return 0
+	}
+	curve := Curve()
+	n := int(data.GetSymbolCount())
+	if len(w.slots) < n {
+		w.slots = append(w.slots, make([]slotState, n-len(w.slots))...)
+	}
+	nowMs := now.UnixMilli()
+	written := 0
+	for i := 0; i < n; i++ {
+		meta, ok := data.InstrumentAt(int32(i))
+		if !ok || meta.OptionType == data.NotOption || meta.Strike <= 0 || meta.ExpiryMs <= nowMs {
+			continue
+		}
+		F := S
+		if e, ok := curve.Expiry(meta.ExpiryMs); ok && e.Forward > 0 {
+			F = e.Forward
+		}
+		d := data.ReadDepthFast(i)
+		st := &w.slots[i]
+		if st.name == meta.Name && st.seq == d.Seq && st.forward == F {
+			continue
+		}
+		*st = slotState{name: meta.Name, seq: d.Seq, forward: F}
+
+		g, ok := Compute(meta, d, F, YearFrac(meta.ExpiryMs, nowMs), w.cfg.Rate)
+		if !ok {
+			g = data.Greeks{Forward: F}
+		}
+		g.TsMs = nowMs
+		data.WriteGreeksFast(i, g)
+		written++
+	}
+	return written
+}
+
+// Compute solves bid/ask/mid implied vols of one option book and its greeks
+// at the mid vol (or the only quoted side). ok is false when no side gives a
+// vol.
+func Compute(meta data.InstrumentMeta, d data.DepthEntry, F, T, r float64) (data.Greeks, bool) {
+	call := meta.IsCall()
+	K := meta.Strike
+	g := data.Greeks{Forward: F}
+	if d.BidPrice > 0 {
+		g.BidIV, _ = ImpliedVol(call, d.BidPrice, F, K, T, r)
+	}
+	if d.AskPrice > 0 {
+		g.AskIV, _ = ImpliedVol(call, d.AskPrice, F, K, T, r)
+	}
+	if d.BidPrice > 0 && d.AskPrice > 0 {
+		g.MarkIV, _ = ImpliedVol(call, 0.5*(d.BidPrice+d.AskPrice), F, K, T, r)
+	}
+
+	vol := g.MarkIV
+	switch {
+	case vol > 0:
+	case g.BidIV > 0 && g.AskIV > 0:
+		vol = 0.5 * (g.BidIV + g.AskIV)
+	case g.BidIV > 0:
+		vol = g.BidIV
+	case g.AskIV > 0:
+		vol = g.AskIV
+	default:
+		return data.Greeks{}, false
+	}
+	s := Greeks(call, F, K, T, r, vol)
+	g.Delta, g.DeltaInv, g.Gamma = s.Delta, s.DeltaInv, s.Gamma
+	g.Theta, g.Vega, g.Rho = s.Theta, s.Vega, s.Rho
+	return g, true
+}
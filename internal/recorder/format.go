@@ -0,0 +1,218 @@
+// Package recorder writes market data to an append-only binary log and reads
+// it back (backtests, signal forensics).
+//
+// File layout (little endian):
+//
+//	header: "HMDR" | u16 version | i64 start (unix ns) | u32 n | n × (u16 len | name)
+//	record: u32 len | u8 kind | i64 time (unix ns) | payload   (len covers kind..payload)
+//
+//	kind 1 update: i32 idx | u8 side | f64 price | f64 qty | f64 index [| f64 ask | f64 ask qty]
+//	               (side 0 ask, 1 bid, 2 both: price/qty is the bid; version 2)
+//	kind 2 index:  f64 price
+//	kind 3 symbol: i32 idx | u16 len | name   ("" = slot freed)
+//	kind 4 raw:    FIX market data message (35=W/X)
+//
+// The header holds the symbol table by slot index at the time the file was
+// opened; symbol records track runtime changes.
+package recorder
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"Options_Hedger/internal/data"
+)
+
+const (
+	magic   = "HMDR"
+	version = 2 // 1: one-sided updates only, still readable
+
+	maxSlots = 1 << 16 // symbol slots a sane file can reference
+)
+
+// Kind is the type of a record.
+type Kind uint8
+
+const (
+	KindUpdate Kind = 1
+	KindIndex  Kind = 2
+	KindSymbol Kind = 3
+	KindRaw    Kind = 4
+)
+
+// Record is one decoded log entry.
+type Record struct {
+	Kind   Kind
+	Time   int64       // unix ns
+	Update data.Update // KindUpdate (UpdateTime = Time)
+	Index  float64     // KindIndex
+	Idx    int32       // KindSymbol
+	Symbol string      // KindSymbol
+	Raw    []byte      // KindRaw, valid until the next call to Next
+}
+
+// ErrFormat is returned for files that are not recorder logs.
+var ErrFormat = errors.New("recorder: bad file format")
+
+// Reader decodes a recorder log.
+type Reader struct {
+	r       *bufio.Reader
+	c       io.Closer
+	Start   int64    // unix ns the file was opened
+	Symbols []string // symbol table by slot, updated as symbol records are read
+	buf     []byte
+}
+
+// Open opens a log file for reading.
+func Open(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	rd, err := NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	rd.c = f
+	return rd, nil
+}
+
+// NewReader reads the header from r.
+func NewReader(r io.Reader) (*Reader, error) {
+	rd := &Reader{r: bufio.NewReaderSize(r, 1<<20)}
+	var hdr [4 + 2 + 8 + 4]byte
+	if _, err := io.ReadFull(rd.r, hdr[:]); err != nil {
+		return nil, err
+	}
+	if v := binary.LittleEndian.Uint16(hdr[4:]); string(hdr[:4]) != magic || v < 1 || v > version {
+		return nil, ErrFormat
+	}
+	rd.Start = int64(binary.LittleEndian.Uint64(hdr[6:]))
+	n := binary.LittleEndian.Uint32(hdr[14:])
+	if n > maxSlots {
+		return nil, ErrFormat
+	}
+	rd.Symbols = make([]string, n)
+	for i := range rd.Symbols {
+		var l [2]byte
+		if _, err := io.ReadFull(rd.r, l[:]); err != nil {
+			return nil, err
+		}
+		name := make([]byte, binary.LittleEndian.Uint16(l[:]))
+		if _, err := io.ReadFull(rd.r, name); err != nil {
+			return nil, err
+		}
+		rd.Symbols[i] = string(name)
+	}
+	return rd, nil
+}
+
+// Next returns the next record, io.EOF at the end of the file and
+// io.ErrUnexpectedEOF for a truncated last record (writer crashed).
+func (rd *Reader) Next() (Record, error) {
+	var l [4]byte
+	if _, err := io.ReadFull(rd.r, l[:]); err != nil {
+		return Record{}, err
+	}
+	n := int(binary.LittleEndian.Uint32(l[:]))
+	if n < 9 {
+		return Record{}, ErrFormat
+	}
+	if cap(rd.buf) < n {
+		rd.buf = make([]byte, n)
+	}
+	b := rd.buf[:n]
+	if _, err := io.ReadFull(rd.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Record{}, err
+	}
+	rec := Record{Kind: Kind(b[0]), Time: int64(binary.LittleEndian.Uint64(b[1:]))}
+	p := b[9:]
+	switch rec.Kind {
+	case KindUpdate:
+		if len(p) < 29 {
+			return rec, ErrFormat
+		}
+		rec.Update = data.Update{
+			SymbolIdx:  int32(binary.LittleEndian.Uint32(p)),
+			IsBid:      p[4] >= 1,
+			TwoSided:   p[4] == 2,
+			Price:      f64(p[5:]),
+			Qty:        f64(p[13:]),
+			IndexPrice: f64(p[21:]),
+			UpdateTime: rec.Time,
+		}
+		if rec.Update.SymbolIdx < 0 || rec.Update.SymbolIdx >= maxSlots {
+			return rec, ErrFormat
+		}
+		if rec.Update.TwoSided {
+			if len(p) < 45 {
+				return rec, ErrFormat
+			}
+			rec.Update.AskPrice, rec.Update.AskQty = f64(p[29:]), f64(p[37:])
+		}
+	case KindIndex:
+		if len(p) < 8 {
+			return rec, ErrFormat
+		}
+		rec.Index = f64(p)
+	case KindSymbol:
+		if len(p) < 6 {
+			return rec, ErrFormat
+		}
+		rec.Idx = int32(binary.LittleEndian.Uint32(p))
+		end := 6 + int(binary.LittleEndian.Uint16(p[4:]))
+		if rec.Idx < 0 || rec.Idx >= maxSlots || end > len(p) {
+			return rec, ErrFormat
+		}
+		rec.Symbol = string(p[6:end])
+		for int(rec.Idx) >= len(rd.Symbols) {
+			rd.Symbols = append(rd.Symbols, "")
+		}
+		rd.Symbols[rec.Idx] = rec.Symbol
+	case KindRaw:
+		rec.Raw = p
+	}
+	return rec, nil
+}
+
+// Close closes the underlying file (if opened with Open).
+func (rd *Reader) Close() error {
+	if rd.c != nil {
+		return rd.c.Close()
+	}
+	return nil
+}
+
+func f64(b []byte) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b)) }
+
+// appendHeader encodes a file header.
+func appendHeader(b []byte, start int64, symbols []string) []byte {
+	b = append(b, magic...)
+	b = binary.LittleEndian.AppendUint16(b, version)
+	b = binary.LittleEndian.AppendUint64(b, uint64(start))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(symbols)))
+	for _, s := range symbols {
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(s)))
+		b = append(b, s...)
+	}
+	return b
+}
+
+// appendRecord encodes one record; payload is appended by fill.
+func appendRecord(b []byte, kind Kind, t int64, fill func([]byte) []byte) []byte {
+	at := len(b)
+	b = append(b, 0, 0, 0, 0, byte(kind))
+	b = binary.LittleEndian.AppendUint64(b, uint64(t))
+	b = fill(b)
+	binary.LittleEndian.PutUint32(b[at:], uint32(len(b)-at-4))
+	return b
+}
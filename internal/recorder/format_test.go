@@ -0,0 +1,144 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"Options_Hedger/internal/data"
+)
+
+func TestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	r := New(Config{Dir: dir, Buffer: 64})
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	base := data.Nanotime()
+	bid := data.Update{SymbolIdx: 3, IsBid: true, Price: 0.0125, Qty: 4, IndexPrice: 65000, UpdateTime: base + 1}
+	ask := data.Update{SymbolIdx: 3, Price: 0.013, Qty: 2.5, UpdateTime: base + 2}
+	both := data.Update{SymbolIdx: 7, IsBid: true, TwoSided: true, Price: 0.02, Qty: 1, AskPrice: 0.021, AskQty: 3, UpdateTime: base + 3}
+	r.OnUpdate(bid)
+	r.OnUpdate(ask)
+	r.OnUpdate(both)
+	r.OnIndex(65001.5, base+4)
+	r.OnSymbol(9, "BTC-27SEP25-60000-C")
+	r.OnSymbol(3, "")
+	r.OnRawMD([]byte("35=X\x01"), base+5)
+	r.Stop()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "md-*.bin"))
+	if len(files) != 1 {
+		t.Fatalf("files = %v, want one", files)
+	}
+	rd, err := Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	var got []Record
+	for {
+		rec, err := rd.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Raw != nil {
+			rec.Raw = append([]byte(nil), rec.Raw...)
+		}
+		got = append(got, rec)
+	}
+	if len(got) != 7 {
+		t.Fatalf("read %d records, want 7", len(got))
+	}
+	for i, want := range []data.Update{bid, ask, both} {
+		u := got[i].Update
+		u.UpdateTime, want.UpdateTime = 0, 0
+		if got[i].Kind != KindUpdate || !reflect.DeepEqual(u, want) {
+			t.Errorf("update %d = %+v, want %+v", i, u, want)
+		}
+	}
+	if got[1].Time-got[0].Time != 1 {
+		t.Errorf("update times %d, %d: want 1ns apart", got[0].Time, got[1].Time)
+	}
+	if got[3].Kind != KindIndex || got[3].Index != 65001.5 {
+		t.Errorf("index record = %+v", got[3])
+	}
+	if got[4].Idx != 9 || got[4].Symbol != "BTC-27SEP25-60000-C" || got[5].Idx != 3 || got[5].Symbol != "" {
+		t.Errorf("symbol records = %+v, %+v", got[4], got[5])
+	}
+	if len(rd.Symbols) != 10 || rd.Symbols[9] != "BTC-27SEP25-60000-C" {
+		t.Errorf("symbol table = %q", rd.Symbols)
+	}
+	if got[6].Kind != KindRaw || string(got[6].Raw) != "35=X\x01" {
+		t.Errorf("raw record = %+v", got[6])
+	}
+}
+
+func TestCorruptRecords(t *testing.T) {
+	f64 := func(b []byte, v float64) []byte { return binary.LittleEndian.AppendUint64(b, math.Float64bits(v)) }
+	update := func(idx uint32, side byte, extra int) func([]byte) []byte {
+		return func(b []byte) []byte {
+			b = binary.LittleEndian.AppendUint32(b, idx)
+			b = append(b, side)
+			b = f64(f64(f64(b, 0.01), 1), 0)
+			for i := 0; i < extra; i++ {
+				b = f64(b, 0.02)
+			}
+			return b
+		}
+	}
+	symbol := func(idx uint32, n uint16, name string) func([]byte) []byte {
+		return func(b []byte) []byte {
+			b = binary.LittleEndian.AppendUint32(b, idx)
+			b = binary.LittleEndian.AppendUint16(b, n)
+			return append(b, name...)
+		}
+	}
+	tests := []struct {
+		name string
+		kind Kind
+		fill func([]byte) []byte
+	}{
+		{"short update", KindUpdate, func(b []byte) []byte { return append(b, 1, 2, 3) }},
+		{"negative update slot", KindUpdate, update(math.MaxUint32, 1, 0)},
+		{"two-sided update without the ask", KindUpdate, update(1, 2, 1)},
+		{"short index", KindIndex, func(b []byte) []byte { return append(b, 1) }},
+		{"symbol name past the record", KindSymbol, symbol(1, 40, "BTC-X")},
+		{"negative symbol slot", KindSymbol, symbol(math.MaxUint32, 5, "BTC-X")},
+		{"symbol slot out of range", KindSymbol, symbol(maxSlots, 5, "BTC-X")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := appendHeader(nil, 0, []string{"BTC-A"})
+			b = appendRecord(b, tt.kind, 1, tt.fill)
+			rd, err := NewReader(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := rd.Next(); !errors.Is(err, ErrFormat) {
+				t.Fatalf("Next() = %v, want ErrFormat", err)
+			}
+		})
+	}
+}
+
+func TestReadsVersion1(t *testing.T) {
+	b := appendHeader(nil, 0, nil)
+	binary.LittleEndian.PutUint16(b[4:], 1)
+	if _, err := NewReader(bytes.NewReader(b)); err != nil {
+		t.Fatalf("version 1 header: %v", err)
+	}
+	binary.LittleEndian.PutUint16(b[4:], version+1)
+	if _, err := NewReader(bytes.NewReader(b)); !errors.Is(err, ErrFormat) {
+		t.Fatalf("future version: %v, want ErrFormat", err)
+	}
+}
@@ -0,0 +1,288 @@
+package recorder
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"Options_Hedger/internal/data"
+	"Options_Hedger/internal/fix"
+)
+
+// Config for the recorder.
+//
+// Env:
+//
+//	HEDGE_REC=0              (1 enables)
+//	HEDGE_REC_DIR=recordings (one file per UTC day and start: md-YYYYMMDD-HHMMSS.bin)
+//	HEDGE_REC_RAW=0          (1 also records raw FIX W/X messages)
+//	HEDGE_REC_BUFFER=65536   (events queued for the writer before dropping)
+type Config struct {
+	Dir    string
+	Raw    bool
+	Buffer int
+}
+
+// ConfigFromEnv returns the recorder config, ok=false when HEDGE_REC is off.
+func ConfigFromEnv() (Config, bool) {
+	c := Config{
+		Dir:    strings.TrimSpace(os.Getenv("HEDGE_REC_DIR")),
+		Raw:    strings.TrimSpace(os.Getenv("HEDGE_REC_RAW")) == "1",
+		Buffer: 65536,
+	}
+	if c.Dir == "" {
+		c.Dir = "recordings"
+	}
+	if v, err := strconv.Atoi(strings.TrimSpace(os.Getenv("HEDGE_REC_BUFFER"))); err == nil && v > 0 {
+		c.Buffer = v
+	}
+	return c, strings.TrimSpace(os.Getenv("HEDGE_REC")) == "1"
+}
+
+// event is one queued item; ns is data.Nanotime.
+type event struct {
+	kind  Kind
+	ns    int64
+	u     data.Update
+	index float64
+	idx   int32
+	name  string
+	raw   []byte
+}
+
+// Stats are the recorder counters.
+type Stats struct {
+	File    string `json:"file"`
+	Records uint64 `json:"records"`
+	Bytes   uint64 `json:"bytes"`
+	Dropped uint64 `json:"dropped"` // queue full: the writer fell behind
+}
+
+// Recorder implements data.MDTap and fix.RawMDTap. The taps only enqueue;
+// encoding and file I/O run on the recorder's own goroutine.
+type Recorder struct {
+	cfg  Config
+	ch   chan event
+	stop chan struct{}
+	done chan struct{}
+
+	records, bytes, dropped atomic.Uint64
+	file                    atomic.Pointer[string]
+
+	// writer goroutine only
+	f        *os.File
+	w        *bufio.Writer
+	day      int64
+	symbols  []string
+	wallBase int64
+	monoBase int64
+	buf      []byte
+}
+
+// New returns a recorder; Start begins recording.
+func New(cfg Config) *Recorder {
+	return &Recorder{
+		cfg:  cfg,
+		ch:   make(chan event, cfg.Buffer),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Start opens the first file with the current symbol table and installs the
+// taps. Call after the order books are initialised.
+func (r *Recorder) Start() error {
+	r.wallBase, r.monoBase = time.Now().UnixNano(), data.Nanotime()
+	n := data.GetSymbolCount()
+	r.symbols = make([]string, n)
+	for i := range r.symbols {
+		r.symbols[i] = data.GetSymbolName(int32(i))
+	}
+	if err := os.MkdirAll(r.cfg.Dir, 0o755); err != nil {
+		return err
+	}
+	if err := r.open(r.wallBase); err != nil {
+		return err
+	}
+	data.SetMDTap(r)
+	if r.cfg.Raw {
+		fix.SetRawMDTap(r)
+	}
+	go r.run()
+	log.Printf("[REC] recording market data to %s (raw=%t)", r.cfg.Dir, r.cfg.Raw)
+	return nil
+}
+
+// Stop removes the taps, writes what is queued and closes the file.
+func (r *Recorder) Stop() {
+	data.SetMDTap(nil)
+	fix.SetRawMDTap(nil)
+	close(r.stop)
+	<-r.done
+	s := r.Stats()
+	log.Printf("[REC] stopped: %d records, %d bytes, %d dropped", s.Records, s.Bytes, s.Dropped)
+}
+
+// Stats returns the current counters.
+func (r *Recorder) Stats() Stats {
+	s := Stats{Records: r.records.Load(), Bytes: r.bytes.Load(), Dropped: r.dropped.Load()}
+	if f := r.file.Load(); f != nil {
+		s.File = *f
+	}
+	return s
+}
+
+// OnUpdate implements data.MDTap.
+func (r *Recorder) OnUpdate(u data.Update) { r.push(event{kind: KindUpdate, ns: u.UpdateTime, u: u}) }
+
+// OnIndex implements data.MDTap.
+func (r *Recorder) OnIndex(price float64, ns int64) {
+	r.push(event{kind: KindIndex, ns: ns, index: price})
+}
+
+// OnSymbol implements data.MDTap.
+func (r *Recorder) OnSymbol(idx int32, name string) {
+	r.push(event{kind: KindSymbol, ns: data.Nanotime(), idx: idx, name: name})
+}
+
+// OnRawMD implements fix.RawMDTap.
+func (r *Recorder) OnRawMD(raw []byte, ns int64) {
+	r.push(event{kind: KindRaw, ns: ns, raw: append([]byte(nil), raw...)})
+}
+
+func (r *Recorder) push(ev event) {
+	select {
+	case r.ch <- ev:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+	flush := time.NewTicker(time.Second)
+	defer flush.Stop()
+	for {
+		select {
+		case ev := <-r.ch:
+			r.write(ev)
+		case <-flush.C:
+			r.flush()
+		case <-r.stop:
+			for len(r.ch) > 0 {
+				r.write(<-r.ch)
+			}
+			r.close()
+			return
+		}
+	}
+}
+
+func (r *Recorder) write(ev event) {
+	if r.w == nil {
+		return
+	}
+	t := r.wallBase + (ev.ns - r.monoBase)
+	if day := t / int64(24*time.Hour); day != r.day {
+		r.close()
+		if err := r.open(t); err != nil {
+			log.Printf("[REC] rotate failed: %v", err)
+			return
+		}
+	}
+
+	b := r.buf[:0]
+	switch ev.kind {
+	case KindUpdate:
+		u := ev.u
+		b = appendRecord(b, KindUpdate, t, func(b []byte) []byte {
+			b = binary.LittleEndian.AppendUint32(b, uint32(u.SymbolIdx))
+			side := byte(0)
+			switch {
+			case u.TwoSided:
+				side = 2
+			case u.IsBid:
+				side = 1
+			}
+			b = append(b, side)
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(u.Price))
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(u.Qty))
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(u.IndexPrice))
+			if u.TwoSided {
+				b = binary.LittleEndian.AppendUint64(b, math.Float64bits(u.AskPrice))
+				b = binary.LittleEndian.AppendUint64(b, math.Float64bits(u.AskQty))
+			}
+			return b
+		})
+	case KindIndex:
+		b = appendRecord(b, KindIndex, t, func(b []byte) []byte {
+			return binary.LittleEndian.AppendUint64(b, math.Float64bits(ev.index))
+		})
+	case KindSymbol:
+		for int(ev.idx) >= len(r.symbols) {
+			r.symbols = append(r.symbols, "")
+		}
+		r.symbols[ev.idx] = ev.name
+		b = appendRecord(b, KindSymbol, t, func(b []byte) []byte {
+			b = binary.LittleEndian.AppendUint32(b, uint32(ev.idx))
+			b = binary.LittleEndian.AppendUint16(b, uint16(len(ev.name)))
+			return append(b, ev.name...)
+		})
+	case KindRaw:
+		b = appendRecord(b, KindRaw, t, func(b []byte) []byte { return append(b, ev.raw...) })
+	}
+	r.buf = b
+	if _, err := r.w.Write(b); err != nil {
+		log.Printf("[REC] write failed, recording stopped: %v", err)
+		r.close()
+		return
+	}
+	r.records.Add(1)
+	r.bytes.Add(uint64(len(b)))
+}
+
+// open starts a new file for the UTC day of t (unix ns).
+func (r *Recorder) open(t int64) error {
+	ts := time.Unix(0, t).UTC()
+	path := filepath.Join(r.cfg.Dir, fmt.Sprintf("md-%s.bin", ts.Format("20060102-150405")))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriterSize(f, 1<<20)
+	if _, err := w.Write(appendHeader(nil, t, r.symbols)); err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.w, r.day = f, w, t/int64(24*time.Hour)
+	r.file.Store(&path)
+	log.Printf("[REC] writing %s", path)
+	return nil
+}
+
+func (r *Recorder) flush() {
+	if r.w != nil {
+		if err := r.w.Flush(); err != nil {
+			log.Printf("[REC] flush failed: %v", err)
+		}
+	}
+}
+
+func (r *Recorder) close() {
+	if r.f == nil {
+		return
+	}
+	r.flush()
+	if err := r.f.Close(); err != nil {
+		log.Printf("[REC] close failed: %v", err)
+	}
+	r.f, r.w = nil, nil
+}
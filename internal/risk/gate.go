@@ -0,0 +1,362 @@
+// Package risk checks orders before they are sent.
+package risk
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"Options_Hedger/internal/data"
+	"Options_Hedger/internal/fix"
+	"Options_Hedger/internal/positions"
+
+	"github.com/quickfixgo/enum"
+)
+
+// Rules; every rejection wraps one of them (test with errors.Is).
+var (
+	ErrHalted        = errors.New("risk: trading halted")
+	ErrMaxQty        = errors.New("risk: order quantity above limit")
+	ErrMaxNotional   = errors.New("risk: order notional above limit")
+	ErrBoxNotional   = errors.New("risk: box notional above limit")
+	ErrPriceCollar   = errors.New("risk: price outside collar")
+	ErrNoReference   = errors.New("risk: no reference price")
+	ErrMaxOpenOrders = errors.New("risk: too many open orders")
+	ErrRateLimit     = errors.New("risk: order rate above limit")
+	ErrExpiryLimit   = errors.New("risk: expiry position limit")
+	ErrStrikeLimit   = errors.New("risk: strike position limit")
+)
+
+// Violation is the error returned for a rejected order.
+type Violation struct {
+	Rule   error
+	Detail string
+}
+
+func (v *Violation) Error() string { return v.Rule.Error() + ": " + v.Detail }
+func (v *Violation) Unwrap() error { return v.Rule }
+
+func violation(rule error, format string, args ...any) error {
+	return &Violation{Rule: rule, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Limits for the gate; zero disables a rule.
+//
+// Env:
+//
+//	HEDGE_RISK_MAX_ORDER_QTY=10       (contracts per order)
+//	HEDGE_RISK_MAX_ORDER_USD=0        (premium qty*price*index per order; combos: gross leg mids)
+//	HEDGE_RISK_MAX_BOX_USD=0          (box face value (K_high-K_low)*qty)
+//	HEDGE_RISK_COLLAR_PCT=0.25        (limit price vs book mid; combos: net leg mids ± pct of gross)
+//	HEDGE_RISK_COLLAR_MIN=0.001       (minimum collar width, BTC)
+//	HEDGE_RISK_MAX_OPEN_ORDERS=50
+//	HEDGE_RISK_MAX_OPS=20             (orders per second)
+//	HEDGE_RISK_MAX_EXPIRY_QTY=0       (gross contracts per expiry)
+//	HEDGE_RISK_MAX_STRIKE_QTY=0       (gross contracts per expiry+strike, calls and puts)
+type Limits struct {
+	MaxOrderQty   float64
+	MaxOrderUSD   float64
+	MaxBoxUSD     float64
+	CollarPct     float64
+	CollarMin     float64
+	MaxOpenOrders int
+	MaxOrdersPerS int
+	MaxExpiryQty  float64
+	MaxStrikeQty  float64
+}
+
+// LimitsFromEnv reads HEDGE_RISK_* settings.
+func LimitsFromEnv() Limits {
+	return Limits{
+		MaxOrderQty:   envFloat("HEDGE_RISK_MAX_ORDER_QTY", 10),
+		MaxOrderUSD:   envFloat("HEDGE_RISK_MAX_ORDER_USD", 0),
+		MaxBoxUSD:     envFloat("HEDGE_RISK_MAX_BOX_USD", 0),
+		CollarPct:     envFloat("HEDGE_RISK_COLLAR_PCT", 0.25),
+		CollarMin:     envFloat("HEDGE_RISK_COLLAR_MIN", 0.001),
+		MaxOpenOrders: int(envFloat("HEDGE_RISK_MAX_OPEN_ORDERS", 50)),
+		MaxOrdersPerS: int(envFloat("HEDGE_RISK_MAX_OPS", 20)),
+		MaxExpiryQty:  envFloat("HEDGE_RISK_MAX_EXPIRY_QTY", 0),
+		MaxStrikeQty:  envFloat("HEDGE_RISK_MAX_STRIKE_QTY", 0),
+	}
+}
+
+// Gate implements fix.PreTradeCheck.
+type Gate struct {
+	lim    Limits
+	halted atomic.Pointer[string] // reason, nil while trading
+
+	rmu   sync.Mutex
+	sends []int64 // ring of recent send times (ns)
+	head  int
+}
+
+// NewGate returns a gate enforcing lim.
+func NewGate(lim Limits) *Gate {
+	g := &Gate{lim: lim}
+	if lim.MaxOrdersPerS > 0 {
+		g.sends = make([]int64, lim.MaxOrdersPerS)
+	}
+	return g
+}
+
+var active atomic.Pointer[Gate]
+
+// Install makes g the gate for every outgoing FIX order.
+func Install(g *Gate) {
+	active.Store(g)
+	fix.SetPreTradeCheck(g)
+	log.Printf("[RISK] pre-trade gate installed: %+v", g.lim)
+}
+
+// Active returns the installed gate (nil if none).
+func Active() *Gate { return active.Load() }
+
+// Halt blocks every new order until Resume.
+func (g *Gate) Halt(reason string) {
+	g.halted.Store(&reason)
+}
+
+// Resume re-enables trading.
+func (g *Gate) Resume() { g.halted.Store(nil) }
+
+// Halted returns the halt reason, if any.
+func (g *Gate) Halted() (string, bool) {
+	if r := g.halted.Load(); r != nil {
+		return *r, true
+	}
+	return "", false
+}
+
+// CheckOrder implements fix.PreTradeCheck. Orders flagged Reduce that do
+// shrink the current position always pass, even while halted.
+func (g *Gate) CheckOrder(c fix.OrderCheck) error {
+	if c.Reduce && len(c.Legs) == 0 && reduces(c.Symbol, c.Side, c.Qty) {
+		return nil
+	}
+	if r, ok := g.Halted(); ok {
+		return violation(ErrHalted, "%s", r)
+	}
+	lim := g.lim
+	if lim.MaxOrderQty > 0 && c.Qty > lim.MaxOrderQty {
+		return violation(ErrMaxQty, "%g > %g", c.Qty, lim.MaxOrderQty)
+	}
+	if lim.MaxOrderUSD > 0 {
+		px := math.Abs(c.Price)
+		if len(c.Legs) > 0 {
+			// the net combo price understates what the legs put at risk
+			_, gross, err := comboMids(c.Legs)
+			if err != nil {
+				return err
+			}
+			px = gross
+		}
+		if usd := c.Qty * px * data.GetIndexPrice(); usd > lim.MaxOrderUSD {
+			return violation(ErrMaxNotional, "$%.0f > $%.0f", usd, lim.MaxOrderUSD)
+		}
+	}
+	if !c.Replace && lim.MaxOpenOrders > 0 && c.OpenOrders >= lim.MaxOpenOrders {
+		return violation(ErrMaxOpenOrders, "%d open", c.OpenOrders)
+	}
+	if len(c.Legs) == 0 {
+		if err := g.checkCollar(c); err != nil {
+			return err
+		}
+		if err := g.checkPosition(c.Symbol, c.Side, c.Qty); err != nil {
+			return err
+		}
+	} else {
+		if err := g.checkComboCollar(c); err != nil {
+			return err
+		}
+		for _, l := range c.Legs {
+			side := l.Side
+			if c.Side == enum.Side_SELL {
+				side = flip(side)
+			}
+			if err := g.checkPosition(l.Symbol, side, c.Qty*math.Max(l.Ratio, 1)); err != nil {
+				return err
+			}
+		}
+	}
+	// Rate last: only orders that pass everything else use up the budget
+	return g.takeRate()
+}
+
+// CheckBox validates a whole box before its legs are sent.
+func (g *Gate) CheckBox(lowStrike, highStrike, qty float64) error {
+	if r, ok := g.Halted(); ok {
+		return violation(ErrHalted, "%s", r)
+	}
+	if g.lim.MaxBoxUSD > 0 {
+		if usd := (highStrike - lowStrike) * qty; usd > g.lim.MaxBoxUSD {
+			return violation(ErrBoxNotional, "$%.0f > $%.0f", usd, g.lim.MaxBoxUSD)
+		}
+	}
+	return nil
+}
+
+// CheckBox runs the installed gate's box check (nil without a gate).
+func CheckBox(lowStrike, highStrike, qty float64) error {
+	if g := active.Load(); g != nil {
+		return g.CheckBox(lowStrike, highStrike, qty)
+	}
+	return nil
+}
+
+// checkCollar keeps the limit price within the collar around the book mid.
+func (g *Gate) checkCollar(c fix.OrderCheck) error {
+	if g.lim.CollarPct <= 0 {
+		return nil
+	}
+	mid, err := bookMid(c.Symbol)
+	if err != nil {
+		return err
+	}
+	width := math.Max(mid*g.lim.CollarPct, g.lim.CollarMin)
+	if (c.Side == enum.Side_BUY && c.Price > mid+width) || (c.Side == enum.Side_SELL && c.Price < mid-width) {
+		return violation(ErrPriceCollar, "%s %s @%g vs mid %g ±%g", sideName(c.Side), c.Symbol, c.Price, mid, width)
+	}
+	return nil
+}
+
+// checkComboCollar keeps a combo's net price within the collar around the
+// net of its leg mids; the width scales with the gross leg premium.
+func (g *Gate) checkComboCollar(c fix.OrderCheck) error {
+	if g.lim.CollarPct <= 0 {
+		return nil
+	}
+	net, gross, err := comboMids(c.Legs)
+	if err != nil {
+		return err
+	}
+	width := math.Max(gross*g.lim.CollarPct, g.lim.CollarMin)
+	if (c.Side == enum.Side_BUY && c.Price > net+width) || (c.Side == enum.Side_SELL && c.Price < net-width) {
+		return violation(ErrPriceCollar, "%s combo of %d legs @%g vs net mid %g ±%g", sideName(c.Side), len(c.Legs), c.Price, net, width)
+	}
+	return nil
+}
+
+// bookMid returns the mid of symbol's two-sided book.
+func bookMid(symbol string) (float64, error) {
+	idx := data.LookupSymbol(symbol)
+	if idx < 0 {
+		return 0, violation(ErrNoReference, "%s not subscribed", symbol)
+	}
+	d := data.ReadDepthFast(int(idx))
+	if d.BidPrice <= 0 || d.AskPrice <= 0 {
+		return 0, violation(ErrNoReference, "%s has no two-sided quote", symbol)
+	}
+	return (d.BidPrice + d.AskPrice) / 2, nil
+}
+
+// comboMids returns the net price of one combo unit at leg mids (legs as
+// for a BUY of the combo) and its gross leg premium.
+func comboMids(legs []fix.MultilegLeg) (net, gross float64, err error) {
+	for _, l := range legs {
+		mid, err := bookMid(l.Symbol)
+		if err != nil {
+			return 0, 0, err
+		}
+		ratio := math.Max(l.Ratio, 1)
+		if l.Side == enum.Side_BUY {
+			net += ratio * mid
+		} else {
+			net -= ratio * mid
+		}
+		gross += ratio * mid
+	}
+	return net, gross, nil
+}
+
+// checkPosition rejects orders that would grow gross exposure beyond the
+// per-expiry or per-strike limits assuming a full fill. Reducing orders pass.
+func (g *Gate) checkPosition(symbol string, side enum.Side, qty float64) error {
+	if g.lim.MaxExpiryQty <= 0 && g.lim.MaxStrikeQty <= 0 {
+		return nil
+	}
+	m, ok := data.ResolveInstrument(symbol)
+	if !ok {
+		return nil
+	}
+	cur := positions.Get(symbol).Qty
+	next := cur + qty
+	if side == enum.Side_SELL {
+		next = cur - qty
+	}
+	growth := math.Abs(next) - math.Abs(cur)
+	if growth <= 0 {
+		return nil
+	}
+
+	var expiryGross, strikeGross float64
+	for _, p := range positions.Filter(func(_ positions.Position, pm data.InstrumentMeta) bool {
+		return pm.ExpiryMs == m.ExpiryMs
+	}) {
+		expiryGross += math.Abs(p.Qty)
+		if pm, _ := data.ResolveInstrument(p.Symbol); pm.Strike == m.Strike {
+			strikeGross += math.Abs(p.Qty)
+		}
+	}
+	if lim := g.lim.MaxExpiryQty; lim > 0 && expiryGross+growth > lim {
+		return violation(ErrExpiryLimit, "%s %g+%g > %g", m.ExpiryLabel(), expiryGross, growth, lim)
+	}
+	if lim := g.lim.MaxStrikeQty; lim > 0 && strikeGross+growth > lim {
+		return violation(ErrStrikeLimit, "%s %.0f %g+%g > %g", m.ExpiryLabel(), m.Strike, strikeGross, growth, lim)
+	}
+	return nil
+}
+
+// reduces reports whether the order only shrinks |position| of symbol.
+func reduces(symbol string, side enum.Side, qty float64) bool {
+	cur := positions.Get(symbol).Qty
+	if side == enum.Side_BUY {
+		return cur < 0 && qty <= -cur+1e-9
+	}
+	return cur > 0 && qty <= cur+1e-9
+}
+
+// takeRate admits at most MaxOrdersPerS orders in any one-second window.
+func (g *Gate) takeRate() error {
+	if len(g.sends) == 0 {
+		return nil
+	}
+	now := time.Now().UnixNano()
+	g.rmu.Lock()
+	defer g.rmu.Unlock()
+	if oldest := g.sends[g.head]; oldest != 0 && now-oldest < int64(time.Second) {
+		return violation(ErrRateLimit, "%d orders/s", len(g.sends))
+	}
+	g.sends[g.head] = now
+	g.head = (g.head + 1) % len(g.sends)
+	return nil
+}
+
+func flip(s enum.Side) enum.Side {
+	if s == enum.Side_BUY {
+		return enum.Side_SELL
+	}
+	return enum.Side_BUY
+}
+
+func sideName(s enum.Side) string {
+	if s == enum.Side_BUY {
+		return "BUY"
+	}
+	return "SELL"
+}
+
+func envFloat(key string, def float64) float64 {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if x, err := strconv.ParseFloat(v, 64); err == nil {
+			return x
+		}
+	}
+	return def
+}
@@ -0,0 +1,178 @@
+package risk
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"Options_Hedger/internal/data"
+	"Options_Hedger/internal/fix"
+	"Options_Hedger/internal/positions"
+
+	"github.com/quickfixgo/enum"
+)
+
+const (
+	callLo  = "BTC-27SEP25-60000-C" // mid 0.011
+	putLo   = "BTC-27SEP25-60000-P" // mid 0.021
+	callHi  = "BTC-27SEP25-70000-C" // mid 0.005
+	callDec = "BTC-27DEC25-60000-C" // mid 0.055
+	oneSide = "BTC-27SEP25-80000-C" // bid only
+	unknown = "BTC-27SEP25-90000-C" // not subscribed
+)
+
+// setupBooks subscribes the test instruments with fixed quotes and an
+// index of 60000.
+func setupBooks(t *testing.T) {
+	t.Helper()
+	cp := data.Capacity()
+	t.Cleanup(func() { data.SetCapacity(cp) })
+	data.SetCapacity(8)
+	data.InitOrderBooks([]string{callLo, putLo, callHi, callDec, oneSide}, nil)
+	for i, q := range [][4]float64{{0.010, 5, 0.012, 5}, {0.020, 5, 0.022, 5}, {0.004, 5, 0.006, 5}, {0.050, 5, 0.060, 5}, {0.001, 5, 0, 0}} {
+		data.WriteDepthFast(i, q[0], q[1], q[2], q[3])
+	}
+	data.SetIndexPrice(60000)
+}
+
+// hold opens qty (negative: short) of symbol for the rest of the test.
+func hold(t *testing.T, symbol string, qty float64) {
+	t.Helper()
+	side, undo := enum.Side_BUY, enum.Side_SELL
+	if qty < 0 {
+		side, undo, qty = enum.Side_SELL, enum.Side_BUY, -qty
+	}
+	positions.ApplyFill(symbol, side, qty, 0.01)
+	t.Cleanup(func() { positions.ApplyFill(symbol, undo, qty, 0.01) })
+}
+
+func order(symbol string, side enum.Side, px, qty float64) fix.OrderCheck {
+	return fix.OrderCheck{Symbol: symbol, Side: side, Price: px, Qty: qty}
+}
+
+// spread is a call spread combo: buy callLo, sell callHi (net mid 0.006,
+// gross 0.016).
+func spread(side enum.Side, px, qty float64) fix.OrderCheck {
+	return fix.OrderCheck{Side: side, Price: px, Qty: qty, Legs: []fix.MultilegLeg{
+		{Symbol: callLo, Side: enum.Side_BUY, Ratio: 1},
+		{Symbol: callHi, Side: enum.Side_SELL, Ratio: 1},
+	}}
+}
+
+func TestGateCheckOrder(t *testing.T) {
+	setupBooks(t)
+	collar := Limits{CollarPct: 0.25, CollarMin: 0.001}
+	tests := []struct {
+		name  string
+		lim   Limits
+		halt  bool
+		held  map[string]float64
+		check fix.OrderCheck
+		want  error
+	}{
+		{"inside every limit", Limits{MaxOrderQty: 10, MaxOrderUSD: 1000, MaxOpenOrders: 5, CollarPct: 0.25, CollarMin: 0.001},
+			false, nil, order(callLo, enum.Side_BUY, 0.012, 1), nil},
+		{"halted", collar, true, nil, order(callLo, enum.Side_BUY, 0.012, 1), ErrHalted},
+		{"reduce-only passes a halt", collar, true, map[string]float64{callLo: 2},
+			fix.OrderCheck{Symbol: callLo, Side: enum.Side_SELL, Price: 0.001, Qty: 1, Reduce: true}, nil},
+		{"reduce flag on a growing order is halted", collar, true, map[string]float64{callLo: 2},
+			fix.OrderCheck{Symbol: callLo, Side: enum.Side_BUY, Price: 0.012, Qty: 1, Reduce: true}, ErrHalted},
+		{"reduce flag beyond the position is halted", collar, true, map[string]float64{callLo: 2},
+			fix.OrderCheck{Symbol: callLo, Side: enum.Side_SELL, Price: 0.010, Qty: 3, Reduce: true}, ErrHalted},
+		{"quantity", Limits{MaxOrderQty: 10}, false, nil, order(callLo, enum.Side_BUY, 0.012, 11), ErrMaxQty},
+		{"notional", Limits{MaxOrderUSD: 1000}, false, nil, order(callLo, enum.Side_BUY, 0.011, 2), ErrMaxNotional},
+		{"combo notional inside on gross", Limits{MaxOrderUSD: 1000}, false, nil, spread(enum.Side_BUY, 0.006, 1), nil},
+		{"combo notional on gross, not net", Limits{MaxOrderUSD: 1000}, false, nil, spread(enum.Side_BUY, 0.006, 2), ErrMaxNotional},
+		{"open orders", Limits{MaxOpenOrders: 5}, false, nil,
+			fix.OrderCheck{Symbol: callLo, Side: enum.Side_BUY, Price: 0.012, Qty: 1, OpenOrders: 5}, ErrMaxOpenOrders},
+		{"replace ignores open orders", Limits{MaxOpenOrders: 5}, false, nil,
+			fix.OrderCheck{Symbol: callLo, Side: enum.Side_BUY, Price: 0.012, Qty: 1, OpenOrders: 5, Replace: true}, nil},
+		{"buy inside the collar", collar, false, nil, order(callLo, enum.Side_BUY, 0.0137, 1), nil},
+		{"buy above the collar", collar, false, nil, order(callLo, enum.Side_BUY, 0.014, 1), ErrPriceCollar},
+		{"sell below the collar", collar, false, nil, order(callLo, enum.Side_SELL, 0.008, 1), ErrPriceCollar},
+		{"sell above the mid", collar, false, nil, order(callLo, enum.Side_SELL, 0.020, 1), nil},
+		{"collar minimum width", Limits{CollarPct: 0.25, CollarMin: 0.002}, false, nil, order(callHi, enum.Side_BUY, 0.0069, 1), nil},
+		{"above the collar minimum width", Limits{CollarPct: 0.25, CollarMin: 0.002}, false, nil, order(callHi, enum.Side_BUY, 0.0071, 1), ErrPriceCollar},
+		{"not subscribed", collar, false, nil, order(unknown, enum.Side_BUY, 0.01, 1), ErrNoReference},
+		{"one-sided book", collar, false, nil, order(oneSide, enum.Side_BUY, 0.001, 1), ErrNoReference},
+		{"combo inside the collar", collar, false, nil, spread(enum.Side_BUY, 0.0099, 1), nil},
+		{"combo buy above net mid + gross collar", collar, false, nil, spread(enum.Side_BUY, 0.0101, 1), ErrPriceCollar},
+		{"combo sell below net mid - gross collar", collar, false, nil, spread(enum.Side_SELL, 0.0019, 1), ErrPriceCollar},
+		{"combo leg without a quote", collar, false, nil,
+			fix.OrderCheck{Side: enum.Side_BUY, Price: 0.01, Qty: 1, Legs: []fix.MultilegLeg{{Symbol: callLo, Side: enum.Side_BUY}, {Symbol: oneSide, Side: enum.Side_SELL}}},
+			ErrNoReference},
+		{"expiry limit", Limits{MaxExpiryQty: 5}, false, map[string]float64{callLo: 3, putLo: -1},
+			order(callHi, enum.Side_BUY, 0.005, 2), ErrExpiryLimit},
+		{"expiry limit counts only its expiry", Limits{MaxExpiryQty: 5}, false, map[string]float64{callLo: 3, putLo: -1},
+			order(callDec, enum.Side_BUY, 0.055, 2), nil},
+		{"expiry limit lets a reducing order pass", Limits{MaxExpiryQty: 3}, false, map[string]float64{callLo: 3, putLo: -1},
+			order(callLo, enum.Side_SELL, 0.011, 2), nil},
+		{"expiry limit on combo legs, sell flips the legs", Limits{MaxExpiryQty: 5}, false, map[string]float64{callLo: 3, putLo: -1},
+			spread(enum.Side_SELL, 0.006, 2), ErrExpiryLimit},
+		{"strike limit counts calls and puts", Limits{MaxStrikeQty: 4}, false, map[string]float64{callLo: 3, putLo: -1},
+			order(putLo, enum.Side_SELL, 0.021, 1), ErrStrikeLimit},
+		{"strike limit counts only its strike", Limits{MaxStrikeQty: 4}, false, map[string]float64{callLo: 3, putLo: -1},
+			order(callHi, enum.Side_BUY, 0.005, 1), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for sym, q := range tt.held {
+				hold(t, sym, q)
+			}
+			g := NewGate(tt.lim)
+			if tt.halt {
+				g.Halt("test")
+			}
+			err := g.CheckOrder(tt.check)
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Fatalf("CheckOrder() = %v, want %v", err, tt.want)
+			}
+			var v *Violation
+			if err != nil && !errors.As(err, &v) {
+				t.Errorf("error %T is not a *Violation", err)
+			}
+		})
+	}
+}
+
+func TestGateRateLimit(t *testing.T) {
+	setupBooks(t)
+	g := NewGate(Limits{MaxOrderQty: 10, MaxOrdersPerS: 3})
+	for i := 0; i < 3; i++ {
+		if err := g.CheckOrder(order(callLo, enum.Side_BUY, 0.012, 1)); err != nil {
+			t.Fatalf("order %d: %v", i, err)
+		}
+	}
+	// A rejected order does not use up the budget, the next one hits it
+	if err := g.CheckOrder(order(callLo, enum.Side_BUY, 0.012, 11)); !errors.Is(err, ErrMaxQty) {
+		t.Fatalf("oversized order: %v", err)
+	}
+	if err := g.CheckOrder(order(callLo, enum.Side_BUY, 0.012, 1)); !errors.Is(err, ErrRateLimit) {
+		t.Fatalf("4th order within a second: %v, want ErrRateLimit", err)
+	}
+
+	// The window slides: once the oldest send is a second old a slot frees
+	g.rmu.Lock()
+	g.sends[g.head] -= int64(time.Second)
+	g.rmu.Unlock()
+	if err := g.CheckOrder(order(callLo, enum.Side_BUY, 0.012, 1)); err != nil {
+		t.Fatalf("after the window: %v", err)
+	}
+	if err := g.CheckOrder(order(callLo, enum.Side_BUY, 0.012, 1)); !errors.Is(err, ErrRateLimit) {
+		t.Fatalf("second order after one slot freed: %v, want ErrRateLimit", err)
+	}
+}
+
+func TestGateCheckBox(t *testing.T) {
+	g := NewGate(Limits{MaxBoxUSD: 20000})
+	if err := g.CheckBox(60000, 70000, 2); err != nil {
+		t.Fatalf("box at the limit: %v", err)
+	}
+	if err := g.CheckBox(60000, 70000, 2.5); !errors.Is(err, ErrBoxNotional) {
+		t.Fatalf("box above the limit: %v", err)
+	}
+	g.Halt("test")
+	if err := g.CheckBox(60000, 61000, 1); !errors.Is(err, ErrHalted) {
+		t.Fatalf("halted box: %v", err)
+	}
+}
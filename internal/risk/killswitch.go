@@ -0,0 +1,382 @@
+package risk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"Options_Hedger/internal/data"
+	"Options_Hedger/internal/fix"
+	"Options_Hedger/internal/notify"
+	"Options_Hedger/internal/positions"
+
+	"github.com/quickfixgo/enum"
+)
+
+// KillConfig for the kill switch; zero disables an automatic trigger.
+//
+// Env:
+//
+//	HEDGE_KILL_STATE_FILE=killswitch.json  (trip survives restarts until re-armed)
+//	HEDGE_KILL_FLATTEN=0                    (close positions with IOC orders on trip)
+//	HEDGE_KILL_FLATTEN_SLIP_TICKS=10        (how far through the touch flatten orders go)
+//	HEDGE_KILL_DAILY_LOSS_USD=0             (PnL drop since 00:00 UTC / start / re-arm)
+//	HEDGE_KILL_FIX_DOWN_SEC=30              (FIX session down after having been up)
+//	HEDGE_KILL_INDEX_STALE_SEC=30           (no index price update)
+//	HEDGE_KILL_MAX_REJECTS=5                (exchange rejects within the window)
+//	HEDGE_KILL_REJECT_WINDOW_SEC=60
+type KillConfig struct {
+	StateFile        string
+	Flatten          bool
+	FlattenSlipTicks float64
+	DailyLossUSD     float64
+	FIXDown          time.Duration
+	IndexStale       time.Duration
+	MaxRejects       int
+	RejectWindow     time.Duration
+	CheckEvery       time.Duration
+}
+
+// KillConfigFromEnv reads HEDGE_KILL_* settings.
+func KillConfigFromEnv() KillConfig {
+	c := KillConfig{
+		StateFile:        strings.TrimSpace(os.Getenv("HEDGE_KILL_STATE_FILE")),
+		Flatten:          strings.TrimSpace(os.Getenv("HEDGE_KILL_FLATTEN")) == "1",
+		FlattenSlipTicks: envFloat("HEDGE_KILL_FLATTEN_SLIP_TICKS", 10),
+		DailyLossUSD:     envFloat("HEDGE_KILL_DAILY_LOSS_USD", 0),
+		FIXDown:          time.Duration(envFloat("HEDGE_KILL_FIX_DOWN_SEC", 30) * float64(time.Second)),
+		IndexStale:       time.Duration(envFloat("HEDGE_KILL_INDEX_STALE_SEC", 30) * float64(time.Second)),
+		MaxRejects:       int(envFloat("HEDGE_KILL_MAX_REJECTS", 5)),
+		RejectWindow:     time.Duration(envFloat("HEDGE_KILL_REJECT_WINDOW_SEC", 60) * float64(time.Second)),
+		CheckEvery:       time.Second,
+	}
+	if c.StateFile == "" {
+		c.StateFile = "killswitch.json"
+	}
+	return c
+}
+
+// KillState is the persisted kill switch state.
+type KillState struct {
+	Tripped bool      `json:"tripped"`
+	Reason  string    `json:"reason,omitempty"`
+	At      time.Time `json:"at,omitempty"`
+}
+
+// ErrNotTripped is returned by Rearm when the switch is armed already.
+var ErrNotTripped = errors.New("risk: kill switch not tripped")
+
+// KillSwitch halts the gate, cancels every working order and optionally
+// flattens positions. A trip is written to StateFile so a restarted hedger
+// stays halted until Rearm.
+type KillSwitch struct {
+	cfg  KillConfig
+	gate *Gate
+	ntf  notify.Notifier
+
+	mu    sync.Mutex
+	state KillState
+
+	// Automatic trigger state, owned by Run
+	lossDay    int64   // UTC day of lossBase
+	lossBase   float64 // PnL (USD) at the start of the day / re-arm
+	seenUp     bool
+	wasUp      bool // logged on at the previous check
+	downSince  time.Time
+	rmu        sync.Mutex
+	rejects    []time.Time
+	rearmedNow bool // guarded by rmu: reset baselines on the next check
+}
+
+// NewKillSwitch restores the persisted state; a tripped switch halts g at once.
+func NewKillSwitch(g *Gate, cfg KillConfig, ntf notify.Notifier) *KillSwitch {
+	k := &KillSwitch{cfg: cfg, gate: g, ntf: ntf}
+	b, err := os.ReadFile(cfg.StateFile)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(b, &k.state); err != nil {
+			// Unreadable state: fail safe
+			k.state = KillState{Tripped: true, Reason: "unreadable state file: " + err.Error(), At: time.Now()}
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		k.state = KillState{Tripped: true, Reason: "state file: " + err.Error(), At: time.Now()}
+	}
+	if k.state.Tripped {
+		g.Halt("kill switch: " + k.state.Reason)
+		log.Printf("[KILL] restored TRIPPED state (%s, %s): trading halted until re-armed",
+			k.state.Reason, k.state.At.Format(time.RFC3339))
+	}
+	return k
+}
+
+// State returns the current state.
+func (k *KillSwitch) State() KillState {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.state
+}
+
+// Trip halts trading, pulls every order and, with Flatten, closes positions.
+// Tripping again re-sends the cancels but keeps the original reason.
+func (k *KillSwitch) Trip(reason string) {
+	k.mu.Lock()
+	first := !k.state.Tripped
+	if first {
+		k.state = KillState{Tripped: true, Reason: reason, At: time.Now().UTC()}
+		k.gate.Halt("kill switch: " + reason)
+	}
+	err := k.save()
+	k.mu.Unlock()
+
+	log.Printf("[KILL] TRIPPED: %s", reason)
+	if err != nil {
+		log.Printf("[KILL] persist failed: %v", err)
+	}
+
+	msg := "[KILL SWITCH] " + reason
+	n, cerr := fix.CancelAll(fix.CancelFilter{})
+	if merr := fix.MassCancel(""); merr != nil {
+		cerr = errors.Join(cerr, merr)
+	}
+	msg += fmt.Sprintf("\ncancels sent: %d", n)
+	if cerr != nil {
+		msg += "\ncancel errors: " + cerr.Error()
+	}
+	if k.cfg.Flatten {
+		msg += "\n" + k.flatten()
+	}
+	if first {
+		k.notify(msg)
+	}
+}
+
+// Rearm clears a trip and resumes trading. The daily loss baseline restarts
+// from the current PnL.
+func (k *KillSwitch) Rearm() error {
+	k.mu.Lock()
+	if !k.state.Tripped {
+		k.mu.Unlock()
+		return ErrNotTripped
+	}
+	prev := k.state
+	k.state = KillState{}
+	err := k.save()
+	k.gate.Resume()
+	k.mu.Unlock()
+
+	k.rmu.Lock()
+	k.rejects = k.rejects[:0]
+	k.rearmedNow = true
+	k.rmu.Unlock()
+
+	if err != nil {
+		log.Printf("[KILL] persist failed: %v", err)
+	}
+	log.Printf("[KILL] re-armed (was: %s)", prev.Reason)
+	k.notify("[KILL SWITCH] re-armed, trading resumed")
+	return nil
+}
+
+// save writes the state atomically (k.mu held).
+func (k *KillSwitch) save() error {
+	b, _ := json.MarshalIndent(k.state, "", "  ")
+	if dir := filepath.Dir(k.cfg.StateFile); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	tmp := k.cfg.StateFile + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, k.cfg.StateFile)
+}
+
+func (k *KillSwitch) notify(msg string) {
+	if k.ntf == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_ = k.ntf.Send(ctx, msg)
+}
+
+// flatten sends one aggressive reducing IOC per open position and returns
+// a summary. Positions without a quote are left for the operator.
+func (k *KillSwitch) flatten() string {
+	sent, skipped := 0, []string{}
+	for _, p := range positions.All() {
+		side, qty := enum.Side_SELL, p.Qty
+		if p.Qty < 0 {
+			side, qty = enum.Side_BUY, -p.Qty
+		}
+		idx := data.LookupSymbol(p.Symbol)
+		if idx < 0 {
+			skipped = append(skipped, p.Symbol)
+			continue
+		}
+		tick := 0.0005
+		if m, ok := data.InstrumentAt(idx); ok && m.TickSize > 0 {
+			tick = m.TickSize
+		}
+		d := data.ReadDepthFast(int(idx))
+		px := d.BidPrice - k.cfg.FlattenSlipTicks*tick
+		if side == enum.Side_BUY {
+			px = d.AskPrice + k.cfg.FlattenSlipTicks*tick
+		}
+		if (side == enum.Side_SELL && d.BidPrice <= 0) || (side == enum.Side_BUY && d.AskPrice <= 0) {
+			skipped = append(skipped, p.Symbol)
+			continue
+		}
+		px = math.Max(math.Round(px/tick)*tick, tick)
+		req := fix.OrderReq{Symbol: p.Symbol, Side: side, Price: px, Qty: qty,
+			TIF: enum.TimeInForce_IMMEDIATE_OR_CANCEL, ClOrdPrefix: "KILL", Reduce: true}
+		if _, err := fix.SendOrderReq(&req); err != nil {
+			log.Printf("[KILL] flatten %s %s %g: %v", sideName(side), p.Symbol, qty, err)
+			skipped = append(skipped, p.Symbol)
+			continue
+		}
+		sent++
+	}
+	out := fmt.Sprintf("flatten orders sent: %d", sent)
+	if len(skipped) > 0 {
+		out += "\nNOT flattened: " + strings.Join(skipped, ", ")
+	}
+	log.Printf("[KILL] %s", out)
+	return out
+}
+
+// Run watches the automatic triggers until ctx is done.
+func (k *KillSwitch) Run(ctx context.Context) {
+	if k.cfg.MaxRejects > 0 {
+		remove := fix.OnOrderEvent(k.onOrderEvent)
+		defer remove()
+	}
+	t := time.NewTicker(k.cfg.CheckEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if reason := k.check(time.Now()); reason != "" {
+				k.Trip(reason)
+			}
+		}
+	}
+}
+
+// check evaluates the automatic triggers and returns a trip reason.
+func (k *KillSwitch) check(now time.Time) string {
+	up := fix.LoggedOn()
+	tripped := k.State().Tripped
+
+	k.rmu.Lock()
+	rearmed := k.rearmedNow
+	k.rearmedNow = false
+	k.rmu.Unlock()
+
+	// FIX session: only counts as down after it has been up once
+	if up && !k.wasUp && tripped {
+		// (Re)connected while tripped: pull anything still resting
+		if err := fix.MassCancel(""); err != nil {
+			log.Printf("[KILL] mass cancel on logon: %v", err)
+		}
+	}
+	if up {
+		k.seenUp, k.downSince = true, time.Time{}
+	} else if k.seenUp && k.downSince.IsZero() {
+		k.downSince = now
+	}
+	k.wasUp = up
+	if tripped {
+		return ""
+	}
+	if k.cfg.FIXDown > 0 && !k.downSince.IsZero() && now.Sub(k.downSince) > k.cfg.FIXDown {
+		return fmt.Sprintf("FIX session down for %s", now.Sub(k.downSince).Truncate(time.Second))
+	}
+
+	if k.cfg.IndexStale > 0 && up {
+		if last := fix.IndexLastUpdateNs(); last > 0 {
+			if age := time.Duration(data.Nanotime() - last); age > k.cfg.IndexStale {
+				return fmt.Sprintf("index price stale for %s", age.Truncate(time.Second))
+			}
+		}
+	}
+
+	if k.cfg.DailyLossUSD > 0 {
+		if s := data.GetIndexPrice(); s > 0 {
+			realized, open := positions.PnLBTC()
+			pnl := (realized + open) * s
+			day := now.UTC().Unix() / 86400
+			if rearmed || day != k.lossDay {
+				k.lossDay, k.lossBase = day, pnl
+			}
+			if loss := k.lossBase - pnl; loss > k.cfg.DailyLossUSD {
+				return fmt.Sprintf("daily loss $%.0f > $%.0f", loss, k.cfg.DailyLossUSD)
+			}
+		}
+	}
+
+	if k.cfg.MaxRejects > 0 {
+		k.rmu.Lock()
+		cut := 0
+		for cut < len(k.rejects) && now.Sub(k.rejects[cut]) > k.cfg.RejectWindow {
+			cut++
+		}
+		k.rejects = k.rejects[cut:]
+		n := len(k.rejects)
+		k.rmu.Unlock()
+		if n >= k.cfg.MaxRejects {
+			return fmt.Sprintf("%d order rejects within %s", n, k.cfg.RejectWindow)
+		}
+	}
+	return ""
+}
+
+func (k *KillSwitch) onOrderEvent(ev fix.OrderEvent) {
+	if ev.Kind != fix.EvRejected {
+		return
+	}
+	k.rmu.Lock()
+	k.rejects = append(k.rejects, time.Now())
+	k.rmu.Unlock()
+}
+
+// HandleCommand runs an operator chat command and returns the reply:
+//
+//	/kill [reason]   trip
+//	/rearm           re-arm
+//	/status          state
+func (k *KillSwitch) HandleCommand(text string) string {
+	cmd, arg, _ := strings.Cut(strings.TrimSpace(text), " ")
+	cmd, _, _ = strings.Cut(cmd, "@") // /kill@botname
+	switch strings.ToLower(cmd) {
+	case "/kill":
+		reason := "telegram"
+		if arg = strings.TrimSpace(arg); arg != "" {
+			reason += ": " + arg
+		}
+		k.Trip(reason)
+		return "kill switch TRIPPED"
+	case "/rearm":
+		if err := k.Rearm(); err != nil {
+			return err.Error()
+		}
+		return "kill switch re-armed"
+	case "/status":
+		if s := k.State(); s.Tripped {
+			return fmt.Sprintf("kill switch TRIPPED at %s: %s", s.At.Format(time.RFC3339), s.Reason)
+		}
+		return "kill switch armed, trading enabled"
+	}
+	return ""
+}
@@ -0,0 +1,190 @@
+package risk
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"Options_Hedger/internal/data"
+	"Options_Hedger/internal/fix"
+)
+
+func newTestKillSwitch(t *testing.T, cfg KillConfig) (*KillSwitch, *Gate) {
+	t.Helper()
+	if cfg.StateFile == "" {
+		cfg.StateFile = filepath.Join(t.TempDir(), "kill.json")
+	}
+	g := NewGate(Limits{})
+	return NewKillSwitch(g, cfg, nil), g
+}
+
+func TestKillSwitchTriggers(t *testing.T) {
+	setupBooks(t)
+	t0 := time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		cfg   KillConfig
+		setup func(t *testing.T, k *KillSwitch)
+		// checks at t0+offset (rejects are stamped with the wall clock, so
+		// their checks start at time.Since(t0)); only the last may trip
+		at   []time.Duration
+		move func(t *testing.T) // between the first and second check
+		want string             // reason prefix, "" = no trip
+	}{
+		{
+			name:  "FIX down after having been up",
+			cfg:   KillConfig{FIXDown: 30 * time.Second},
+			setup: func(t *testing.T, k *KillSwitch) { k.seenUp, k.wasUp = true, true },
+			at:    []time.Duration{0, 31 * time.Second},
+			want:  "FIX session down",
+		},
+		{
+			name:  "FIX down within the grace period",
+			cfg:   KillConfig{FIXDown: 30 * time.Second},
+			setup: func(t *testing.T, k *KillSwitch) { k.seenUp, k.wasUp = true, true },
+			at:    []time.Duration{0, 29 * time.Second},
+		},
+		{
+			name: "FIX never up",
+			cfg:  KillConfig{FIXDown: 30 * time.Second},
+			at:   []time.Duration{0, time.Hour},
+		},
+		{
+			name: "daily loss",
+			cfg:  KillConfig{DailyLossUSD: 100},
+			setup: func(t *testing.T, k *KillSwitch) {
+				hold(t, callLo, 1)
+				requote(t, 0, 0.010, 0.012)
+			},
+			at:   []time.Duration{0, time.Minute},
+			move: func(t *testing.T) { requote(t, 0, 0.008, 0.010) }, // $120 on one contract
+			want: "daily loss",
+		},
+		{
+			name: "daily loss baseline restarts each UTC day",
+			cfg:  KillConfig{DailyLossUSD: 100},
+			setup: func(t *testing.T, k *KillSwitch) {
+				hold(t, callLo, 1)
+				requote(t, 0, 0.010, 0.012)
+			},
+			at:   []time.Duration{0, 24 * time.Hour},
+			move: func(t *testing.T) { requote(t, 0, 0.008, 0.010) },
+		},
+		{
+			name: "rejects within the window",
+			cfg:  KillConfig{MaxRejects: 3, RejectWindow: time.Minute},
+			setup: func(t *testing.T, k *KillSwitch) {
+				for i := 0; i < 3; i++ {
+					k.onOrderEvent(fix.OrderEvent{Kind: fix.EvRejected})
+				}
+				k.onOrderEvent(fix.OrderEvent{Kind: fix.EvCanceled})
+			},
+			at:   []time.Duration{time.Since(t0)},
+			want: "3 order rejects",
+		},
+		{
+			name: "rejects below the count",
+			cfg:  KillConfig{MaxRejects: 3, RejectWindow: time.Minute},
+			setup: func(t *testing.T, k *KillSwitch) {
+				for i := 0; i < 2; i++ {
+					k.onOrderEvent(fix.OrderEvent{Kind: fix.EvRejected})
+				}
+				k.onOrderEvent(fix.OrderEvent{Kind: fix.EvCancelRejected})
+			},
+			at: []time.Duration{time.Since(t0)},
+		},
+		{
+			name: "rejects age out of the window",
+			cfg:  KillConfig{MaxRejects: 3, RejectWindow: time.Minute},
+			setup: func(t *testing.T, k *KillSwitch) {
+				for i := 0; i < 3; i++ {
+					k.onOrderEvent(fix.OrderEvent{Kind: fix.EvRejected})
+				}
+			},
+			at: []time.Duration{time.Since(t0) + 2*time.Minute},
+		},
+		{
+			name: "no automatic trip while tripped",
+			cfg:  KillConfig{MaxRejects: 1, RejectWindow: time.Minute},
+			setup: func(t *testing.T, k *KillSwitch) {
+				k.state.Tripped = true
+				k.onOrderEvent(fix.OrderEvent{Kind: fix.EvRejected})
+			},
+			at: []time.Duration{time.Since(t0)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, _ := newTestKillSwitch(t, tt.cfg)
+			if tt.setup != nil {
+				tt.setup(t, k)
+			}
+			var reason string
+			for i, off := range tt.at {
+				if i == 1 && tt.move != nil {
+					tt.move(t)
+				}
+				if reason = k.check(t0.Add(off)); reason != "" && i < len(tt.at)-1 {
+					t.Fatalf("tripped early at check %d: %s", i, reason)
+				}
+			}
+			if tt.want == "" && reason != "" || !strings.HasPrefix(reason, tt.want) {
+				t.Fatalf("check() = %q, want %q", reason, tt.want)
+			}
+		})
+	}
+}
+
+// requote sets the touch of a slot.
+func requote(t *testing.T, idx int, bid, ask float64) {
+	t.Helper()
+	data.WriteDepthFast(idx, bid, 5, ask, 5)
+}
+
+func TestKillSwitchPersistsTrip(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "kill.json")
+	k, g := newTestKillSwitch(t, KillConfig{StateFile: state})
+	k.Trip("test")
+	if r, ok := g.Halted(); !ok || r != "kill switch: test" {
+		t.Fatalf("gate after Trip: %q, %v", r, ok)
+	}
+
+	// A restart comes back halted
+	k2, g2 := newTestKillSwitch(t, KillConfig{StateFile: state})
+	if s := k2.State(); !s.Tripped || s.Reason != "test" {
+		t.Fatalf("restored state = %+v", s)
+	}
+	if _, ok := g2.Halted(); !ok {
+		t.Fatal("restored switch did not halt the gate")
+	}
+
+	if err := k2.Rearm(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g2.Halted(); ok {
+		t.Fatal("gate still halted after Rearm")
+	}
+	if err := k2.Rearm(); !errors.Is(err, ErrNotTripped) {
+		t.Fatalf("second Rearm() = %v, want ErrNotTripped", err)
+	}
+	if k3, _ := newTestKillSwitch(t, KillConfig{StateFile: state}); k3.State().Tripped {
+		t.Fatal("re-armed state not persisted")
+	}
+}
+
+func TestKillSwitchUnreadableStateFailsSafe(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "kill.json")
+	if err := os.WriteFile(state, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	k, g := newTestKillSwitch(t, KillConfig{StateFile: state})
+	if !k.State().Tripped {
+		t.Fatal("unreadable state file did not trip the switch")
+	}
+	if _, ok := g.Halted(); !ok {
+		t.Fatal("unreadable state file did not halt the gate")
+	}
+}
@@ -0,0 +1,206 @@
+package servers
+
+import (
+	"Options_Hedger/internal/data"
+	"Options_Hedger/internal/fix"
+	"Options_Hedger/internal/paper"
+	"Options_Hedger/internal/positions"
+	"Options_Hedger/internal/pricing"
+	"Options_Hedger/internal/risk"
+	"Options_Hedger/internal/surface"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServeAdminHTTP starts the operator API on HEDGE_ADMIN_ADDR
+// (default 127.0.0.1:7072, "off" disables):
+//
+//	GET  /positions[?symbol=...]  position book
+//	GET  /kill                    kill switch state
+//	POST /kill[?reason=...]       trip the kill switch
+//	POST /kill/rearm              re-arm after a trip
+//	GET  /throttle                order-entry credit throttler metrics
+//	GET  /paper                   paper trading fills, fees and PnL
+//	GET  /greeks[?symbol=...]     implied vols and greeks per option
+//	GET  /surface[?expiry=...[&strike=...]]  fitted SVI smiles, or one fitted vol
+//	GET  /curve                   parity-implied forwards and rates per expiry
+func ServeAdminHTTP(ks *risk.KillSwitch) {
+	addr := strings.TrimSpace(os.Getenv("HEDGE_ADMIN_ADDR"))
+	if addr == "off" {
+		return
+	}
+	if addr == "" {
+		addr = "127.0.0.1:7072"
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/positions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if sym := r.URL.Query().Get("symbol"); sym != "" {
+			writeJSON(w, positions.Get(sym))
+			return
+		}
+		writeJSON(w, struct {
+			Positions     []positions.Position `json:"positions"`
+			LastReconcile time.Time            `json:"last_reconcile"`
+		}{positions.All(), positions.LastReconcile()})
+	})
+
+	mux.HandleFunc("/kill", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			reason := "http"
+			if q := strings.TrimSpace(r.URL.Query().Get("reason")); q != "" {
+				reason += ": " + q
+			}
+			ks.Trip(reason)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, ks.State())
+	})
+
+	mux.HandleFunc("/kill/rearm", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := ks.Rearm(); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		writeJSON(w, ks.State())
+	})
+
+	mux.HandleFunc("/throttle", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, fix.ThrottleStatus())
+	})
+
+	mux.HandleFunc("/paper", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		sim := paper.Active()
+		if sim == nil {
+			http.Error(w, "paper trading is off", http.StatusNotFound)
+			return
+		}
+		realized, open := positions.PnLBTC()
+		writeJSON(w, struct {
+			paper.Stats
+			RealizedBTC float64 `json:"realized_btc"`
+			OpenBTC     float64 `json:"open_btc"`
+			PnLUSD      float64 `json:"pnl_usd"`
+		}{sim.Stats(), realized, open, (realized + open) * data.GetIndexPrice()})
+	})
+
+	mux.HandleFunc("/greeks", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		type row struct {
+			Symbol string `json:"symbol"`
+			data.Greeks
+		}
+		if sym := r.URL.Query().Get("symbol"); sym != "" {
+			g, ok := data.ReadGreeksFast(int(data.LookupSymbol(sym)))
+			if !ok {
+				http.Error(w, "no greeks for "+sym, http.StatusNotFound)
+				return
+			}
+			writeJSON(w, row{sym, g})
+			return
+		}
+		rows := []row{}
+		for i := int32(0); i < data.GetSymbolCount(); i++ {
+			name := data.GetSymbolName(i)
+			if g, ok := data.ReadGreeksFast(int(i)); ok && name != "" {
+				rows = append(rows, row{name, g})
+			}
+		}
+		writeJSON(w, rows)
+	})
+
+	mux.HandleFunc("/surface", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		f := surface.Active()
+		if f == nil {
+			http.Error(w, "surface fitting is off", http.StatusNotFound)
+			return
+		}
+		snap := f.Snapshot()
+		if snap == nil {
+			http.Error(w, "no fit yet", http.StatusServiceUnavailable)
+			return
+		}
+		exp := r.URL.Query().Get("expiry")
+		if exp == "" {
+			writeJSON(w, snap)
+			return
+		}
+		sm, ok := snap.Smile(exp)
+		if !ok {
+			http.Error(w, "unknown expiry "+exp, http.StatusNotFound)
+			return
+		}
+		if q := r.URL.Query().Get("strike"); q != "" {
+			strike, err := strconv.ParseFloat(q, 64)
+			if err != nil || strike <= 0 {
+				http.Error(w, "bad strike", http.StatusBadRequest)
+				return
+			}
+			writeJSON(w, struct {
+				Expiry string  `json:"expiry"`
+				Strike float64 `json:"strike"`
+				IV     float64 `json:"iv"`
+			}{exp, strike, sm.IV(strike)})
+			return
+		}
+		writeJSON(w, sm)
+	})
+
+	mux.HandleFunc("/curve", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ts := pricing.Curve()
+		if ts == nil {
+			http.Error(w, "no term structure yet", http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, ts)
+	})
+
+	go func() {
+		log.Printf("[ADMIN-HTTP] listening on http://%s (GET /positions, /kill, /throttle, /paper, /greeks, /surface, /curve)", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("[ADMIN-HTTP] server stopped: %v", err)
+		}
+	}()
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -3,15 +3,21 @@ package strategy
 import (
 	"Options_Hedger/internal/data"
 	"Options_Hedger/internal/notify"
-	"strconv"
-	"strings"
+	"math"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 const (
 	BoxLong    int8    = +1
 	BoxShort   int8    = -1
 	legsPerBox float64 = 4.0
+
+	nsPerYear  = 365 * 24 * float64(time.Hour)
+	maxBoxRate = 10.0  // clamp for boxes priced at or below zero
+	maxBoxAPR  = 100.0 // clamp for boxes tying up no capital
+	boardCap   = 128   // waiting signals kept when topN is 0
 )
 
 // OptionInfo: compact option metadata (32 bytes)
@@ -32,28 +38,62 @@ type BoxSignal struct {
 	LowStrike    float64
 	HighStrike   float64
 	Profit       float64 // USD profit floor (worst-case)
+	Qty          float64 // executable box size at detection
+	Rate         float64 // annualized rate the box lends (long) or borrows (short) at, after fees
+	RateEdge     float64 // Rate beyond the hurdle: long Rate-hurdle, short hurdle-Rate
+	ExpiryMs     int64   // expiration (08:00 UTC on Deribit)
+	Years        float64 // time to expiry at detection
+	CapitalUSD   float64 // capital tied up until expiry (BoxCapitalUSD)
+	APR          float64 // Profit / CapitalUSD / Years
 	UpdateTimeNs int64
 	Side         int8 // +1: Long Box, -1: Short Box
+
+	dedupBit uint64 // recentSignals bit of the box
+}
+
+// strikeSlot groups the call/put pair of one (expiry, strike).
+type strikeSlot struct {
+	Strike float64
+	Expiry uint16
+	Call   int16 // symbol index, -1 if not subscribed
+	Put    int16 // symbol index, -1 if not subscribed
+}
+
+// optionChange is a runtime universe edit applied on the engine goroutine.
+type optionChange struct {
+	idx    int32
+	symbol string // "" = remove
 }
 
 type BoxSpreadHFT struct {
 	updates chan data.Update
-	signals chan BoxSignal
+	ready   chan struct{} // signalled when the board has new candidates
+	changes chan optionChange
 
-	// Cache-friendly option table
-	options     [data.MaxOptions]OptionInfo
+	// Cache-friendly option table (sized to data.Capacity(), indexed by symbol index)
+	options     []OptionInfo
 	optionCount int32
+	expiryIndex map[int64]uint16
+	expiryMs    []int64 // by OptionInfo.Expiry
 
-	// Fast lookups
-	expiryMap  [16]uint16
-	strikeMap  [data.MaxOptions]float64
-	pairLookup [data.MaxOptions][data.MaxOptions]bool
+	// Fast lookups: symbol -> strike slot, slot -> other slots of the same expiry
+	slotOf []int32
+	slots  []strikeSlot
+	peers  [][]int32
 
 	// Dedup & runtime state
 	recentSignals uint64
+	dedupNs       int64 // recentSignals reset interval in engine clock ns (0 = never)
+	lastReset     int64
+	pending       []BoxSignal // candidates of the current update, merged on flush
+	boardMu       sync.Mutex
+	board         []BoxSignal // waiting signals, best APR first (guarded by boardMu)
+	maxAgeNs      int64       // board entries older than this are dropped (0 = never)
 	lastCheck     int64
 	notifier      notify.Notifier
 	targetAtom    atomic.Value // HedgeTarget
+	now           func() int64 // signal timestamps; data.Nanotime unless replaying
+	wall          func() int64 // Unix ns for time to expiry
 
 	// Runtime params
 	minStrikeGap float64 // min strike distance (USD)
@@ -61,6 +101,10 @@ type BoxSpreadHFT struct {
 	minProfitUSD float64 // profit floor threshold (USD)
 	flatnessMax  float64 // legacy symmetric flatness cap: |netBTC|/Q (0=off)
 	maxQty       float64 // max executable qty cap (0=unlimited)
+	hurdleRate   float64 // annualized rate a box must beat (0=off)
+	minAPR       float64 // annualized yield on capital floor (0=off)
+	marginRate   float64 // short box margin as a fraction of the face value
+	topN         int     // signals kept on the board, best APR first (0=boardCap)
 
 	// Fees per 1 BTC notional per leg (either fixed USD or percent). If percent>0, it takes precedence.
 	feePerLegUSD  float64 // fixed fee per leg in USD
@@ -85,12 +129,20 @@ type BoxSpreadHFT struct {
 func NewBoxSpreadHFT(ch chan data.Update) *BoxSpreadHFT {
 	return &BoxSpreadHFT{
 		updates:       ch,
-		signals:       make(chan BoxSignal, 128),
+		ready:         make(chan struct{}, 1),
+		pending:       make([]BoxSignal, 0, 16),
+		board:         make([]BoxSignal, 0, boardCap),
+		changes:       make(chan optionChange, 256),
+		now:           data.Nanotime,
+		wall:          func() int64 { return time.Now().UnixNano() },
 		minStrikeGap:  1000,
 		debounceNs:    10000, // 10µs
+		dedupNs:       int64(time.Second),
+		maxAgeNs:      int64(200 * time.Millisecond),
 		minProfitUSD:  1.0,
 		flatnessMax:   0.02,
 		maxQty:        0,
+		marginRate:    0.1,
 		feePerLegUSD:  0.0,
 		feePerLegRate: 0.0001, // 0.01%
 		useBandCheck:  false,
@@ -108,71 +160,174 @@ func NewBoxSpreadHFT(ch chan data.Update) *BoxSpreadHFT {
 func (e *BoxSpreadHFT) SetNotifier(n notify.Notifier) { e.notifier = n }
 func (e *BoxSpreadHFT) SetTarget(t HedgeTarget)       { e.targetAtom.Store(t) }
 
+// SetClock replaces the signal timestamp source (event time in replays).
+// Replay timestamps are Unix ns, so it also drives time to expiry.
+func (e *BoxSpreadHFT) SetClock(now func() int64) { e.now, e.wall = now, now }
+
 // InitializeHFT ingests the pre-selected symbols universe for detection.
-// Expected symbol format: UNDERLYING-EXPIRY-STRIKE-C|P (e.g., BTC-15AUG25-116000-C)
-// Expiries are indexed to compact OptionInfo entries.
+// Strike, type and expiry come from the data instrument registry; expiries
+// are indexed to compact OptionInfo entries.
 func (e *BoxSpreadHFT) InitializeHFT(symbols []string) {
 	count := len(symbols)
-	if count > data.MaxOptions {
-		count = data.MaxOptions
+	if count > data.Capacity() {
+		count = data.Capacity()
 	}
+	e.options = make([]OptionInfo, data.Capacity())
+	e.expiryIndex = make(map[int64]uint16)
+	e.expiryMs = e.expiryMs[:0]
+	for i := range e.options {
+		e.options[i].Index = -1
+	}
+	for i := 0; i < count; i++ {
+		e.setOption(i, symbols[i])
+	}
+	e.optionCount = int32(len(e.options))
+	e.buildPairLookup()
+	// the table now mirrors data: nothing freed before is still referenced
+	for i := range e.options {
+		data.ReleaseSymbol(int32(i))
+	}
+}
 
-	expiryIndex := make(map[string]uint16)
-	var expiryCounter uint16
+// setOption loads instrument metadata into the option table slot idx ("" clears it).
+func (e *BoxSpreadHFT) setOption(idx int, symbol string) {
+	e.options[idx] = OptionInfo{Index: -1}
+	if symbol == "" {
+		return
+	}
+	meta, ok := data.InstrumentAt(int32(idx))
+	if !ok || meta.Name != symbol {
+		// slot not (yet) bound to this symbol in data
+		meta, ok = data.ResolveInstrument(symbol)
+	}
+	if !ok || meta.OptionType == data.NotOption {
+		return
+	}
+	if _, ok := e.expiryIndex[meta.ExpiryMs]; !ok {
+		e.expiryIndex[meta.ExpiryMs] = uint16(len(e.expiryIndex))
+		e.expiryMs = append(e.expiryMs, meta.ExpiryMs)
+	}
+	e.options[idx] = OptionInfo{
+		Strike: meta.Strike,
+		Expiry: e.expiryIndex[meta.ExpiryMs],
+		Index:  int16(idx),
+		IsCall: meta.IsCall(),
+	}
+}
 
-	for i := 0; i < count; i++ {
-		parts := strings.Split(symbols[i], "-")
-		if len(parts) != 4 {
-			continue
+// AddOption registers a runtime-subscribed symbol at its data index.
+// Safe from any goroutine: applied by Run between updates.
+func (e *BoxSpreadHFT) AddOption(idx int32, symbol string) {
+	e.changes <- optionChange{idx: idx, symbol: symbol}
+}
+
+// RemoveOption drops a symbol index from detection (applied by Run).
+func (e *BoxSpreadHFT) RemoveOption(idx int32) {
+	e.changes <- optionChange{idx: idx}
+}
+
+// applyChanges applies c plus any queued edits, then rebuilds the lookups once
+// and releases the data holds of the edited slots.
+func (e *BoxSpreadHFT) applyChanges(c optionChange) {
+	var edited []int32
+	for {
+		if c.idx >= 0 && int(c.idx) < len(e.options) {
+			e.setOption(int(c.idx), c.symbol)
 		}
-		strike, err := strconv.ParseFloat(parts[2], 64)
-		if err != nil {
+		edited = append(edited, c.idx)
+		select {
+		case c = <-e.changes:
 			continue
+		default:
 		}
-		expiry := parts[1]
-		if _, ok := expiryIndex[expiry]; !ok {
-			expiryIndex[expiry] = expiryCounter
-			e.expiryMap[expiryCounter] = expiryCounter
-			expiryCounter++
-		}
-		e.options[i] = OptionInfo{
-			Strike: strike,
-			Expiry: expiryIndex[expiry],
-			Index:  int16(i),
-			IsCall: parts[3] == "C",
-		}
-		e.strikeMap[i] = strike
+		break
 	}
-	e.optionCount = int32(count)
 	e.buildPairLookup()
+	for _, idx := range edited {
+		data.ReleaseSymbol(idx)
+	}
 }
 
-// buildPairLookup precomputes eligible pairs by same expiry and different strikes.
+// buildPairLookup groups options into (expiry, strike) slots and precomputes,
+// per slot, the other slots of the same expiry (candidate box partners).
 func (e *BoxSpreadHFT) buildPairLookup() {
 	count := int(e.optionCount)
+	type key struct {
+		expiry uint16
+		strike float64
+	}
+	slotIdx := make(map[key]int32, count)
+	e.slotOf = make([]int32, count)
+	e.slots = e.slots[:0]
+
 	for i := 0; i < count; i++ {
-		oi := &e.options[i]
-		for j := i + 1; j < count; j++ {
-			oj := &e.options[j]
-			if oi.Expiry == oj.Expiry && oi.Strike != oj.Strike {
-				e.pairLookup[i][j] = true
-				e.pairLookup[j][i] = true
+		e.slotOf[i] = -1
+		o := &e.options[i]
+		if o.Index < 0 {
+			continue
+		}
+		k := key{o.Expiry, o.Strike}
+		si, ok := slotIdx[k]
+		if !ok {
+			si = int32(len(e.slots))
+			slotIdx[k] = si
+			e.slots = append(e.slots, strikeSlot{Strike: o.Strike, Expiry: o.Expiry, Call: -1, Put: -1})
+		}
+		sl := &e.slots[si]
+		if o.IsCall {
+			if sl.Call == -1 {
+				sl.Call = int16(i)
+			}
+		} else if sl.Put == -1 {
+			sl.Put = int16(i)
+		}
+		e.slotOf[i] = si
+	}
+
+	e.peers = make([][]int32, len(e.slots))
+	for i := range e.slots {
+		for j := range e.slots {
+			if i != j && e.slots[i].Expiry == e.slots[j].Expiry {
+				e.peers[i] = append(e.peers[i], int32(j))
 			}
 		}
 	}
 }
 
-// Run consumes updates and triggers detection.
+// Run consumes updates and runtime universe edits, and triggers detection.
 func (e *BoxSpreadHFT) Run() {
-	for update := range e.updates {
-		e.processUpdateHFT(update)
+	for {
+		select {
+		case update, ok := <-e.updates:
+			if !ok {
+				return
+			}
+			e.processUpdateHFT(update)
+		case c := <-e.changes:
+			e.applyChanges(c)
+		}
 	}
 }
 
-// processUpdateHFT debounces and checks pairs related to the updated symbol.
+// Process runs detection for one update on the caller's goroutine, for
+// deterministic replays. Do not combine with Run.
+func (e *BoxSpreadHFT) Process(update data.Update) {
+	select {
+	case c := <-e.changes:
+		e.applyChanges(c)
+	default:
+	}
+	e.processUpdateHFT(update)
+}
+
+// processUpdateHFT debounces and checks boxes related to the updated symbol.
 func (e *BoxSpreadHFT) processUpdateHFT(update data.Update) {
 	idx := int(update.SymbolIdx)
-	if idx >= int(e.optionCount) {
+	if idx < 0 || idx >= int(e.optionCount) {
+		return
+	}
+	slot := e.slotOf[idx]
+	if slot < 0 {
 		return
 	}
 	// Debounce
@@ -181,16 +336,73 @@ func (e *BoxSpreadHFT) processUpdateHFT(update data.Update) {
 		return
 	}
 	e.lastCheck = now
+	// Dedup window on the engine clock, so replays age it like live
+	if t := e.now(); e.dedupNs > 0 && (t-e.lastReset >= e.dedupNs || t < e.lastReset) {
+		e.ResetSignalMask()
+		e.lastReset = t
+	}
 
-	count := int(e.optionCount)
-	for i := 0; i < count; i++ {
-		if !e.pairLookup[idx][i] {
-			continue
+	for _, peer := range e.peers[slot] {
+		e.checkBoxFast(int(slot), int(peer), update.IndexPrice)
+	}
+	e.flush()
+}
+
+// flush merges the candidates of one update into the board, replacing the
+// older signal of the same box, ranks it best APR first (ties: larger
+// profit) and keeps the topN, then wakes the consumer.
+func (e *BoxSpreadHFT) flush() {
+	if len(e.pending) == 0 {
+		return
+	}
+	limit := boardCap
+	if e.topN > 0 && e.topN < limit {
+		limit = e.topN
+	}
+	e.boardMu.Lock()
+	b := e.board
+	for _, sig := range e.pending {
+		i := 0
+		for i < len(b) && !sameBox(b[i], sig) {
+			i++
+		}
+		if i == len(b) {
+			b = append(b, sig)
+		} else {
+			b[i] = sig
+		}
+		for ; i > 0 && better(b[i], b[i-1]); i-- { // insertion sort: a handful of signals
+			b[i], b[i-1] = b[i-1], b[i]
+		}
+		for ; i < len(b)-1 && better(b[i+1], b[i]); i++ { // a refreshed box may rank lower
+			b[i], b[i+1] = b[i+1], b[i]
 		}
-		e.checkBoxFast(idx, i, update.IndexPrice)
+	}
+	if len(b) > limit {
+		b = b[:limit]
+	}
+	e.board = b
+	e.boardMu.Unlock()
+	e.pending = e.pending[:0]
+
+	select {
+	case e.ready <- struct{}{}:
+	default:
 	}
 }
 
+func sameBox(a, b BoxSignal) bool {
+	return a.Side == b.Side && a.LowCallIdx == b.LowCallIdx && a.LowPutIdx == b.LowPutIdx &&
+		a.HighCallIdx == b.HighCallIdx && a.HighPutIdx == b.HighPutIdx
+}
+
+func better(a, b BoxSignal) bool {
+	if a.APR != b.APR {
+		return a.APR > b.APR
+	}
+	return a.Profit > b.Profit
+}
+
 // passFlatnessDirectional applies either legacy symmetric flatness or directional band on slope.
 // slope := dPnL/dS per 1 qty = -netBTC/Q.
 func (e *BoxSpreadHFT) passFlatnessDirectional(slope float64) bool {
@@ -251,75 +463,49 @@ func (e *BoxSpreadHFT) passFlatnessDirectional(slope float64) bool {
 	}
 }
 
-// checkBoxFast evaluates both Long Box and Short Box for a given strike pair (same expiry).
-// It emits signals when worst-case profit floor exceeds minProfitUSD and flatness gates pass.
-func (e *BoxSpreadHFT) checkBoxFast(idx1, idx2 int, indexPrice float64) {
-	opt1 := &e.options[idx1]
-	opt2 := &e.options[idx2]
+// checkBoxFast evaluates both Long Box and Short Box for a given pair of
+// strike slots (same expiry).
+// It queues signals when worst-case profit floor exceeds minProfitUSD and the
+// flatness, hurdle and APR gates pass; flush ranks and sends them.
+func (e *BoxSpreadHFT) checkBoxFast(slot1, slot2 int, indexPrice float64) {
+	lo := &e.slots[slot1]
+	hi := &e.slots[slot2]
 
 	// Strike ordering (branch-only, avoids math.Min/Max)
-	lowStrike := opt1.Strike
-	highStrike := opt2.Strike
-	if lowStrike > highStrike {
-		lowStrike, highStrike = highStrike, lowStrike
+	if lo.Strike > hi.Strike {
+		lo, hi = hi, lo
 	}
+	lowStrike := lo.Strike
+	highStrike := hi.Strike
 	// Enforce min strike gap
 	if highStrike-lowStrike < e.minStrikeGap {
 		return
 	}
 
-	// Coarse dedup on (lowStrike, highStrike)
-	hash := uint64(lowStrike)*1000 + uint64(highStrike)
-	bit := hash & 63
-	if (e.recentSignals>>bit)&1 == 1 {
+	// 4 legs at the same expiry
+	lcIdx, lpIdx, hcIdx, hpIdx := lo.Call, lo.Put, hi.Call, hi.Put
+	if lcIdx == -1 || lpIdx == -1 || hcIdx == -1 || hpIdx == -1 {
 		return
 	}
-	atomic.OrUint64(&e.recentSignals, 1<<bit)
-
-	// Find 4 legs at the same expiry
-	expiry := opt1.Expiry
-	var lcIdx, lpIdx, hcIdx, hpIdx int16 = -1, -1, -1, -1
-	n := int(e.optionCount)
-	for i := 0; i < n; i++ {
-		o := &e.options[i]
-		if o.Expiry != expiry {
-			continue
-		}
-		s := o.Strike
-		if s == lowStrike {
-			if o.IsCall {
-				if lcIdx == -1 {
-					lcIdx = int16(i) // low strike call
-				}
-			} else {
-				if lpIdx == -1 {
-					lpIdx = int16(i) // low strike put
-				}
-			}
-		} else if s == highStrike {
-			if o.IsCall {
-				if hcIdx == -1 {
-					hcIdx = int16(i) // high strike call
-				}
-			} else {
-				if hpIdx == -1 {
-					hpIdx = int16(i) // high strike put
-				}
-			}
-		}
-		if lcIdx != -1 && lpIdx != -1 && hcIdx != -1 && hpIdx != -1 {
-			break
-		}
+	// Never trade a leg whose market data stopped updating
+	if !data.SymbolLive(int32(lcIdx)) || !data.SymbolLive(int32(lpIdx)) ||
+		!data.SymbolLive(int32(hcIdx)) || !data.SymbolLive(int32(hpIdx)) {
+		return
 	}
-	if lcIdx == -1 || lpIdx == -1 || hcIdx == -1 || hpIdx == -1 {
+
+	// Coarse dedup on (expiry, lowStrike, highStrike); set once a box is taken
+	hash := uint64(lowStrike)*1000 + uint64(highStrike) + uint64(lo.Expiry)
+	bit := uint64(1) << (hash & 63)
+	if atomic.LoadUint64(&e.recentSignals)&bit != 0 {
 		return
 	}
 
-	// Top-of-book snapshot
-	lc := data.ReadDepthFast(int(lcIdx)) // C(K_low)
-	lp := data.ReadDepthFast(int(lpIdx)) // P(K_low)
-	hc := data.ReadDepthFast(int(hcIdx)) // C(K_high)
-	hp := data.ReadDepthFast(int(hpIdx)) // P(K_high)
+	// Top-of-book snapshot (all four legs consistent at one instant)
+	legs := data.ReadDepth4Fast(int(lcIdx), int(lpIdx), int(hcIdx), int(hpIdx))
+	lc := &legs[0] // C(K_low)
+	lp := &legs[1] // P(K_low)
+	hc := &legs[2] // C(K_high)
+	hp := &legs[3] // P(K_high)
 
 	// Basic sanity
 	if lc.AskPrice <= 0 || lp.AskPrice <= 0 || hc.AskPrice <= 0 || hp.AskPrice <= 0 {
@@ -409,21 +595,31 @@ func (e *BoxSpreadHFT) checkBoxFast(idx1, idx2 int, indexPrice float64) {
 			}
 			profitFloor := fixedUSD*Qlong - netBTCL*Sstar - fees
 			if profitFloor >= e.minProfitUSD {
-				// inline send (no closure)
-				sig := BoxSignal{
-					LowCallIdx:   lcIdx,
-					LowPutIdx:    lpIdx,
-					HighCallIdx:  hcIdx,
-					HighPutIdx:   hpIdx,
-					LowStrike:    lowStrike,
-					HighStrike:   highStrike,
-					Profit:       profitFloor,
-					UpdateTimeNs: data.Nanotime(),
-					Side:         BoxLong,
-				}
-				select {
-				case e.signals <- sig:
-				default:
+				// lends at: pay the debit and fees now, receive the face at expiry
+				rate := e.boxRate(lo.Expiry, fixedUSD, (netBTCL*Sstar+fees)/Qlong)
+				capital := BoxCapitalUSD(BoxLong, fixedUSD*Qlong, netBTCL*Sstar+fees, e.marginRate)
+				apr := e.boxAPR(lo.Expiry, profitFloor, capital)
+				if (e.hurdleRate <= 0 || rate >= e.hurdleRate) && (e.minAPR <= 0 || apr >= e.minAPR) {
+					sig := BoxSignal{
+						LowCallIdx:   lcIdx,
+						LowPutIdx:    lpIdx,
+						HighCallIdx:  hcIdx,
+						HighPutIdx:   hpIdx,
+						LowStrike:    lowStrike,
+						HighStrike:   highStrike,
+						Profit:       profitFloor,
+						Qty:          Qlong,
+						Rate:         rate,
+						RateEdge:     rate - e.hurdleRate,
+						ExpiryMs:     e.expiryMs[lo.Expiry],
+						Years:        e.years(lo.Expiry),
+						CapitalUSD:   capital,
+						APR:          apr,
+						UpdateTimeNs: e.now(),
+						Side:         BoxLong,
+						dedupBit:     bit,
+					}
+					e.pending = append(e.pending, sig)
 				}
 			}
 		}
@@ -444,29 +640,108 @@ func (e *BoxSpreadHFT) checkBoxFast(idx1, idx2 int, indexPrice float64) {
 			}
 			profitFloor := -fixedUSD*Qshort - netBTCS*Sstar - fees
 			if profitFloor >= e.minProfitUSD {
-				// inline send (no closure)
-				sig := BoxSignal{
-					LowCallIdx:   lcIdx,
-					LowPutIdx:    lpIdx,
-					HighCallIdx:  hcIdx,
-					HighPutIdx:   hpIdx,
-					LowStrike:    lowStrike,
-					HighStrike:   highStrike,
-					Profit:       profitFloor,
-					UpdateTimeNs: data.Nanotime(),
-					Side:         BoxShort,
-				}
-				select {
-				case e.signals <- sig:
-				default:
+				// borrows at: receive the credit less fees now, owe the face at expiry
+				rate := e.boxRate(lo.Expiry, fixedUSD, (-netBTCS*Sstar-fees)/Qshort)
+				capital := BoxCapitalUSD(BoxShort, fixedUSD*Qshort, netBTCS*Sstar+fees, e.marginRate)
+				apr := e.boxAPR(lo.Expiry, profitFloor, capital)
+				if (e.hurdleRate <= 0 || rate <= e.hurdleRate) && (e.minAPR <= 0 || apr >= e.minAPR) {
+					sig := BoxSignal{
+						LowCallIdx:   lcIdx,
+						LowPutIdx:    lpIdx,
+						HighCallIdx:  hcIdx,
+						HighPutIdx:   hpIdx,
+						LowStrike:    lowStrike,
+						HighStrike:   highStrike,
+						Profit:       profitFloor,
+						Qty:          Qshort,
+						Rate:         rate,
+						RateEdge:     e.hurdleRate - rate,
+						ExpiryMs:     e.expiryMs[lo.Expiry],
+						Years:        e.years(lo.Expiry),
+						CapitalUSD:   capital,
+						APR:          apr,
+						UpdateTimeNs: e.now(),
+						Side:         BoxShort,
+						dedupBit:     bit,
+					}
+					e.pending = append(e.pending, sig)
 				}
 			}
 		}
 	}
 }
 
-// Signals exposes the non-blocking signal channel to downstream executors.
-func (e *BoxSpreadHFT) Signals() <-chan BoxSignal { return e.signals }
+// years returns the time to an expiry in years (0 once expired).
+func (e *BoxSpreadHFT) years(expiry uint16) float64 {
+	if int(expiry) >= len(e.expiryMs) {
+		return 0
+	}
+	return math.Max((float64(e.expiryMs[expiry])*1e6-float64(e.wall()))/nsPerYear, 0)
+}
+
+// boxRate returns the continuously compounded annual rate that turns pv USD
+// today into face USD at the expiry, clamped to ±maxBoxRate.
+func (e *BoxSpreadHFT) boxRate(expiry uint16, face, pv float64) float64 {
+	T := e.years(expiry)
+	if T <= 0 {
+		return 0
+	}
+	if pv <= 0 {
+		return maxBoxRate
+	}
+	return math.Max(-maxBoxRate, math.Min(maxBoxRate, math.Log(face/pv)/T))
+}
+
+// boxAPR annualizes profit on the capital tied up until the expiry, clamped
+// to maxBoxAPR.
+func (e *BoxSpreadHFT) boxAPR(expiry uint16, profit, capital float64) float64 {
+	T := e.years(expiry)
+	if T <= 0 {
+		return 0
+	}
+	if capital <= 0 {
+		return math.Copysign(maxBoxAPR, profit)
+	}
+	return math.Max(-maxBoxAPR, math.Min(maxBoxAPR, profit/capital/T))
+}
+
+// BoxCapitalUSD is the capital a box ties up until expiry. cost is the USD
+// paid now for all legs including fees (negative: a credit). A long box
+// costs its debit; a short box owes the face at expiry, so it holds the
+// part the credit does not cover plus margin of marginRate * face.
+func BoxCapitalUSD(side int8, face, cost, marginRate float64) float64 {
+	if side == BoxShort {
+		return math.Max(face+cost, 0) + marginRate*face
+	}
+	return math.Max(cost, 0)
+}
+
+// Ready is signalled whenever new candidates reach the board; the consumer
+// then calls NextSignal until it reports none.
+func (e *BoxSpreadHFT) Ready() <-chan struct{} { return e.ready }
+
+// NextSignal takes the best signal off the board, skipping signals older
+// than the max age and boxes signalled within the dedup window. Only taken
+// signals mark their box as signalled.
+func (e *BoxSpreadHFT) NextSignal() (BoxSignal, bool) {
+	now := e.now()
+	e.boardMu.Lock()
+	defer e.boardMu.Unlock()
+	for len(e.board) > 0 {
+		sig := e.board[0]
+		e.board = e.board[:copy(e.board, e.board[1:])]
+		if e.maxAgeNs > 0 && now-sig.UpdateTimeNs > e.maxAgeNs {
+			continue
+		}
+		if atomic.LoadUint64(&e.recentSignals)&sig.dedupBit != 0 {
+			continue
+		}
+		atomic.OrUint64(&e.recentSignals, sig.dedupBit)
+		return sig, true
+	}
+	return BoxSignal{}, false
+}
 
-// ResetSignalMask clears the coarse dedup bitmask; call periodically.
+// ResetSignalMask clears the coarse dedup bitmask. The engine also clears it
+// every Dedup interval of its clock.
 func (e *BoxSpreadHFT) ResetSignalMask() { atomic.StoreUint64(&e.recentSignals, 0) }
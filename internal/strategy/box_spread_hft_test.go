@@ -0,0 +1,69 @@
+package strategy
+
+import "testing"
+
+// boardEngine returns an engine whose clock reads *now.
+func boardEngine(now *int64) *BoxSpreadHFT {
+	e := NewBoxSpreadHFT(nil)
+	e.now = func() int64 { return *now }
+	return e
+}
+
+func box(lo, hi int16, apr float64, at int64, bit uint) BoxSignal {
+	return BoxSignal{LowCallIdx: lo, LowPutIdx: lo + 1, HighCallIdx: hi, HighPutIdx: hi + 1,
+		APR: apr, UpdateTimeNs: at, Side: BoxLong, dedupBit: 1 << bit}
+}
+
+func TestBoardServesBestAcrossUpdates(t *testing.T) {
+	var now int64 = 1000
+	e := boardEngine(&now)
+	e.topN = 2
+
+	e.pending = append(e.pending, box(0, 2, 0.10, now, 1), box(0, 4, 0.30, now, 2))
+	e.flush()
+	// A later update brings a better box and refreshes the 0/2 box lower
+	e.pending = append(e.pending, box(4, 6, 0.50, now, 3), box(0, 2, 0.05, now, 1))
+	e.flush()
+
+	var got []float64
+	for sig, ok := e.NextSignal(); ok; sig, ok = e.NextSignal() {
+		got = append(got, sig.APR)
+	}
+	if len(got) != 2 || got[0] != 0.50 || got[1] != 0.30 {
+		t.Fatalf("served APRs %v, want [0.5 0.3] (top 2)", got)
+	}
+}
+
+func TestBoardDedupOnlyTakenSignals(t *testing.T) {
+	var now int64 = 1000
+	e := boardEngine(&now)
+	e.topN = 1
+
+	// The cut box was never taken, so it is not suppressed
+	e.pending = append(e.pending, box(0, 2, 0.30, now, 1), box(0, 4, 0.10, now, 2))
+	e.flush()
+	if sig, ok := e.NextSignal(); !ok || sig.APR != 0.30 {
+		t.Fatalf("NextSignal() = %v, %v", sig.APR, ok)
+	}
+	if e.recentSignals != 1<<1 {
+		t.Fatalf("dedup mask = %b, want only the taken box", e.recentSignals)
+	}
+	e.pending = append(e.pending, box(0, 4, 0.10, now, 2))
+	e.flush()
+	if sig, ok := e.NextSignal(); !ok || sig.APR != 0.10 {
+		t.Fatalf("second NextSignal() = %v, %v; want the cut box", sig.APR, ok)
+	}
+}
+
+func TestBoardDropsAgedSignals(t *testing.T) {
+	var now int64 = 1000
+	e := boardEngine(&now)
+	e.maxAgeNs = 100
+
+	e.pending = append(e.pending, box(0, 2, 0.30, now, 1))
+	e.flush()
+	now += 101
+	if sig, ok := e.NextSignal(); ok {
+		t.Fatalf("NextSignal() served a %dns old signal", now-sig.UpdateTimeNs)
+	}
+}
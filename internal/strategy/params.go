@@ -0,0 +1,90 @@
+package strategy
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BoxParams are the tunable detection thresholds of BoxSpreadHFT.
+//
+// Env (defaults match NewBoxSpreadHFT):
+//
+//	HEDGE_BOX_MIN_PROFIT_USD=1      (worst-case profit floor per signal)
+//	HEDGE_BOX_MIN_STRIKE_GAP=1000   (USD between the two strikes)
+//	HEDGE_BOX_DEBOUNCE_US=10        (min time between checks)
+//	HEDGE_BOX_DEDUP_MS=1000         (a signalled box is not signalled again
+//	                                 for this long, 0 = never again)
+//	HEDGE_BOX_MAX_QTY=0             (box size cap, 0 = unlimited)
+//	HEDGE_BOX_FEE_RATE=0.0001       (fee per leg as a fraction of notional)
+//	HEDGE_BOX_FEE_USD=0             (fixed fee per leg, per contract)
+//	HEDGE_BOX_FLATNESS_MAX=0.02     (max |net BTC| per contract)
+//	HEDGE_BOX_HURDLE_RATE=0         (annual rate a long box must lend above /
+//	                                 a short box borrow below, 0 = off)
+//	HEDGE_BOX_MIN_APR=0             (annualized profit on capital floor, 0 = off)
+//	HEDGE_BOX_MARGIN_RATE=0.1       (short box margin, fraction of the face value)
+//	HEDGE_BOX_TOP_N=0               (signals waiting for the executor, best APR
+//	                                 first; 0 = 128)
+//	HEDGE_BOX_SIGNAL_MAX_AGE_MS=200 (a waiting signal not taken within this is
+//	                                 dropped, 0 = never)
+type BoxParams struct {
+	MinProfitUSD float64       `json:"min_profit_usd"`
+	MinStrikeGap float64       `json:"min_strike_gap"`
+	Debounce     time.Duration `json:"debounce_ns"`
+	Dedup        time.Duration `json:"dedup_ns"`
+	MaxQty       float64       `json:"max_qty"`
+	FeeRate      float64       `json:"fee_rate"`
+	FeeUSD       float64       `json:"fee_usd"`
+	FlatnessMax  float64       `json:"flatness_max"`
+	HurdleRate   float64       `json:"hurdle_rate"`
+	MinAPR       float64       `json:"min_apr"`
+	MarginRate   float64       `json:"margin_rate"`
+	TopN         int           `json:"top_n"`
+	MaxAge       time.Duration `json:"max_age_ns"`
+}
+
+// BoxParamsFromEnv reads HEDGE_BOX_* settings.
+func BoxParamsFromEnv() BoxParams {
+	return BoxParams{
+		MinProfitUSD: envFloat("HEDGE_BOX_MIN_PROFIT_USD", 1),
+		MinStrikeGap: envFloat("HEDGE_BOX_MIN_STRIKE_GAP", 1000),
+		Debounce:     time.Duration(envFloat("HEDGE_BOX_DEBOUNCE_US", 10) * float64(time.Microsecond)),
+		Dedup:        time.Duration(envFloat("HEDGE_BOX_DEDUP_MS", 1000) * float64(time.Millisecond)),
+		MaxQty:       envFloat("HEDGE_BOX_MAX_QTY", 0),
+		FeeRate:      envFloat("HEDGE_BOX_FEE_RATE", 0.0001),
+		FeeUSD:       envFloat("HEDGE_BOX_FEE_USD", 0),
+		FlatnessMax:  envFloat("HEDGE_BOX_FLATNESS_MAX", 0.02),
+		HurdleRate:   envFloat("HEDGE_BOX_HURDLE_RATE", 0),
+		MinAPR:       envFloat("HEDGE_BOX_MIN_APR", 0),
+		MarginRate:   envFloat("HEDGE_BOX_MARGIN_RATE", 0.1),
+		TopN:         int(envFloat("HEDGE_BOX_TOP_N", 0)),
+		MaxAge:       time.Duration(envFloat("HEDGE_BOX_SIGNAL_MAX_AGE_MS", 200) * float64(time.Millisecond)),
+	}
+}
+
+// SetParams applies p; call before Run / Process.
+func (e *BoxSpreadHFT) SetParams(p BoxParams) {
+	e.minProfitUSD = p.MinProfitUSD
+	e.minStrikeGap = p.MinStrikeGap
+	e.debounceNs = int64(p.Debounce)
+	e.dedupNs = int64(p.Dedup)
+	e.maxQty = p.MaxQty
+	e.feePerLegRate = p.FeeRate
+	e.feePerLegUSD = p.FeeUSD
+	e.flatnessMax = p.FlatnessMax
+	e.hurdleRate = p.HurdleRate
+	e.minAPR = p.MinAPR
+	e.marginRate = p.MarginRate
+	e.topN = p.TopN
+	e.maxAgeNs = int64(p.MaxAge)
+}
+
+func envFloat(key string, def float64) float64 {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if x, err := strconv.ParseFloat(v, 64); err == nil && x >= 0 {
+			return x
+		}
+	}
+	return def
+}
@@ -0,0 +1,306 @@
+package surface
+
+import (
+	"context"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"Options_Hedger/internal/data"
+	"Options_Hedger/internal/pricing"
+)
+
+// Config for the surface fitter.
+//
+// Env:
+//
+//	HEDGE_SVI_SEC=10           (refit interval, 0 disables)
+//	HEDGE_SVI_MIN_STRIKES=5    (fewer quoted strikes: the expiry is not fitted)
+//	HEDGE_SVI_MAX_SPREAD=0.2   (drop quotes whose bid/ask IV spread is wider)
+//	HEDGE_GREEKS_RATE=0        (discount rate, shared with the greeks worker)
+type Config struct {
+	Interval   time.Duration
+	MinStrikes int
+	MaxSpread  float64
+	Rate       float64
+}
+
+// ConfigFromEnv returns the fitter config, ok=false when HEDGE_SVI_SEC=0.
+func ConfigFromEnv() (Config, bool) {
+	c := Config{Interval: 10 * time.Second, MinStrikes: 5, MaxSpread: 0.2}
+	if v, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv("HEDGE_SVI_SEC")), 64); err == nil && v >= 0 {
+		c.Interval = time.Duration(v * float64(time.Second))
+	}
+	if v, err := strconv.Atoi(strings.TrimSpace(os.Getenv("HEDGE_SVI_MIN_STRIKES"))); err == nil && v >= 5 {
+		c.MinStrikes = v
+	}
+	if v, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv("HEDGE_SVI_MAX_SPREAD")), 64); err == nil && v > 0 {
+		c.MaxSpread = v
+	}
+	g, _ := pricing.ConfigFromEnv()
+	c.Rate = g.Rate
+	return c, c.Interval > 0
+}
+
+// StrikeFit is one quoted strike of a smile: the out-of-the-money option's
+// implied vols and the fitted vol. Residual = MidIV - FitIV (positive: the
+// market is rich to the fit).
+type StrikeFit struct {
+	Symbol   string  `json:"symbol"`
+	Strike   float64 `json:"strike"`
+	K        float64 `json:"k"` // ln(strike / forward)
+	BidIV    float64 `json:"bid_iv"`
+	AskIV    float64 `json:"ask_iv"`
+	MidIV    float64 `json:"mid_iv"`
+	FitIV    float64 `json:"fit_iv"`
+	Residual float64 `json:"residual"`
+}
+
+// Smile is the fit of one expiry. Butterfly and Calendar list the log
+// moneyness points where the fitted surface admits static arbitrage
+// (negative density, or total variance below the previous expiry's).
+type Smile struct {
+	Expiry    string      `json:"expiry"`
+	ExpiryMs  int64       `json:"expiry_ms"`
+	T         float64     `json:"t"`
+	Forward   float64     `json:"forward"`
+	Params    SVI         `json:"params"`
+	RMSE      float64     `json:"rmse"` // vol
+	Strikes   []StrikeFit `json:"strikes"`
+	Butterfly []float64   `json:"butterfly_violations,omitempty"`
+	Calendar  []float64   `json:"calendar_violations,omitempty"`
+	ArbFree   bool        `json:"arb_free"`
+	Err       string      `json:"error,omitempty"`
+}
+
+// IV returns the fitted implied vol at strike (0 when the expiry is not
+// fitted).
+func (s Smile) IV(strike float64) float64 {
+	if s.Err != "" || strike <= 0 || s.Forward <= 0 {
+		return 0
+	}
+	return s.Params.IV(math.Log(strike/s.Forward), s.T)
+}
+
+// Snapshot is the surface of one refit, expiries in time order.
+type Snapshot struct {
+	FittedAt time.Time `json:"fitted_at"`
+	Smiles   []Smile   `json:"smiles"`
+}
+
+// Smile returns the fit of an expiry label (e.g. "27SEP25").
+func (s *Snapshot) Smile(expiry string) (Smile, bool) {
+	if s == nil {
+		return Smile{}, false
+	}
+	for _, sm := range s.Smiles {
+		if sm.Expiry == expiry {
+			return sm, true
+		}
+	}
+	return Smile{}, false
+}
+
+// Fitter refits the surface from the books of the subscribed universe on its
+// own goroutine; readers get the latest Snapshot lock-free.
+type Fitter struct {
+	cfg    Config
+	latest atomic.Pointer[Snapshot]
+	arb    map[string]bool // last arbitrage state per expiry, for logging
+}
+
+// New returns a fitter; Run starts it.
+func New(cfg Config) *Fitter {
+	return &Fitter{cfg: cfg, arb: map[string]bool{}}
+}
+
+var active atomic.Pointer[Fitter]
+
+// Install makes f the fitter served by the admin API.
+func Install(f *Fitter) { active.Store(f) }
+
+// Active returns the installed fitter (nil if none).
+func Active() *Fitter { return active.Load() }
+
+// Snapshot returns the latest fit (nil before the first one).
+func (f *Fitter) Snapshot() *Snapshot { return f.latest.Load() }
+
+// Run refits every Interval until ctx is done.
+func (f *Fitter) Run(ctx context.Context) {
+	log.Printf("[SVI] refitting every %s (min %d strikes)", f.cfg.Interval, f.cfg.MinStrikes)
+	t := time.NewTicker(f.cfg.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			f.Fit(time.Now())
+		}
+	}
+}
+
+// Fit refits every expiry of the subscribed chain once and publishes the
+// result.
+func (f *Fitter) Fit(now time.Time) *Snapshot {
+	snap := &Snapshot{FittedAt: now, Smiles: f.collect(now)}
+	for i := range snap.Smiles {
+		f.fitSmile(&snap.Smiles[i])
+	}
+	f.calendar(snap.Smiles)
+	for i := range snap.Smiles {
+		sm := &snap.Smiles[i]
+		sm.ArbFree = sm.Err == "" && len(sm.Butterfly) == 0 && len(sm.Calendar) == 0
+		if prev, seen := f.arb[sm.Expiry]; sm.Err == "" && (!seen || prev != sm.ArbFree) {
+			if !sm.ArbFree {
+				log.Printf("[SVI] %s: fitted smile admits arbitrage (butterfly %d, calendar %d points)",
+					sm.Expiry, len(sm.Butterfly), len(sm.Calendar))
+			} else if seen {
+				log.Printf("[SVI] %s: fitted smile arbitrage-free again", sm.Expiry)
+			}
+			f.arb[sm.Expiry] = sm.ArbFree
+		}
+	}
+	f.latest.Store(snap)
+	return snap
+}
+
+// collect groups the subscribed options by expiry and solves implied vols
+// of the out-of-the-money side at each strike. Each expiry uses its parity
+// forward from the rate curve (the index until the curve has one), like the
+// greeks worker.
+func (f *Fitter) collect(now time.Time) []Smile {
+	S := data.GetIndexPrice()
+	if S <= 0 {
+		return nil
+	}
+	curve := pricing.Curve()
+	forwards := map[int64]float64{}
+	forward := func(exp int64) float64 {
+		F, ok := forwards[exp]
+		if !ok {
+			F = S
+			if e, ok := curve.Expiry(exp); ok && e.Forward > 0 {
+				F = e.Forward
+			}
+			forwards[exp] = F
+		}
+		return F
+	}
+	nowMs := now.UnixMilli()
+	type quote struct {
+		sym  string
+		meta data.InstrumentMeta
+		d    data.DepthEntry
+	}
+	chains := map[int64]map[float64]quote{}
+	labels := map[int64]string{}
+	n := data.GetSymbolCount()
+	for i := int32(0); i < n; i++ {
+		meta, ok := data.InstrumentAt(i)
+		if !ok || meta.OptionType == data.NotOption || meta.Strike <= 0 || meta.ExpiryMs <= nowMs {
+			continue
+		}
+		// out of the money: calls above the forward, puts below
+		if meta.IsCall() != (meta.Strike >= forward(meta.ExpiryMs)) {
+			continue
+		}
+		d := data.ReadDepthFast(int(i))
+		if d.BidPrice <= 0 || d.AskPrice <= 0 || !data.SymbolLive(i) {
+			continue
+		}
+		if chains[meta.ExpiryMs] == nil {
+			chains[meta.ExpiryMs] = map[float64]quote{}
+			labels[meta.ExpiryMs] = meta.ExpiryLabel()
+		}
+		chains[meta.ExpiryMs][meta.Strike] = quote{meta.Name, meta, d}
+	}
+
+	smiles := make([]Smile, 0, len(chains))
+	for exp, chain := range chains {
+		T, F := pricing.YearFrac(exp, nowMs), forward(exp)
+		sm := Smile{Expiry: labels[exp], ExpiryMs: exp, T: T, Forward: F}
+		for K, q := range chain {
+			call := q.meta.IsCall()
+			mid, ok := pricing.ImpliedVol(call, 0.5*(q.d.BidPrice+q.d.AskPrice), F, K, T, f.cfg.Rate)
+			if !ok {
+				continue
+			}
+			bid, _ := pricing.ImpliedVol(call, q.d.BidPrice, F, K, T, f.cfg.Rate)
+			ask, _ := pricing.ImpliedVol(call, q.d.AskPrice, F, K, T, f.cfg.Rate)
+			if bid > 0 && ask > 0 && ask-bid > f.cfg.MaxSpread {
+				continue
+			}
+			sm.Strikes = append(sm.Strikes, StrikeFit{
+				Symbol: q.sym, Strike: K, K: math.Log(K / F),
+				BidIV: bid, AskIV: ask, MidIV: mid,
+			})
+		}
+		sort.Slice(sm.Strikes, func(i, j int) bool { return sm.Strikes[i].Strike < sm.Strikes[j].Strike })
+		smiles = append(smiles, sm)
+	}
+	sort.Slice(smiles, func(i, j int) bool { return smiles[i].ExpiryMs < smiles[j].ExpiryMs })
+	return smiles
+}
+
+// fitSmile fits SVI to the strikes in total variance, weighting each strike
+// by the tightness of its quote, and fills residuals and the butterfly
+// check.
+func (f *Fitter) fitSmile(sm *Smile) {
+	if len(sm.Strikes) < f.cfg.MinStrikes {
+		sm.Err = "not enough quoted strikes: " + strconv.Itoa(len(sm.Strikes))
+		return
+	}
+	pts := make([]point, len(sm.Strikes))
+	for i, s := range sm.Strikes {
+		spread := 0.01
+		if s.BidIV > 0 && s.AskIV > 0 {
+			spread = math.Max(s.AskIV-s.BidIV, 0.005)
+		}
+		pts[i] = point{k: s.K, w: s.MidIV * s.MidIV * sm.T, wt: 1 / spread}
+	}
+	p, err := fitSVI(pts)
+	if err != nil {
+		sm.Err = err.Error()
+		return
+	}
+	sm.Params = p
+	var ss float64
+	for i := range sm.Strikes {
+		s := &sm.Strikes[i]
+		s.FitIV = p.IV(s.K, sm.T)
+		s.Residual = s.MidIV - s.FitIV
+		ss += s.Residual * s.Residual
+	}
+	sm.RMSE = math.Sqrt(ss / float64(len(sm.Strikes)))
+	sm.Butterfly = butterfly(p, sm.Strikes[0].K, sm.Strikes[len(sm.Strikes)-1].K)
+}
+
+// calendar flags points where total variance decreases from one fitted
+// expiry to the next, over the strikes both expiries quote.
+func (f *Fitter) calendar(smiles []Smile) {
+	prev := -1
+	for i := range smiles {
+		if smiles[i].Err != "" {
+			continue
+		}
+		if prev >= 0 {
+			a, b := &smiles[prev], &smiles[i]
+			lo := math.Max(a.Strikes[0].K, b.Strikes[0].K)
+			hi := math.Min(a.Strikes[len(a.Strikes)-1].K, b.Strikes[len(b.Strikes)-1].K)
+			const n = 50
+			for j := 0; j <= n && lo <= hi; j++ {
+				k := lo + (hi-lo)*float64(j)/n
+				if b.Params.W(k) < a.Params.W(k)-1e-9 {
+					b.Calendar = append(b.Calendar, k)
+				}
+			}
+		}
+		prev = i
+	}
+}
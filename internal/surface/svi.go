@@ -0,0 +1,238 @@
+// Package surface fits an SVI smile per expiry to the mid implied vols of the
+// subscribed option chain and checks the fitted surface for static
+// arbitrage.
+package surface
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// SVI is the raw SVI parameterisation of total implied variance in log
+// moneyness k = ln(K/F):
+//
+//	w(k) = A + B*(Rho*(k-M) + sqrt((k-M)^2 + Sigma^2))
+type SVI struct {
+	A     float64 `json:"a"`
+	B     float64 `json:"b"`
+	Rho   float64 `json:"rho"`
+	M     float64 `json:"m"`
+	Sigma float64 `json:"sigma"`
+}
+
+// W returns the total variance at k.
+func (p SVI) W(k float64) float64 {
+	x := k - p.M
+	return p.A + p.B*(p.Rho*x+math.Sqrt(x*x+p.Sigma*p.Sigma))
+}
+
+// IV returns the implied vol at k for time to expiry T (years).
+func (p SVI) IV(k, T float64) float64 {
+	w := p.W(k)
+	if w <= 0 || T <= 0 {
+		return 0
+	}
+	return math.Sqrt(w / T)
+}
+
+// dW returns the first and second derivatives of w at k.
+func (p SVI) dW(k float64) (float64, float64) {
+	x := k - p.M
+	r := math.Sqrt(x*x + p.Sigma*p.Sigma)
+	return p.B * (p.Rho + x/r), p.B * p.Sigma * p.Sigma / (r * r * r)
+}
+
+// point is one market observation: log moneyness, total variance, weight.
+type point struct {
+	k, w, wt float64
+}
+
+// fitSVI fits raw SVI to the points with the quasi-explicit method: for a
+// given (M, Sigma) the best (A, B, Rho) is a small linear least squares
+// problem, and (M, Sigma) are searched with Nelder-Mead.
+func fitSVI(pts []point) (SVI, error) {
+	if len(pts) < 5 {
+		return SVI{}, fmt.Errorf("need 5 strikes, have %d", len(pts))
+	}
+	kmin, kmax := pts[0].k, pts[0].k
+	for _, p := range pts {
+		kmin, kmax = math.Min(kmin, p.k), math.Max(kmax, p.k)
+	}
+	span := math.Max(kmax-kmin, 0.05)
+
+	obj := func(x [2]float64) (SVI, float64) {
+		m, sigma := x[0], x[1]
+		if sigma < 1e-3 || sigma > 4*span || m < kmin-span || m > kmax+span {
+			return SVI{}, math.Inf(1)
+		}
+		p := innerFit(pts, m, sigma)
+		return p, sse(pts, p)
+	}
+
+	best, bestErr := SVI{}, math.Inf(1)
+	// a few starts: the smile minimum is usually near ATM
+	for _, m0 := range []float64{0, kmin + span/4, kmax - span/4} {
+		x := nelderMead(func(x [2]float64) float64 { _, e := obj(x); return e }, [2]float64{m0, span / 4}, span/8)
+		if p, e := obj(x); e < bestErr {
+			best, bestErr = p, e
+		}
+	}
+	if math.IsInf(bestErr, 1) {
+		return SVI{}, fmt.Errorf("no admissible fit")
+	}
+	return best, nil
+}
+
+// innerFit solves w = a + d*y + c*z, y = (k-m)/sigma, z = sqrt(y^2+1), by
+// weighted least squares and maps back to raw SVI with c >= 0, |d| <= c.
+func innerFit(pts []point, m, sigma float64) SVI {
+	var ata [3][3]float64
+	var atb [3]float64
+	for _, p := range pts {
+		y := (p.k - m) / sigma
+		row := [3]float64{1, y, math.Sqrt(y*y + 1)}
+		for i := 0; i < 3; i++ {
+			atb[i] += p.wt * row[i] * p.w
+			for j := 0; j < 3; j++ {
+				ata[i][j] += p.wt * row[i] * row[j]
+			}
+		}
+	}
+	a, d, c := 0.0, 0.0, 0.0
+	if x, ok := solve3(ata, atb); ok {
+		a, d, c = x[0], x[1], x[2]
+	}
+	if c < 0 {
+		c = 0
+	}
+	d = math.Max(-c, math.Min(c, d))
+	// refit the level for the projected slope terms
+	var num, den float64
+	for _, p := range pts {
+		y := (p.k - m) / sigma
+		num += p.wt * (p.w - d*y - c*math.Sqrt(y*y+1))
+		den += p.wt
+	}
+	if den > 0 {
+		a = num / den
+	}
+	p := SVI{A: a, B: c / sigma, M: m, Sigma: sigma}
+	if c > 0 {
+		p.Rho = d / c
+	}
+	return p
+}
+
+func sse(pts []point, p SVI) float64 {
+	var s float64
+	for _, q := range pts {
+		e := p.W(q.k) - q.w
+		s += q.wt * e * e
+	}
+	return s
+}
+
+// solve3 solves a 3x3 system by Gaussian elimination with partial pivoting.
+func solve3(a [3][3]float64, b [3]float64) ([3]float64, bool) {
+	for c := 0; c < 3; c++ {
+		piv := c
+		for r := c + 1; r < 3; r++ {
+			if math.Abs(a[r][c]) > math.Abs(a[piv][c]) {
+				piv = r
+			}
+		}
+		if math.Abs(a[piv][c]) < 1e-14 {
+			return [3]float64{}, false
+		}
+		a[c], a[piv] = a[piv], a[c]
+		b[c], b[piv] = b[piv], b[c]
+		for r := c + 1; r < 3; r++ {
+			f := a[r][c] / a[c][c]
+			for k := c; k < 3; k++ {
+				a[r][k] -= f * a[c][k]
+			}
+			b[r] -= f * b[c]
+		}
+	}
+	var x [3]float64
+	for r := 2; r >= 0; r-- {
+		s := b[r]
+		for k := r + 1; k < 3; k++ {
+			s -= a[r][k] * x[k]
+		}
+		x[r] = s / a[r][r]
+	}
+	return x, true
+}
+
+// nelderMead minimises f over two variables from x0 with initial step.
+func nelderMead(f func([2]float64) float64, x0 [2]float64, step float64) [2]float64 {
+	type vtx struct {
+		x [2]float64
+		f float64
+	}
+	s := []vtx{{x0, f(x0)}}
+	for i := 0; i < 2; i++ {
+		x := x0
+		x[i] += step
+		s = append(s, vtx{x, f(x)})
+	}
+	lerp := func(a, b [2]float64, t float64) [2]float64 {
+		return [2]float64{a[0] + t*(b[0]-a[0]), a[1] + t*(b[1]-a[1])}
+	}
+	for it := 0; it < 200; it++ {
+		sort.Slice(s, func(i, j int) bool { return s[i].f < s[j].f })
+		if math.Abs(s[2].f-s[0].f) <= 1e-12*(math.Abs(s[0].f)+1e-12) {
+			break
+		}
+		c := lerp(s[0].x, s[1].x, 0.5)
+		r := lerp(c, s[2].x, -1)
+		fr := f(r)
+		switch {
+		case fr < s[0].f:
+			e := lerp(c, s[2].x, -2)
+			if fe := f(e); fe < fr {
+				s[2] = vtx{e, fe}
+			} else {
+				s[2] = vtx{r, fr}
+			}
+		case fr < s[1].f:
+			s[2] = vtx{r, fr}
+		default:
+			k := lerp(c, s[2].x, 0.5)
+			if fk := f(k); fk < s[2].f {
+				s[2] = vtx{k, fk}
+				continue
+			}
+			for i := 1; i < 3; i++ {
+				x := lerp(s[0].x, s[i].x, 0.5)
+				s[i] = vtx{x, f(x)}
+			}
+		}
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].f < s[j].f })
+	return s[0].x
+}
+
+// butterfly reports where Gatheral's density condition g(k) >= 0 fails (or
+// w <= 0) on a grid over [kmin, kmax].
+func butterfly(p SVI, kmin, kmax float64) []float64 {
+	var bad []float64
+	const n = 50
+	for i := 0; i <= n; i++ {
+		k := kmin + (kmax-kmin)*float64(i)/n
+		w := p.W(k)
+		if w <= 0 {
+			bad = append(bad, k)
+			continue
+		}
+		w1, w2 := p.dW(k)
+		t := 1 - k*w1/(2*w)
+		g := t*t - w1*w1/4*(1/w+0.25) + w2/2
+		if g < -1e-9 {
+			bad = append(bad, k)
+		}
+	}
+	return bad
+}
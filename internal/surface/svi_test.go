@@ -0,0 +1,70 @@
+package surface
+
+import (
+	"math"
+	"testing"
+)
+
+// sample returns n equally weighted points of p over [kmin, kmax].
+func sample(p SVI, kmin, kmax float64, n int) []point {
+	pts := make([]point, n)
+	for i := range pts {
+		k := kmin + (kmax-kmin)*float64(i)/float64(n-1)
+		pts[i] = point{k: k, w: p.W(k), wt: 1}
+	}
+	return pts
+}
+
+func TestFitSVIRecoversSmile(t *testing.T) {
+	tests := []struct {
+		name       string
+		p          SVI
+		kmin, kmax float64
+	}{
+		{"skewed short dated", SVI{A: 0.01, B: 0.1, Rho: -0.4, M: 0.02, Sigma: 0.1}, -0.4, 0.4},
+		{"symmetric", SVI{A: 0.04, B: 0.2, Rho: 0, M: 0, Sigma: 0.2}, -0.6, 0.6},
+		{"call skew long dated", SVI{A: 0.15, B: 0.3, Rho: 0.3, M: -0.05, Sigma: 0.3}, -1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pts := sample(tt.p, tt.kmin, tt.kmax, 15)
+			got, err := fitSVI(pts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, q := range pts {
+				if e := math.Abs(got.W(q.k) - q.w); e > 1e-4*math.Max(q.w, 1e-2) {
+					t.Errorf("w(%.3f) = %g, want %g (fit %+v)", q.k, got.W(q.k), q.w, got)
+				}
+			}
+		})
+	}
+}
+
+func TestFitSVITooFewStrikes(t *testing.T) {
+	pts := sample(SVI{A: 0.04, B: 0.2, Sigma: 0.2}, -0.3, 0.3, 4)
+	if _, err := fitSVI(pts); err == nil {
+		t.Fatal("fitSVI with 4 strikes succeeded")
+	}
+}
+
+func TestButterfly(t *testing.T) {
+	tests := []struct {
+		name string
+		p    SVI
+		arb  bool
+	}{
+		{"arbitrage-free", SVI{A: 0.04, B: 0.2, Rho: -0.3, M: 0, Sigma: 0.2}, false},
+		// Axel Vogt's raw SVI slice with negative density (Gatheral & Jacquier)
+		{"vogt", SVI{A: -0.0410, B: 0.1331, Rho: 0.3060, M: 0.3586, Sigma: 0.4153}, true},
+		{"negative variance", SVI{A: -0.1, B: 0.05, Rho: 0, M: 0, Sigma: 0.1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bad := butterfly(tt.p, -1.5, 1.5)
+			if (len(bad) > 0) != tt.arb {
+				t.Fatalf("butterfly() flagged %d points, want arbitrage=%v", len(bad), tt.arb)
+			}
+		})
+	}
+}
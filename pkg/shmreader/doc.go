@@ -0,0 +1,7 @@
+// Package shmreader gives other processes on the same box lock-free, read-only
+// access to the hedger's SharedBook segment under /dev/shm (Linux only).
+//
+// Depth entries are read through their sequence lock, so a returned quote is
+// never torn. LastUpdateNs values are CLOCK_MONOTONIC nanoseconds and can be
+// compared against Nanotime() of the reading process.
+package shmreader
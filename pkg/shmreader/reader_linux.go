@@ -0,0 +1,148 @@
+//go:build linux
+
+package shmreader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"Options_Hedger/internal/data"
+)
+
+var (
+	ErrNotReady   = errors.New("shmreader: segment not initialized by writer")
+	ErrBadVersion = errors.New("shmreader: unsupported segment version")
+)
+
+// Reader is a read-only view of the writer's segment.
+type Reader struct {
+	mem     []byte
+	hdr     *data.ShmHeader
+	book    *data.SharedBook
+	entries []data.DepthEntry
+	cap     int
+}
+
+// Open maps /dev/shm/<name> read-only. An empty name uses data.ShmName().
+func Open(name string) (*Reader, error) {
+	if name == "" {
+		name = data.ShmName()
+	}
+	path := filepath.Join("/dev/shm", name)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() < int64(unsafe.Sizeof(data.ShmHeader{})) {
+		return nil, ErrNotReady
+	}
+	mem, err := syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("mmap %s: %w", path, err)
+	}
+
+	hdr := (*data.ShmHeader)(unsafe.Pointer(&mem[0]))
+	if atomic.LoadUint64(&hdr.Magic) != data.ShmMagic {
+		_ = syscall.Munmap(mem)
+		return nil, ErrNotReady
+	}
+	if hdr.Version != data.ShmVersion {
+		_ = syscall.Munmap(mem)
+		return nil, ErrBadVersion
+	}
+	capacity := int(hdr.Capacity)
+	if data.ShmSize(capacity) > len(mem) {
+		_ = syscall.Munmap(mem)
+		return nil, ErrNotReady
+	}
+	return &Reader{
+		mem:     mem,
+		hdr:     hdr,
+		book:    (*data.SharedBook)(unsafe.Pointer(&mem[data.ShmBookOffset(capacity)])),
+		entries: unsafe.Slice((*data.DepthEntry)(unsafe.Pointer(&mem[data.ShmEntriesOffset(capacity)])), capacity),
+		cap:     capacity,
+	}, nil
+}
+
+// Close unmaps the segment.
+func (r *Reader) Close() error {
+	if r.mem == nil {
+		return nil
+	}
+	err := syscall.Munmap(r.mem)
+	r.mem, r.hdr, r.book, r.entries = nil, nil, nil, nil
+	return err
+}
+
+// Live reports whether the writer still owns a valid segment
+// (it clears Magic while re-initializing).
+func (r *Reader) Live() bool { return atomic.LoadUint64(&r.hdr.Magic) == data.ShmMagic }
+
+// WriterPid returns the pid of the process that created the segment.
+func (r *Reader) WriterPid() int { return int(r.hdr.WriterPid) }
+
+// Capacity returns the number of depth slots in the segment.
+func (r *Reader) Capacity() int { return r.cap }
+
+// IndexPrice returns the last index price and its update time (monotonic ns).
+func (r *Reader) IndexPrice() (float64, int64) { return r.book.Index() }
+
+// Depth returns a consistent top-of-book copy for a symbol index.
+func (r *Reader) Depth(idx int) (data.DepthEntry, bool) {
+	if idx < 0 || idx >= r.cap {
+		return data.DepthEntry{}, false
+	}
+	return r.entries[idx].Load(), true
+}
+
+// Symbols returns a consistent copy of the symbol table (index = symbol index).
+func (r *Reader) Symbols() []string {
+	for spin := 0; ; spin++ {
+		seq := atomic.LoadUint64(&r.hdr.SymbolSeq)
+		if seq&1 != 0 {
+			runtime.Gosched()
+			continue
+		}
+		n := int(atomic.LoadInt32(&r.hdr.SymbolCount))
+		if n > r.cap {
+			n = r.cap
+		}
+		out := make([]string, n)
+		base := data.ShmSymbolTableOffset()
+		for i := 0; i < n; i++ {
+			slot := r.mem[base+i*data.SymbolNameLen : base+(i+1)*data.SymbolNameLen]
+			end := 0
+			for end < len(slot) && slot[end] != 0 {
+				end++
+			}
+			out[i] = string(slot[:end])
+		}
+		if atomic.LoadUint64(&r.hdr.SymbolSeq) == seq {
+			return out
+		}
+	}
+}
+
+// Lookup returns the symbol index of name, or -1.
+func (r *Reader) Lookup(name string) int32 {
+	for i, s := range r.Symbols() {
+		if s == name {
+			return int32(i)
+		}
+	}
+	return -1
+}
+
+// Nanotime returns the monotonic clock used for LastUpdateNs.
+func Nanotime() int64 { return data.Nanotime() }